@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/MGavranovic/jaeger-backend/src/jaegermail"
+)
+
+const (
+	defaultDeletionGrace = 30 * 24 * time.Hour
+	purgeInterval        = time.Hour
+)
+
+// deletionGrace is how long a deleted account can still be restored by logging in, ACCOUNT_DELETION_GRACE overrides it
+func deletionGrace() time.Duration {
+	if value := os.Getenv("ACCOUNT_DELETION_GRACE"); value != "" {
+		grace, err := time.ParseDuration(value)
+		if err == nil && grace >= 0 {
+			return grace
+		}
+		log.Printf("Invalid ACCOUNT_DELETION_GRACE %q, using %s", value, defaultDeletionGrace)
+	}
+	return defaultDeletionGrace
+}
+
+// handleDeleteAccount soft deletes the current user, the account is purged after the grace period
+func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized account deletion request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deletedAt, err := s.users.ScheduleUserDeletion(r.Context(), caller.User.ID)
+	if err != nil {
+		log.Printf("Failed scheduling deletion of user %d: %s", caller.User.ID, err)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+	purgeAt := deletedAt.Add(deletionGrace())
+	s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditUserDelete, fmt.Sprintf("user:%d", caller.User.ID), "purge at "+purgeAt.Format(time.RFC3339))
+
+	err = s.mailer.Send(jaegermail.Message{
+		To:      caller.User.Email,
+		Subject: "Your Jaeger account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nYour Jaeger account and all of your notes will be permanently deleted on %s.\n"+
+			"If you change your mind, log in before then and the deletion will be cancelled.\n", caller.User.FullName, purgeAt.Format("January 2, 2006")),
+	})
+	if err != nil {
+		log.Printf("Failed sending deletion email to %s: %s", caller.User.Email, err)
+	}
+
+	jaegerjwt.DeleteCookie(w) // the sessions are already revoked
+	jaegerjwt.DeleteRefreshCookie(w)
+	deleteCSRFCookie(w)
+	writeJSON(w, http.StatusAccepted, map[string]any{"purgeAt": purgeAt})
+	log.Printf("User %d scheduled for deletion at %s", caller.User.ID, purgeAt)
+}
+
+// runAccountPurge hard deletes the accounts whose grace period is over, it blocks until stop is closed
+func (s *Server) runAccountPurge(stop <-chan struct{}) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.users.PurgeDeletedUsers(context.Background(), time.Now().Add(-deletionGrace()))
+		if err != nil {
+			log.Printf("Account purge failed: %s", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleExportAccount sends everything stored about the user as a zip of JSON files
+func (s *Server) handleExportAccount(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized export request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userId := caller.User.ID
+
+	// everything is collected before writing so a failing query can still be answered with an error
+	files := []struct {
+		name  string
+		fetch func() (any, error)
+	}{
+		{"profile.json", func() (any, error) { return s.users.GetProfile(r.Context(), userId) }},
+		{"notes.json", func() (any, error) { return s.notes.GetAllUserNotes(r.Context(), userId, jaegerdb.NoteFilter{}) }},
+		{"status_history.json", func() (any, error) { return s.notes.GetStatusHistory(r.Context(), userId) }},
+		{"interviews.json", func() (any, error) { return s.notes.GetUserInterviews(r.Context(), userId) }},
+		{"contacts.json", func() (any, error) { return s.notes.GetContacts(r.Context(), userId) }},
+		{"companies.json", func() (any, error) { return s.notes.GetCompanies(r.Context(), userId) }},
+		{"tags.json", func() (any, error) { return s.notes.GetTags(r.Context(), userId) }},
+		{"sessions.json", func() (any, error) { return s.users.GetActiveSessions(r.Context(), userId) }},
+		{"login_history.json", func() (any, error) { return s.users.GetLoginHistory(r.Context(), userId) }},
+		{"identities.json", func() (any, error) { return s.users.GetUserIdentities(r.Context(), userId) }},
+		{"api_tokens.json", func() (any, error) { return s.users.GetAPITokens(r.Context(), userId) }},
+		{"activity.json", func() (any, error) {
+			events, _, err := s.users.GetAuditEvents(r.Context(), userId, math.MaxInt32, 0) // all of them
+			return events, err
+		}},
+	}
+
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		data, err := file.fetch()
+		if err != nil {
+			log.Printf("Failed collecting %s for the export of user %d: %s", file.name, userId, err)
+			http.Error(w, "Failed to export account", http.StatusInternalServerError)
+			return
+		}
+		contents[i], err = json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			log.Printf("Failed marshaling %s for the export of user %d: %s", file.name, userId, err)
+			http.Error(w, "Failed to export account", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("jaeger-export-%s.zip", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	archive := zip.NewWriter(w)
+	for i, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			log.Printf("Failed writing the export of user %d: %s", userId, err)
+			return
+		}
+		if _, err := f.Write(contents[i]); err != nil {
+			log.Printf("Failed writing the export of user %d: %s", userId, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed writing the export of user %d: %s", userId, err)
+		return
+	}
+	log.Printf("User %d exported their data", userId)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+const deletionSubject = "Your Jaeger account will be deleted"
+
+// deleteAccount schedules the deletion of the client's account and returns when it was deleted
+func (c *testClient) deleteAccount() time.Time {
+	c.t.Helper()
+	rec := c.expect(http.MethodDelete, "/api/users/current", nil, http.StatusAccepted)
+	purgeAt := decodeResponse[struct {
+		PurgeAt time.Time `json:"purgeAt"`
+	}](c.t, rec).PurgeAt
+	return purgeAt.Add(-deletionGrace())
+}
+
+func TestExportAccount(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+	ada.createNote("Acme")
+	ada.createTag("remote")
+	bob.createNote("Globex")
+	bearer, token := ada.createAPIToken("ci")
+
+	rec := ada.expect(http.MethodGet, "/api/users/current/export", nil, http.StatusOK)
+	if !strings.HasPrefix(rec.Header().Get("Content-Disposition"), `attachment; filename="jaeger-export-`) {
+		t.Errorf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("reading the export: %s", err)
+	}
+
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name], _ = io.ReadAll(f)
+		f.Close()
+		if !json.Valid(files[file.Name]) {
+			t.Errorf("%s isn't JSON: %s", file.Name, files[file.Name])
+		}
+	}
+	for _, name := range []string{"profile.json", "notes.json", "status_history.json", "interviews.json", "contacts.json", "companies.json",
+		"tags.json", "sessions.json", "login_history.json", "identities.json", "api_tokens.json", "activity.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("export is missing %s", name)
+		}
+	}
+
+	var profile jaegerdb.ProfileDB
+	json.Unmarshal(files["profile.json"], &profile)
+	if profile.Email != "ada@example.com" {
+		t.Errorf("exported profile = %+v", profile)
+	}
+	var notes []jaegerdb.NoteDB
+	json.Unmarshal(files["notes.json"], &notes)
+	if len(notes) != 1 || notes[0].CompanyName != "Acme" {
+		t.Errorf("exported notes = %+v, want only ada's note", notes)
+	}
+	var tags []jaegerdb.TagDB
+	json.Unmarshal(files["tags.json"], &tags)
+	if len(tags) != 1 || tags[0].Name != "remote" {
+		t.Errorf("exported tags = %+v", tags)
+	}
+	var events []jaegerdb.AuditEventDB
+	json.Unmarshal(files["activity.json"], &events)
+	if !slices.ContainsFunc(events, func(e jaegerdb.AuditEventDB) bool { return e.Action == jaegerdb.AuditLogin }) {
+		t.Errorf("exported activity has no login: %+v", events)
+	}
+
+	// nothing in it can be used to log in
+	for name, data := range files {
+		for _, secret := range []string{testPassword, token.Token, jaegerjwt.HashToken(token.Token), ada.cookies["refreshToken"]} {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Errorf("%s contains a secret", name)
+			}
+		}
+	}
+
+	bearer.expect(http.MethodGet, "/api/users/current/export", nil, http.StatusUnauthorized)
+	ts.client(t).expect(http.MethodGet, "/api/users/current/export", nil, http.StatusUnauthorized)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	t.Setenv("ACCOUNT_DELETION_GRACE", "48h")
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	c.createNote("Acme")
+	bearer, _ := c.createAPIToken("ci")
+
+	stolen := ts.client(t)
+	stolen.cookies["authToken"], stolen.cookies["refreshToken"] = c.cookies["authToken"], c.cookies["refreshToken"]
+	stolen.csrf, stolen.cookies[csrfCookieName] = c.csrf, c.csrf
+
+	deletedAt := c.deleteAccount()
+	if since := time.Since(deletedAt); since < 0 || since > time.Minute {
+		t.Errorf("deleted at %v with a 48h grace period, want about now", deletedAt)
+	}
+	if len(c.cookies) != 0 {
+		t.Errorf("deletion left cookies %v", c.cookies)
+	}
+	if len(ts.mails(t, "ada@example.com")) != 2 { // the verification mail and the deletion notice
+		t.Errorf("no deletion notice sent")
+	}
+
+	// nothing from before the deletion gets in anymore
+	stolen.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+	stolen.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+	bearer.expect(http.MethodGet, "/api/notes/", nil, http.StatusUnauthorized)
+
+	// a refresh token the deletion didn't revoke, e.g. one issued while it was running, is refused as well
+	user := ts.user(t, "ada@example.com")
+	refresh, refreshHash, err := jaegerjwt.GenerateRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.store.CreateRefreshToken(t.Context(), user.ID, "late-family", refreshHash, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	late := ts.client(t)
+	late.cookies["refreshToken"] = refresh
+	late.csrf, late.cookies[csrfCookieName] = "csrf", "csrf"
+	late.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+
+	// logging in during the grace period brings the account back with its notes
+	c.login("ada@example.com", testPassword)
+	if ts.user(t, "ada@example.com").DeletionScheduled {
+		t.Errorf("login didn't cancel the deletion")
+	}
+	if notes := c.listNotes(); len(notes) != 1 {
+		t.Errorf("restored account has %d notes, want 1", len(notes))
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+	carol := ts.signup(t, "carol@example.com")
+	ada.createNote("Acme")
+	carol.createNote("Globex")
+	adaId := ts.user(t, "ada@example.com").ID
+
+	adaDeleted := ada.deleteAccount()
+	bobDeleted := bob.deleteAccount()
+	if !adaDeleted.Before(bobDeleted) {
+		t.Fatalf("both accounts deleted at %v", adaDeleted)
+	}
+
+	if purged, err := ts.store.PurgeDeletedUsers(t.Context(), adaDeleted); err != nil || purged != 0 {
+		t.Fatalf("purge with the cutoff at the deletion = %d, %v, want nothing purged", purged, err)
+	}
+
+	// only accounts deleted before the cutoff go, accounts that weren't deleted never do
+	if purged, err := ts.store.PurgeDeletedUsers(t.Context(), bobDeleted); err != nil || purged != 1 {
+		t.Fatalf("purged %d, %v, want ada's account", purged, err)
+	}
+	if _, err := ts.store.GetUserByEmail(t.Context(), "ada@example.com"); err == nil {
+		t.Errorf("purged user still exists")
+	}
+	if notes, _ := ts.store.GetAllUserNotes(t.Context(), adaId, jaegerdb.NoteFilter{}); len(notes) != 0 {
+		t.Errorf("purged user still has %d notes", len(notes))
+	}
+	ts.client(t).expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "ada@example.com", Password: testPassword}, http.StatusUnauthorized)
+
+	ts.client(t).login("bob@example.com", testPassword)
+	if notes := carol.listNotes(); len(notes) != 1 {
+		t.Errorf("carol has %d notes after the purge, want 1", len(notes))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type UsersPage struct {
+	Users    []jaegerdb.AdminUserDB `json:"users"`
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"pageSize"`
+	Total    int                    `json:"total"`
+}
+
+// pagination reads the page and pageSize query parameters, pages start at 1
+func pagination(r *http.Request) (page, pageSize int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err = strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	return page, min(pageSize, maxPageSize)
+}
+
+// handleAdminUsers lists all users a page at a time, password hashes are never part of it
+func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, pageSize := pagination(r)
+	users, total, err := s.users.GetUsersPage(r.Context(), pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Failed retrieving users for admin: %s", err)
+		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
+		return
+	}
+	if users == nil {
+		users = []jaegerdb.AdminUserDB{}
+	}
+
+	writeJSON(w, http.StatusOK, UsersPage{Users: users, Page: page, PageSize: pageSize, Total: total})
+}
+
+// handleAdminUser handles the actions on a single user: /api/admin/users/{id}/{action}
+func (s *Server) handleAdminUser(w http.ResponseWriter, r *http.Request) {
+	admin := callerFromContext(r)
+
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/users/"), "/")
+	userId, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "disable", "enable":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if userId == admin.User.ID {
+			http.Error(w, "You can't disable your own account", http.StatusBadRequest)
+			return
+		}
+
+		disabled := action == "disable"
+		err := s.users.SetUserDisabled(r.Context(), userId, disabled)
+		if writeStoreError(w, err, "Failed to update user", "Failed to %s user %d", action, userId) {
+			return
+		}
+
+		auditAction := jaegerdb.AuditAdminEnable
+		if disabled {
+			auditAction = jaegerdb.AuditAdminDisable
+		}
+		s.audit(r, userId, admin.User.ID, auditAction, "user:"+idStr, "")
+		log.Printf("Admin %d set disabled=%t for user %d", admin.User.ID, disabled, userId)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("User " + action + "d"))
+
+	case "force-password-reset":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		email, err := s.users.RequirePasswordReset(r.Context(), userId)
+		if writeStoreError(w, err, "Failed to force password reset", "Failed forcing password reset of user %d", userId) {
+			return
+		}
+		// the user can still request another link through forgot password if this one gets lost
+		if err := s.sendPasswordReset(r.Context(), email); err != nil {
+			log.Printf("Failed sending forced password reset to user %d: %s", userId, err)
+		}
+
+		s.audit(r, userId, admin.User.ID, jaegerdb.AuditAdminPasswordReset, "user:"+idStr, "")
+		log.Printf("Admin %d forced a password reset for user %d", admin.User.ID, userId)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Password reset required"))
+
+	case "login-attempts":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		attempts, err := s.users.GetRecentLoginFailures(r.Context(), userId, recentFailuresLimit)
+		if err != nil {
+			log.Printf("Failed retrieving login attempts for user %d: %s", userId, err)
+			http.Error(w, "Failed to retrieve login attempts", http.StatusInternalServerError)
+			return
+		}
+		if attempts == nil {
+			attempts = []jaegerdb.LoginAttemptDB{}
+		}
+		writeJSON(w, http.StatusOK, attempts)
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
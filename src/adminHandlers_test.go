@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/MGavranovic/jaeger-backend/src/jaegertotp"
+)
+
+// adminUsers gives the users in admins the admin role, the stores can't grant it, that's done in the database
+type adminUsers struct {
+	jaegerdb.UserStore
+	admins map[string]bool
+}
+
+func (a adminUsers) GetUserByEmail(ctx context.Context, email string) (*jaegerdb.RetrievedUser, error) {
+	user, err := a.UserStore.GetUserByEmail(ctx, email)
+	if err == nil && a.admins[email] {
+		user.Role = jaegerdb.RoleAdmin
+	}
+	return user, err
+}
+
+// signupAdmin signs up a user with the admin role
+func (ts *testServer) signupAdmin(t *testing.T, email string) *testClient {
+	t.Helper()
+	c := ts.signup(t, email)
+	ts.users = adminUsers{UserStore: ts.store, admins: map[string]bool{email: true}}
+	return c
+}
+
+func TestForcedPasswordResetLocksAccount(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	user := ts.user(t, "ada@example.com")
+
+	created := decodeResponse[NewAPITokenResponse](t, c.expect(http.MethodPost, "/api/users/current/tokens", NewAPITokenData{Name: "cli"}, http.StatusCreated))
+	api := ts.client(t)
+	api.bearer = created.Token
+	api.expect(http.MethodGet, "/api/notes/", nil, http.StatusOK)
+
+	// with 2FA on, the password step is done before the reset and the second one after it
+	secret, err := jaegertotp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.store.SetPendingTOTPSecret(t.Context(), user.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.store.EnableTOTP(t.Context(), user.ID, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	pending := ts.client(t)
+	mfa := decodeResponse[map[string]any](t, pending.expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "ada@example.com", Password: testPassword}, http.StatusAccepted))
+
+	// what the admin endpoint does
+	if _, err := ts.store.RequirePasswordReset(t.Context(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	c.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+	c.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+	api.expect(http.MethodGet, "/api/notes/", nil, http.StatusUnauthorized)
+	ts.client(t).expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "ada@example.com", Password: testPassword}, http.StatusForbidden)
+
+	code, err := jaegertotp.Code(secret, jaegertotp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfaToken, _ := mfa["mfaToken"].(string)
+	pending.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: mfaToken, Code: code}, http.StatusForbidden)
+	if pending.cookies["authToken"] != "" {
+		t.Errorf("the second factor started a session during the forced reset")
+	}
+
+	// the reset lifts the lock, old sessions and API tokens stay revoked
+	if err := ts.store.CreatePasswordResetToken(t.Context(), user.ID, jaegerjwt.HashToken("reset-token"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	ts.client(t).expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: "reset-token", Password: "a brand new passphrase"}, http.StatusOK)
+	api.expect(http.MethodGet, "/api/notes/", nil, http.StatusUnauthorized)
+	c.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+}
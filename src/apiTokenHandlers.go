@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+type NewAPITokenData struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"` // 0 means the token doesn't expire
+}
+
+// validate gives a token without scopes every scope
+func (req *NewAPITokenData) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("Name is required")
+	}
+	if req.ExpiresInDays < 0 {
+		return errors.New("expiresInDays can't be negative")
+	}
+
+	if len(req.Scopes) == 0 {
+		req.Scopes = apiTokenScopes
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(apiTokenScopes, scope) {
+			return errors.New("Unknown scope " + scope)
+		}
+	}
+	return nil
+}
+
+type NewAPITokenResponse struct {
+	jaegerdb.APITokenDB
+	Token string `json:"token"` // only returned once, on creation
+}
+
+// handleAPITokens lists (GET) and creates (POST) the user's personal access tokens
+func (s *Server) handleAPITokens(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// tokens can only be managed from a logged in session, never with another token
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized API tokens request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := s.users.GetAPITokens(r.Context(), caller.User.ID)
+		if err != nil {
+			log.Printf("Failed retrieving API tokens of user %d: %s", caller.User.ID, err)
+			http.Error(w, "Failed to retrieve API tokens", http.StatusInternalServerError)
+			return
+		}
+		if tokens == nil {
+			tokens = []jaegerdb.APITokenDB{}
+		}
+		writeJSON(w, http.StatusOK, tokens)
+	case http.MethodPost:
+		s.createAPIToken(w, r, caller)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createAPIToken(w http.ResponseWriter, r *http.Request, caller *caller) {
+	newToken, ok := decodeJSON[NewAPITokenData](w, r, "API token")
+	if !ok {
+		return
+	}
+
+	var expiresAt *time.Time
+	if newToken.ExpiresInDays > 0 {
+		expires := time.Now().Add(time.Duration(newToken.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &expires
+	}
+
+	secret, _, err := jaegerjwt.GenerateOpaqueToken()
+	if err != nil {
+		log.Printf("Failed generating API token: %s", err)
+		http.Error(w, "Failed to create API token", http.StatusInternalServerError)
+		return
+	}
+	token := apiTokenPrefix + secret
+
+	created, err := s.users.CreateAPIToken(r.Context(), caller.User.ID, newToken.Name, token[:len(apiTokenPrefix)+6], jaegerjwt.HashToken(token), newToken.Scopes, expiresAt)
+	if err != nil {
+		log.Printf("Failed storing API token for user %d: %s", caller.User.ID, err)
+		http.Error(w, "Failed to create API token", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, NewAPITokenResponse{APITokenDB: created, Token: token})
+	log.Printf("API token %d created for user %d", created.ID, caller.User.ID)
+}
+
+func (s *Server) handleRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized API token revoke request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokenId, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/users/current/tokens/"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	err = s.users.RevokeAPIToken(r.Context(), caller.User.ID, tokenId)
+	if writeStoreError(w, err, "Failed to revoke API token", "Failed revoking API token %d", tokenId) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("API token revoked"))
+	log.Printf("API token %d of user %d revoked", tokenId, caller.User.ID)
+}
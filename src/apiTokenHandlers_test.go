@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+// createAPIToken creates a personal access token and returns a client using it
+func (c *testClient) createAPIToken(name string, scopes ...string) (*testClient, NewAPITokenResponse) {
+	c.t.Helper()
+	created := decodeResponse[NewAPITokenResponse](c.t, c.expect(http.MethodPost, "/api/users/current/tokens", NewAPITokenData{Name: name, Scopes: scopes}, http.StatusCreated))
+	bearer := c.srv.client(c.t)
+	bearer.bearer = created.Token
+	return bearer, created
+}
+
+func TestAPITokenCreate(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	c.expect(http.MethodPost, "/api/users/current/tokens", NewAPITokenData{Name: "  "}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/current/tokens", NewAPITokenData{Name: "ci", Scopes: []string{"admin"}}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/current/tokens", NewAPITokenData{Name: "ci", ExpiresInDays: -1}, http.StatusBadRequest)
+
+	_, created := c.createAPIToken("ci")
+	if !strings.HasPrefix(created.Token, apiTokenPrefix) || !strings.HasPrefix(created.Token, created.Prefix) {
+		t.Errorf("token %q doesn't start with its prefix %q", created.Token, created.Prefix)
+	}
+	if len(created.Scopes) != len(apiTokenScopes) || created.ExpiresAt != nil {
+		t.Errorf("token without scopes or expiry = %+v, want every scope and no expiry", created.APITokenDB)
+	}
+
+	// the plaintext is only in the response to the creation
+	listing := c.expect(http.MethodGet, "/api/users/current/tokens", nil, http.StatusOK)
+	if strings.Contains(listing.Body.String(), created.Token) {
+		t.Errorf("token listing contains the plaintext token")
+	}
+	tokens := decodeResponse[[]jaegerdb.APITokenDB](t, listing)
+	if len(tokens) != 1 || tokens[0].ID != created.ID || tokens[0].Prefix != created.Prefix {
+		t.Errorf("tokens = %+v", tokens)
+	}
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	note := c.createNote("Acme")
+	id := strconv.Itoa(note.Id)
+
+	reader, _ := c.createAPIToken("dashboard", scopeNotesRead)
+	writer, _ := c.createAPIToken("importer")
+
+	if notes := reader.listNotes(); len(notes) != 1 || notes[0].Id != note.Id {
+		t.Errorf("notes read with the token = %+v", notes)
+	}
+	reader.expect(http.MethodGet, "/api/notes/current/"+id, nil, http.StatusOK)
+
+	// bearer requests carry no cookies and no CSRF header, a read token can't change anything
+	update := updatedNote{NoteId: note.Id, CompanyName: "Acme", Position: "Staff Engineer", ApplicationStatus: jaegerdb.StatusApplied, AppliedOn: "2026-09-01"}
+	reader.expect(http.MethodPost, "/api/notes/create", Note{CompanyName: "Globex", AppliedOn: "2026-09-01"}, http.StatusForbidden)
+	reader.expect(http.MethodPut, "/api/notes/update", update, http.StatusForbidden)
+	reader.expect(http.MethodDelete, "/api/notes/delete/"+id, nil, http.StatusForbidden)
+
+	writer.expect(http.MethodPost, "/api/notes/create", Note{CompanyName: "Globex", AppliedOn: "2026-09-01"}, http.StatusOK)
+	writer.expect(http.MethodPut, "/api/notes/update", update, http.StatusOK)
+	writer.expect(http.MethodDelete, "/api/notes/delete/"+id, nil, http.StatusOK)
+	if notes := c.listNotes(); len(notes) != 1 || notes[0].CompanyName != "Globex" {
+		t.Errorf("notes after the token's changes = %+v", notes)
+	}
+}
+
+func TestAPITokenCannotManageTokens(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	bearer, created := c.createAPIToken("ci")
+
+	// a leaked token can't be used to mint more tokens, list them or keep itself alive
+	bearer.expect(http.MethodGet, "/api/users/current/tokens", nil, http.StatusUnauthorized)
+	bearer.expect(http.MethodPost, "/api/users/current/tokens", NewAPITokenData{Name: "more"}, http.StatusUnauthorized)
+	bearer.expect(http.MethodDelete, "/api/users/current/tokens/"+strconv.Itoa(created.ID), nil, http.StatusUnauthorized)
+	bearer.expect(http.MethodGet, "/api/users/current/sessions", nil, http.StatusUnauthorized)
+}
+
+func TestAPITokenRevokeAndExpiry(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+	user := ts.user(t, "ada@example.com")
+
+	bearer, created := ada.createAPIToken("ci")
+	path := "/api/users/current/tokens/" + strconv.Itoa(created.ID)
+	bob.expect(http.MethodDelete, path, nil, http.StatusNotFound)
+	bearer.expect(http.MethodGet, "/api/notes/", nil, http.StatusOK)
+
+	ada.expect(http.MethodDelete, path, nil, http.StatusOK)
+	ada.expect(http.MethodDelete, path, nil, http.StatusNotFound)
+	bearer.expect(http.MethodGet, "/api/notes/", nil, http.StatusUnauthorized)
+	if tokens := decodeResponse[[]jaegerdb.APITokenDB](t, ada.expect(http.MethodGet, "/api/users/current/tokens", nil, http.StatusOK)); len(tokens) != 0 {
+		t.Errorf("revoked token still listed: %+v", tokens)
+	}
+
+	expired := apiTokenPrefix + "expired"
+	if _, err := ts.store.CreateAPIToken(t.Context(), user.ID, "old", expired[:len(apiTokenPrefix)+6], jaegerjwt.HashToken(expired), apiTokenScopes, timePtr(time.Now().Add(-time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	bearer.bearer = expired
+	bearer.expect(http.MethodGet, "/api/notes/", nil, http.StatusUnauthorized)
+}
+
+func TestPasswordResetRevokesAPITokens(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	bearer, _ := c.createAPIToken("ci")
+
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "ada@example.com"}, http.StatusAccepted)
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: ts.mailedToken(t, "ada@example.com", resetSubject), Password: "a brand new passphrase"}, http.StatusOK)
+
+	bearer.expect(http.MethodGet, "/api/notes/", nil, http.StatusUnauthorized)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+type ActivityPage struct {
+	Events   []jaegerdb.AuditEventDB `json:"events"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"pageSize"`
+	Total    int                     `json:"total"`
+}
+
+// audit records an event for the user, a failing write is logged but doesn't fail the request it describes
+func (s *Server) audit(r *http.Request, userId, actorId int, action, target, details string) {
+	if err := s.users.RecordAuditEvent(r.Context(), userId, actorId, action, target, details, clientIP(r), r.UserAgent()); err != nil {
+		log.Printf("Failed recording %s for user %d: %s", action, userId, err)
+	}
+}
+
+// handleActivity shows the user the security events of their own account
+func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized activity request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, pageSize := pagination(r)
+	events, total, err := s.users.GetAuditEvents(r.Context(), caller.User.ID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Failed retrieving activity of user %d: %s", caller.User.ID, err)
+		http.Error(w, "Failed to retrieve activity", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []jaegerdb.AuditEventDB{}
+	}
+
+	writeJSON(w, http.StatusOK, ActivityPage{Events: events, Page: page, PageSize: pageSize, Total: total})
+}
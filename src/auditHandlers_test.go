@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// activity returns the client's audit events, newest first
+func (c *testClient) activity() []jaegerdb.AuditEventDB {
+	c.t.Helper()
+	return decodeResponse[ActivityPage](c.t, c.expect(http.MethodGet, "/api/users/current/activity", nil, http.StatusOK)).Events
+}
+
+func TestActivity(t *testing.T) {
+	tests := []struct {
+		name    string
+		act     func(ts *testServer, c *testClient) (target string)
+		action  string
+		details string
+	}{
+		{
+			name:   "login",
+			act:    func(ts *testServer, c *testClient) string { return "session:" },
+			action: jaegerdb.AuditLogin,
+		},
+		{
+			name: "logout",
+			act: func(ts *testServer, c *testClient) string {
+				c.expect(http.MethodPost, "/api/users/logout", nil, http.StatusOK)
+				c.login("ada@example.com", testPassword)
+				return "session:"
+			},
+			action: jaegerdb.AuditLogout,
+		},
+		{
+			name: "profile update",
+			act: func(ts *testServer, c *testClient) string {
+				c.expect(http.MethodPut, "/api/users/current/update", UpdatedUserData{FullName: "Ada King"}, http.StatusOK)
+				return "user:"
+			},
+			action:  jaegerdb.AuditUserUpdate,
+			details: "fullName",
+		},
+		{
+			name: "password change",
+			act: func(ts *testServer, c *testClient) string {
+				c.expect(http.MethodPut, "/api/users/current/update", UpdatedUserData{Password: "a brand new passphrase"}, http.StatusOK)
+				return "user:"
+			},
+			action:  jaegerdb.AuditUserUpdate,
+			details: "password",
+		},
+		{
+			name: "note delete",
+			act: func(ts *testServer, c *testClient) string {
+				id := strconv.Itoa(c.createNote("Acme").Id)
+				c.expect(http.MethodDelete, "/api/notes/delete/"+id, nil, http.StatusOK)
+				return "note:" + id
+			},
+			action: jaegerdb.AuditNoteDelete,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			c := ts.signup(t, "ada@example.com")
+			id := ts.user(t, "ada@example.com").ID
+			target := tt.act(ts, c)
+
+			events := c.activity()
+			i := slices.IndexFunc(events, func(e jaegerdb.AuditEventDB) bool { return e.Action == tt.action })
+			if i < 0 {
+				t.Fatalf("no %s event in %+v", tt.action, events)
+			}
+			e := events[i]
+			if !strings.HasPrefix(e.Target, target) || e.Details != tt.details || e.ActorID == nil || *e.ActorID != id || e.IPAddress == "" {
+				t.Errorf("%s event = %+v, want target %s... and details %q by the user", tt.action, e, target, tt.details)
+			}
+		})
+	}
+}
+
+func TestActivityOfAdminActions(t *testing.T) {
+	ts := newTestServer(t)
+	admin := ts.signupAdmin(t, "root@example.com")
+	ada := ts.signup(t, "ada@example.com")
+	adminId, adaId := ts.user(t, "root@example.com").ID, ts.user(t, "ada@example.com").ID
+	path := "/api/admin/users/" + strconv.Itoa(adaId)
+
+	admin.expect(http.MethodPost, path+"/disable", nil, http.StatusOK)
+	admin.expect(http.MethodPost, path+"/enable", nil, http.StatusOK)
+	admin.expect(http.MethodPost, path+"/force-password-reset", nil, http.StatusOK)
+	ada.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: ts.mailedToken(t, "ada@example.com", resetSubject), Password: "a brand new passphrase"}, http.StatusOK)
+	ada.login("ada@example.com", "a brand new passphrase")
+
+	// the events are in the account's own activity, with the admin as the actor
+	var got []string
+	for _, e := range ada.activity() {
+		if !strings.HasPrefix(e.Action, "admin.") {
+			continue
+		}
+		got = append(got, e.Action)
+		if e.ActorID == nil || *e.ActorID != adminId || e.Target != "user:"+strconv.Itoa(adaId) {
+			t.Errorf("%s event = %+v, want admin %d acting on user %d", e.Action, e, adminId, adaId)
+		}
+	}
+	if want := []string{jaegerdb.AuditAdminPasswordReset, jaegerdb.AuditAdminEnable, jaegerdb.AuditAdminDisable}; !slices.Equal(got, want) {
+		t.Errorf("admin events = %v, want %v", got, want)
+	}
+	if slices.ContainsFunc(admin.activity(), func(e jaegerdb.AuditEventDB) bool { return strings.HasPrefix(e.Action, "admin.") }) {
+		t.Errorf("admin actions on ada are in the admin's own activity")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// scopes personal access tokens can be limited to, tokens created without scopes get all of them
+const (
+	scopeNotesRead  = "notes:read"
+	scopeNotesWrite = "notes:write"
+)
+
+var apiTokenScopes = []string{scopeNotesRead, scopeNotesWrite}
+
+// prefix of personal access tokens, so they can be told apart from JWTs in the Authorization header
+const apiTokenPrefix = "jgr_"
+
+var errNotASession = errors.New("endpoint can't be used with an API token")
+var errAccountDisabled = errors.New("account has been disabled")
+var errAccountDeleted = errors.New("account is scheduled for deletion")
+var errPasswordResetRequired = errors.New("password has to be reset")
+
+// caller is whoever made the request, either through a session (cookie or bearer JWT) or a personal access token
+type caller struct {
+	User      *jaegerdb.RetrievedUser
+	SessionID string   // set for sessions
+	TokenID   int      // set for personal access tokens
+	Scopes    []string // only limits personal access tokens
+}
+
+func (c *caller) isAPIToken() bool {
+	return c.TokenID != 0
+}
+
+// can checks if the caller is allowed to use the scope, sessions can do everything
+func (c *caller) can(scope string) bool {
+	return !c.isAPIToken() || slices.Contains(c.Scopes, scope)
+}
+
+// isAdmin uses the role stored for the user, not the one in the token, so demoted admins lose access immediately
+func (c *caller) isAdmin() bool {
+	return c.User.Role == jaegerdb.RoleAdmin
+}
+
+// authenticate resolves the caller from the authToken cookie or the Authorization: Bearer header
+func (s *Server) authenticate(r *http.Request) (*caller, error) {
+	c, err := s.resolveCaller(r)
+	if err != nil {
+		return nil, err
+	}
+	// disabled accounts lose access right away, including their API tokens
+	if c.User.Disabled {
+		return nil, errAccountDisabled
+	}
+	// deleted accounts can only come back by logging in again
+	if c.User.DeletionScheduled {
+		return nil, errAccountDeleted
+	}
+	// a forced reset locks out everything, API tokens and sessions started before it alike, until the password is changed
+	if c.User.PasswordResetRequired {
+		return nil, errPasswordResetRequired
+	}
+	return c, nil
+}
+
+func (s *Server) resolveCaller(r *http.Request) (*caller, error) {
+	if bearer, ok := bearerToken(r); ok {
+		if strings.HasPrefix(bearer, apiTokenPrefix) {
+			return s.authenticateAPIToken(r.Context(), bearer)
+		}
+		return s.authenticateJWT(r.Context(), bearer)
+	}
+
+	cookie, err := r.Cookie("authToken")
+	if err != nil {
+		return nil, err
+	}
+	return s.authenticateJWT(r.Context(), cookie.Value)
+}
+
+// authenticateSession is used by account management endpoints which personal access tokens can't use
+func (s *Server) authenticateSession(r *http.Request) (*caller, error) {
+	c, err := s.authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if c.isAPIToken() {
+		return nil, errNotASession
+	}
+	return c, nil
+}
+
+func (s *Server) authenticateJWT(ctx context.Context, tokenStr string) (*caller, error) {
+	token, err := jaegerjwt.ValidateToken(ctx, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("token is not valid")
+	}
+	email, ok := claims["email"].(string)
+	if !ok {
+		return nil, fmt.Errorf("email claim is missing or invalid")
+	}
+	jti, _ := claims["jti"].(string) // ValidateToken already made sure it's there
+
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return &caller{User: user, SessionID: jti}, nil
+}
+
+func (s *Server) authenticateAPIToken(ctx context.Context, token string) (*caller, error) {
+	email, tokenId, scopes, err := s.users.AuthenticateAPIToken(ctx, jaegerjwt.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return &caller{User: user, TokenID: tokenId, Scopes: scopes}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// clientIP returns the IP of the client without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type callerKey struct{}
+
+// requireAuth only lets authenticated callers with the scope through and puts the caller in the request context
+func (s *Server) requireAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enableCors(&w)
+
+		if r.Method == http.MethodOptions { // preflight requests don't carry credentials
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		c, err := s.authenticate(r)
+		if err != nil {
+			log.Printf("Unauthorized request to %s: %s", r.URL.Path, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !c.can(scope) {
+			log.Printf("API token %d is missing the %s scope for %s", c.TokenID, scope, r.URL.Path)
+			http.Error(w, "Token is missing the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	}
+}
+
+// requireAdmin only lets sessions of admins through, API tokens can't be used for the admin api
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enableCors(&w)
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		c, err := s.authenticateSession(r)
+		if err != nil {
+			log.Printf("Unauthorized request to %s: %s", r.URL.Path, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !c.isAdmin() {
+			log.Printf("User %d without admin role tried to access %s", c.User.ID, r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	}
+}
+
+// callerFromContext returns the caller put in the context by requireAuth or requireAdmin
+func callerFromContext(r *http.Request) *caller {
+	c, _ := r.Context().Value(callerKey{}).(*caller)
+	return c
+}
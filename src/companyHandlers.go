@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+type CompanyRequest struct {
+	Name     string `json:"name"`
+	Website  string `json:"website"`
+	Industry string `json:"industry"`
+	Size     string `json:"size"`
+	Location string `json:"location"`
+}
+
+type CompanyAliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// validate normalizes the request, the error message is meant for the client
+func (req *CompanyRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Website = strings.TrimSpace(req.Website)
+	req.Industry = strings.TrimSpace(req.Industry)
+	req.Size = strings.TrimSpace(req.Size)
+	req.Location = strings.TrimSpace(req.Location)
+
+	if req.Name == "" {
+		return errors.New("Name is required")
+	}
+	if req.Website != "" {
+		link, err := url.Parse(req.Website)
+		if err != nil || (link.Scheme != "https" && link.Scheme != "http") || link.Host == "" {
+			return errors.New("Website has to be an http(s) URL")
+		}
+	}
+	if req.Size != "" && !slices.Contains(jaegerdb.CompanySizes, req.Size) {
+		return fmt.Errorf("Size has to be one of %s", strings.Join(jaegerdb.CompanySizes, ", "))
+	}
+	return nil
+}
+
+func (req CompanyRequest) toCompany() jaegerdb.CompanyDB {
+	return jaegerdb.CompanyDB{
+		Name:     req.Name,
+		Website:  req.Website,
+		Industry: req.Industry,
+		Size:     req.Size,
+		Location: req.Location,
+	}
+}
+
+func (req *CompanyAliasRequest) validate() error {
+	req.Alias = strings.TrimSpace(req.Alias)
+	if req.Alias == "" {
+		return errors.New("Alias is required")
+	}
+	return nil
+}
+
+// handleCompanies serves GET and POST /api/companies
+func (s *Server) handleCompanies(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		companies, err := s.notes.GetCompanies(r.Context(), caller.User.ID)
+		if writeStoreError(w, err, "Failed retrieving companies", "Failed retrieving the companies of user %d", caller.User.ID) {
+			return
+		}
+		if companies == nil {
+			companies = []jaegerdb.CompanyDB{}
+		}
+		writeJSON(w, http.StatusOK, companies)
+
+	case http.MethodPost:
+		req, ok := decodeJSON[CompanyRequest](w, r, "company")
+		if !ok {
+			return
+		}
+		created, err := s.notes.CreateCompany(r.Context(), caller.User.ID, req.toCompany())
+		if writeStoreError(w, err, "Failed creating company", "Failed creating a company for user %d", caller.User.ID) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditCompanyCreate, "company:"+strconv.Itoa(created.ID), created.Name)
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCompany serves GET, PUT and DELETE /api/companies/{id}, GET includes the applications to the company and how they went
+func (s *Server) handleCompany(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	target := "company:" + strconv.Itoa(companyId)
+
+	switch r.Method {
+	case http.MethodGet:
+		company, err := s.notes.GetCompany(r.Context(), caller.User.ID, companyId)
+		if writeStoreError(w, err, "Failed retrieving company", "Failed retrieving company %d", companyId) {
+			return
+		}
+		writeJSON(w, http.StatusOK, company)
+
+	case http.MethodPut:
+		req, ok := decodeJSON[CompanyRequest](w, r, "company")
+		if !ok {
+			return
+		}
+		company := req.toCompany()
+		company.ID = companyId
+		updated, err := s.notes.UpdateCompany(r.Context(), caller.User.ID, company)
+		if writeStoreError(w, err, "Failed updating company", "Failed updating company %d", companyId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditCompanyUpdate, target, "")
+		writeJSON(w, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		err := s.notes.DeleteCompany(r.Context(), caller.User.ID, companyId)
+		if writeStoreError(w, err, "Failed deleting company", "Failed deleting company %d", companyId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditCompanyDelete, target, "")
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCompanyAliases serves POST /api/companies/{id}/aliases, an alias naming another company merges that company into this one
+func (s *Server) handleCompanyAliases(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	req, ok := decodeJSON[CompanyAliasRequest](w, r, "alias")
+	if !ok {
+		return
+	}
+
+	company, err := s.notes.AddCompanyAlias(r.Context(), caller.User.ID, companyId, req.Alias)
+	if writeStoreError(w, err, "Failed adding alias", "Failed adding an alias to company %d", companyId) {
+		return
+	}
+	s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditCompanyAliasAdd, "company:"+strconv.Itoa(companyId), req.Alias)
+	writeJSON(w, http.StatusOK, company)
+}
+
+// handleCompanyAlias serves DELETE /api/companies/{id}/aliases/{aliasId}
+func (s *Server) handleCompanyAlias(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	aliasId, err := strconv.Atoi(r.PathValue("aliasId"))
+	if err != nil {
+		http.Error(w, "Invalid alias ID format", http.StatusBadRequest)
+		return
+	}
+
+	err = s.notes.DeleteCompanyAlias(r.Context(), caller.User.ID, companyId, aliasId)
+	if writeStoreError(w, err, "Failed deleting alias", "Failed deleting alias %d of company %d", aliasId, companyId) {
+		return
+	}
+	s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditCompanyAliasDelete, "company:"+strconv.Itoa(companyId), "alias:"+strconv.Itoa(aliasId))
+	w.WriteHeader(http.StatusOK)
+}
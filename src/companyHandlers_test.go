@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// companies returns the caller's companies by name
+func (c *testClient) companies() map[string]jaegerdb.CompanyDB {
+	c.t.Helper()
+	byName := map[string]jaegerdb.CompanyDB{}
+	for _, company := range decodeResponse[[]jaegerdb.CompanyDB](c.t, c.expect(http.MethodGet, "/api/companies", nil, http.StatusOK)) {
+		byName[company.Name] = company
+	}
+	return byName
+}
+
+func TestCompanyNameMatching(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	// notes are filed under the company their name normalizes to, the first spelling names it
+	c.createNote("Google LLC")
+	c.createNote("google")
+	c.createNote("Google, Inc.")
+	c.createNote("Alphabet")
+
+	companies := c.companies()
+	if len(companies) != 2 || companies["Google LLC"].ApplicationCount != 3 || companies["Alphabet"].ApplicationCount != 1 {
+		t.Fatalf("companies = %+v, want Google LLC with 3 and Alphabet with 1 application", companies)
+	}
+
+	c.expect(http.MethodPost, "/api/companies", CompanyRequest{Name: "GOOGLE corp"}, http.StatusConflict)
+	c.expect(http.MethodPost, "/api/companies", CompanyRequest{Name: "..."}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/companies", CompanyRequest{Name: "Initech", Size: "huge"}, http.StatusBadRequest)
+	initech := decodeResponse[jaegerdb.CompanyDB](t, c.expect(http.MethodPost, "/api/companies", CompanyRequest{Name: " Initech ", Size: "51-200"}, http.StatusCreated))
+	if initech.Name != "Initech" {
+		t.Errorf("created company name = %q, want Initech", initech.Name)
+	}
+
+	// a note for a company that already exists joins it
+	c.createNote("Initech Inc.")
+	if count := c.companies()["Initech"].ApplicationCount; count != 1 {
+		t.Errorf("Initech has %d applications, want 1", count)
+	}
+}
+
+func TestCompanyAliasMerge(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	c.createNote("Google")
+	c.createNote("Alphabet Inc.")
+	c.createNote("Globex")
+	companies := c.companies()
+	google, alphabet, globex := companies["Google"], companies["Alphabet Inc."], companies["Globex"]
+	googlePath := "/api/companies/" + strconv.Itoa(google.ID)
+
+	c.expect(http.MethodPost, googlePath+"/aliases", CompanyAliasRequest{Alias: "  "}, http.StatusBadRequest)
+
+	// an alias that names another company merges it, with its applications
+	merged := decodeResponse[jaegerdb.CompanyDB](t, c.expect(http.MethodPost, googlePath+"/aliases", CompanyAliasRequest{Alias: "alphabet"}, http.StatusOK))
+	if merged.ApplicationCount != 2 || len(merged.Aliases) != 1 || merged.Aliases[0].Alias != "alphabet" {
+		t.Errorf("merged company = %+v, want 2 applications and the alias", merged)
+	}
+	c.expect(http.MethodGet, "/api/companies/"+strconv.Itoa(alphabet.ID), nil, http.StatusNotFound)
+	detail := decodeResponse[jaegerdb.CompanyDetailDB](t, c.expect(http.MethodGet, googlePath, nil, http.StatusOK))
+	if detail.Stats.Total != 2 || len(detail.Applications) != 2 {
+		t.Errorf("merged company has %d applications, want 2", len(detail.Applications))
+	}
+
+	// new notes under the alias are filed under the company
+	c.createNote("Alphabet LLC")
+	if count := c.companies()["Google"].ApplicationCount; count != 3 {
+		t.Errorf("Google has %d applications after a note under its alias, want 3", count)
+	}
+
+	// the alias of one company can't be taken by another
+	globexPath := "/api/companies/" + strconv.Itoa(globex.ID)
+	c.expect(http.MethodPost, globexPath+"/aliases", CompanyAliasRequest{Alias: "Alphabet"}, http.StatusConflict)
+	c.expect(http.MethodPut, globexPath, CompanyRequest{Name: "Alphabet"}, http.StatusConflict)
+
+	// companies with applications are merged instead of deleted
+	c.expect(http.MethodDelete, globexPath, nil, http.StatusConflict)
+
+	aliasId := "/aliases/" + strconv.Itoa(merged.Aliases[0].ID)
+	aliasPath := googlePath + aliasId
+	c.expect(http.MethodDelete, globexPath+aliasId, nil, http.StatusNotFound)
+	c.expect(http.MethodDelete, aliasPath, nil, http.StatusOK)
+	c.expect(http.MethodDelete, aliasPath, nil, http.StatusNotFound)
+}
+
+func TestCompaniesOwnership(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+
+	ada.createNote("Acme")
+	acme := ada.companies()["Acme"]
+	path := "/api/companies/" + strconv.Itoa(acme.ID)
+
+	bob.expect(http.MethodGet, path, nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, path, CompanyRequest{Name: "Hijacked"}, http.StatusNotFound)
+	bob.expect(http.MethodDelete, path, nil, http.StatusNotFound)
+	bob.expect(http.MethodPost, path+"/aliases", CompanyAliasRequest{Alias: "Hijacked"}, http.StatusNotFound)
+
+	// the same name is a different company for another user
+	bob.expect(http.MethodPost, "/api/companies", CompanyRequest{Name: "Acme"}, http.StatusCreated)
+	if len(ada.companies()) != 1 {
+		t.Errorf("bob's company shows up for ada")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+type ContactRequest struct {
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	LinkedInURL string `json:"linkedinUrl"`
+	Notes       string `json:"notes"`
+}
+
+// validate normalizes the request, the error message is meant for the client
+func (req *ContactRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Role = strings.TrimSpace(req.Role)
+	req.Email = strings.TrimSpace(req.Email)
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.LinkedInURL = strings.TrimSpace(req.LinkedInURL)
+
+	if req.Name == "" {
+		return errors.New("Name is required")
+	}
+	if req.Email != "" {
+		if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+			return errors.New("Email is invalid")
+		}
+	}
+	if req.LinkedInURL != "" {
+		link, err := url.Parse(req.LinkedInURL)
+		if err != nil || (link.Scheme != "https" && link.Scheme != "http") || link.Host == "" {
+			return errors.New("LinkedIn URL has to be an http(s) URL")
+		}
+	}
+	return nil
+}
+
+func (req ContactRequest) toContact() jaegerdb.ContactDB {
+	return jaegerdb.ContactDB{
+		Name:        req.Name,
+		Role:        req.Role,
+		Email:       req.Email,
+		Phone:       req.Phone,
+		LinkedInURL: req.LinkedInURL,
+		Notes:       req.Notes,
+	}
+}
+
+// handleContacts serves GET and POST /api/contacts
+func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		contacts, err := s.notes.GetContacts(r.Context(), caller.User.ID)
+		if writeStoreError(w, err, "Failed retrieving contacts", "Failed retrieving the contacts of user %d", caller.User.ID) {
+			return
+		}
+		if contacts == nil {
+			contacts = []jaegerdb.ContactDB{}
+		}
+		writeJSON(w, http.StatusOK, contacts)
+
+	case http.MethodPost:
+		req, ok := decodeJSON[ContactRequest](w, r, "contact")
+		if !ok {
+			return
+		}
+		created, err := s.notes.CreateContact(r.Context(), caller.User.ID, req.toContact())
+		if writeStoreError(w, err, "Failed creating contact", "Failed creating a contact for user %d", caller.User.ID) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditContactCreate, "contact:"+strconv.Itoa(created.ID), created.Name)
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleContact serves GET, PUT and DELETE /api/contacts/{id}
+func (s *Server) handleContact(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	contactId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	target := "contact:" + strconv.Itoa(contactId)
+
+	switch r.Method {
+	case http.MethodGet:
+		contact, err := s.notes.GetContact(r.Context(), caller.User.ID, contactId)
+		if writeStoreError(w, err, "Failed retrieving contact", "Failed retrieving contact %d", contactId) {
+			return
+		}
+		writeJSON(w, http.StatusOK, contact)
+
+	case http.MethodPut:
+		req, ok := decodeJSON[ContactRequest](w, r, "contact")
+		if !ok {
+			return
+		}
+		contact := req.toContact()
+		contact.ID = contactId
+		updated, err := s.notes.UpdateContact(r.Context(), caller.User.ID, contact)
+		if writeStoreError(w, err, "Failed updating contact", "Failed updating contact %d", contactId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditContactUpdate, target, "")
+		writeJSON(w, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		err := s.notes.DeleteContact(r.Context(), caller.User.ID, contactId)
+		if writeStoreError(w, err, "Failed deleting contact", "Failed deleting contact %d", contactId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditContactDelete, target, "")
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleContactNotes serves GET /api/contacts/{id}/notes, the applications the contact is linked to
+func (s *Server) handleContactNotes(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contactId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	notes, err := s.notes.GetContactNotes(r.Context(), caller.User.ID, contactId)
+	if writeStoreError(w, err, "Failed retrieving notes", "Failed retrieving the notes of contact %d", contactId) {
+		return
+	}
+	if notes == nil {
+		notes = []jaegerdb.NoteDB{}
+	}
+	writeJSON(w, http.StatusOK, notes)
+}
+
+// handleNoteContacts serves GET /api/notes/{id}/contacts
+func (s *Server) handleNoteContacts(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	noteId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	contacts, err := s.notes.GetNoteContacts(r.Context(), caller.User.ID, noteId)
+	if writeStoreError(w, err, "Failed retrieving contacts", "Failed retrieving the contacts of note %d", noteId) {
+		return
+	}
+	if contacts == nil {
+		contacts = []jaegerdb.ContactDB{}
+	}
+	writeJSON(w, http.StatusOK, contacts)
+}
+
+// handleNoteContact serves PUT and DELETE /api/notes/{id}/contacts/{contactId}, linking and unlinking the contact
+func (s *Server) handleNoteContact(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	noteId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	contactId, err := strconv.Atoi(r.PathValue("contactId"))
+	if err != nil {
+		http.Error(w, "Invalid contact ID format", http.StatusBadRequest)
+		return
+	}
+	target := "contact:" + strconv.Itoa(contactId)
+	details := "note:" + strconv.Itoa(noteId)
+
+	switch r.Method {
+	case http.MethodPut:
+		err := s.notes.LinkContact(r.Context(), caller.User.ID, noteId, contactId)
+		if writeStoreError(w, err, "Failed linking contact", "Failed linking contact %d to note %d", contactId, noteId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditContactLink, target, details)
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodDelete:
+		err := s.notes.UnlinkContact(r.Context(), caller.User.ID, noteId, contactId)
+		if errors.Is(err, jaegerdb.ErrContactNotFound) {
+			http.Error(w, "Contact is not linked to the note", http.StatusNotFound)
+			return
+		}
+		if writeStoreError(w, err, "Failed unlinking contact", "Failed unlinking contact %d from note %d", contactId, noteId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditContactUnlink, target, details)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// createContact adds a contact with the name to the client's address book
+func (c *testClient) createContact(name string) jaegerdb.ContactDB {
+	c.t.Helper()
+	return decodeResponse[jaegerdb.ContactDB](c.t, c.expect(http.MethodPost, "/api/contacts", ContactRequest{Name: name}, http.StatusCreated))
+}
+
+func TestCreateContact(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	for _, req := range []ContactRequest{
+		{Name: "  ", Email: "grace@example.com"},
+		{Name: "Grace", Email: "Grace <grace@example.com>"},
+		{Name: "Grace", Email: "grace"},
+		{Name: "Grace", LinkedInURL: "ftp://linkedin.com/in/grace"},
+		{Name: "Grace", LinkedInURL: "https://"},
+	} {
+		c.expect(http.MethodPost, "/api/contacts", req, http.StatusBadRequest)
+	}
+
+	// fields are trimmed, the free text notes are kept as written
+	created := decodeResponse[jaegerdb.ContactDB](t, c.expect(http.MethodPost, "/api/contacts", ContactRequest{Name: " Grace Hopper ", Email: " grace@example.com ",
+		LinkedInURL: "https://www.linkedin.com/in/grace", Notes: " met at the meetup "}, http.StatusCreated))
+	if created.Name != "Grace Hopper" || created.Email != "grace@example.com" || created.LinkedInURL != "https://www.linkedin.com/in/grace" || created.Notes != " met at the meetup " {
+		t.Errorf("created contact = %+v", created)
+	}
+	if list := decodeResponse[[]jaegerdb.ContactDB](t, c.expect(http.MethodGet, "/api/contacts", nil, http.StatusOK)); len(list) != 1 || list[0].ID != created.ID {
+		t.Errorf("contacts = %+v, want only %d", list, created.ID)
+	}
+}
+
+func TestLinkContacts(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+
+	adaNote, adaContact := ada.createNote("Acme"), ada.createContact("Grace")
+	bobNote, bobContact := bob.createNote("Initech"), bob.createContact("Alan")
+	link := func(noteId, contactId int) string {
+		return "/api/notes/" + strconv.Itoa(noteId) + "/contacts/" + strconv.Itoa(contactId)
+	}
+
+	// both the note and the contact have to be the caller's
+	tests := []struct {
+		name   string
+		c      *testClient
+		path   string
+		status int
+	}{
+		{"own contact to someone else's note", ada, link(bobNote.Id, adaContact.ID), http.StatusNotFound},
+		{"someone else's contact to own note", ada, link(adaNote.Id, bobContact.ID), http.StatusNotFound},
+		{"someone else's contact to someone else's note", bob, link(adaNote.Id, adaContact.ID), http.StatusNotFound},
+		{"own contact to own note", ada, link(adaNote.Id, adaContact.ID), http.StatusOK},
+		{"linked again", ada, link(adaNote.Id, adaContact.ID), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.t = t
+			tt.c.expect(http.MethodPut, tt.path, nil, tt.status)
+		})
+	}
+	ada.t, bob.t = t, t
+
+	if got := decodeResponse[[]jaegerdb.ContactDB](t, bob.expect(http.MethodGet, "/api/notes/"+strconv.Itoa(bobNote.Id)+"/contacts", nil, http.StatusOK)); len(got) != 0 {
+		t.Errorf("contacts of bob's note = %+v, want none", got)
+	}
+	if got := decodeResponse[[]jaegerdb.NoteDB](t, ada.expect(http.MethodGet, "/api/contacts/"+strconv.Itoa(adaContact.ID)+"/notes", nil, http.StatusOK)); len(got) != 1 || got[0].Id != adaNote.Id {
+		t.Errorf("notes of ada's contact = %+v, want only %d", got, adaNote.Id)
+	}
+
+	bob.expect(http.MethodDelete, link(adaNote.Id, adaContact.ID), nil, http.StatusNotFound)
+	ada.expect(http.MethodDelete, link(adaNote.Id, adaContact.ID), nil, http.StatusOK)
+	ada.expect(http.MethodDelete, link(adaNote.Id, adaContact.ID), nil, http.StatusNotFound)
+}
+
+func TestContactLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+
+	contact := ada.createContact("Grace")
+	note := ada.createNote("Acme")
+	path := "/api/contacts/" + strconv.Itoa(contact.ID)
+	ada.expect(http.MethodPut, "/api/notes/"+strconv.Itoa(note.Id)+"/contacts/"+strconv.Itoa(contact.ID), nil, http.StatusOK)
+
+	// other users' contacts don't exist for them
+	bob.expect(http.MethodGet, path, nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, path, ContactRequest{Name: "Hijacked"}, http.StatusNotFound)
+	bob.expect(http.MethodDelete, path, nil, http.StatusNotFound)
+	bob.expect(http.MethodGet, path+"/notes", nil, http.StatusNotFound)
+
+	ada.expect(http.MethodPut, path, ContactRequest{Name: "Grace", Email: "grace"}, http.StatusBadRequest)
+	ada.expect(http.MethodPut, path, ContactRequest{Name: "Grace Hopper", Role: "Recruiter"}, http.StatusOK)
+	if got := decodeResponse[jaegerdb.ContactDB](t, ada.expect(http.MethodGet, path, nil, http.StatusOK)); got.Name != "Grace Hopper" || got.Role != "Recruiter" {
+		t.Errorf("updated contact = %+v", got)
+	}
+
+	// deleting the contact takes its links with it, the note stays
+	ada.expect(http.MethodDelete, path, nil, http.StatusOK)
+	ada.expect(http.MethodGet, path, nil, http.StatusNotFound)
+	if got := decodeResponse[[]jaegerdb.ContactDB](t, ada.expect(http.MethodGet, "/api/notes/"+strconv.Itoa(note.Id)+"/contacts", nil, http.StatusOK)); len(got) != 0 {
+		t.Errorf("contacts of the note after the delete = %+v, want none", got)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+// double submit CSRF protection: the token is set in a cookie and handed to the frontend in the X-CSRF-Token
+// response header, state changing requests authenticated by cookies have to send it back in the X-CSRF-Token header.
+// A cross site request carries the cookie but can't read or set the header.
+const (
+	csrfCookieName = "csrfToken"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// endpoints that are used before there is a session, a stale cookie shouldn't stop the user from logging in again
+var csrfExemptPrefixes = []string{
+	"/api/users/signup",
+	"/api/users/login/",
+	"/api/v2/users/login",
+	"/api/users/password/forgot",
+	"/api/users/password/reset",
+}
+
+// csrfProtect rejects unsafe requests carrying session cookies without the matching CSRF header,
+// requests authenticated with a bearer token don't send cookies implicitly and don't need it
+func csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if _, ok := bearerToken(r); ok || !hasSessionCookie(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range csrfExemptPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		cookie, err := r.Cookie(csrfCookieName)
+		header := r.Header.Get(csrfHeaderName)
+		if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+			log.Printf("CSRF check failed for %s %s", r.Method, r.URL.Path)
+			enableCors(&w)
+			http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasSessionCookie(r *http.Request) bool {
+	for _, name := range []string{"authToken", "refreshToken"} {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// issueCSRFToken sets a new token, called whenever a session starts
+func issueCSRFToken(w http.ResponseWriter) error {
+	token, _, err := jaegerjwt.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	setCSRFToken(w, token)
+	return nil
+}
+
+// ensureCSRFToken hands the existing token to the frontend again, or issues one for sessions started before CSRF protection
+func ensureCSRFToken(w http.ResponseWriter, r *http.Request) error {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		setCSRFToken(w, cookie.Value)
+		return nil
+	}
+	return issueCSRFToken(w)
+}
+
+// the cookie lives as long as the refresh token, the frontend can't read it so the token is also sent as a header
+func setCSRFToken(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		Expires:  time.Now().Add(jaegerjwt.RefreshTokenDuration),
+		MaxAge:   int(jaegerjwt.RefreshTokenDuration.Seconds()),
+	})
+	w.Header().Set(csrfHeaderName, token)
+}
+
+func deleteCSRFCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		MaxAge:   -1,
+	})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones of interviews have to load on hosts without zoneinfo
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+const (
+	defaultUpcomingDays = 14
+	maxUpcomingDays     = 365
+)
+
+type InterviewRequest struct {
+	Round        string   `json:"round"`
+	Type         string   `json:"type"`
+	StartsAt     string   `json:"startsAt"` // RFC 3339, or a local time like 2026-10-20T14:30 in timeZone
+	EndsAt       string   `json:"endsAt,omitempty"`
+	TimeZone     string   `json:"timeZone"`
+	Location     string   `json:"location"`
+	VideoLink    string   `json:"videoLink"`
+	Interviewers []string `json:"interviewers"`
+	Outcome      string   `json:"outcome,omitempty"`
+	PrepNotes    string   `json:"prepNotes"`
+
+	// set by validate
+	startsAt time.Time
+	endsAt   *time.Time
+}
+
+// parseInterviewTime takes times with an offset as they are and local times as times in loc
+func parseInterviewTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// validate normalizes the request and parses its times, the error message is meant for the client
+func (req *InterviewRequest) validate() error {
+	req.Round = strings.TrimSpace(req.Round)
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	req.TimeZone = strings.TrimSpace(req.TimeZone)
+	req.Location = strings.TrimSpace(req.Location)
+	req.VideoLink = strings.TrimSpace(req.VideoLink)
+	req.Outcome = strings.ToLower(strings.TrimSpace(req.Outcome))
+
+	if req.Round == "" {
+		return errors.New("Round is required")
+	}
+	if !slices.Contains(jaegerdb.InterviewTypes, req.Type) {
+		return fmt.Errorf("Type has to be one of %s", strings.Join(jaegerdb.InterviewTypes, ", "))
+	}
+	if req.Outcome == "" {
+		req.Outcome = jaegerdb.OutcomePending
+	}
+	if !slices.Contains(jaegerdb.InterviewOutcomes, req.Outcome) {
+		return fmt.Errorf("Outcome has to be one of %s", strings.Join(jaegerdb.InterviewOutcomes, ", "))
+	}
+
+	if req.TimeZone == "" {
+		req.TimeZone = "UTC"
+	}
+	loc, err := time.LoadLocation(req.TimeZone)
+	if err != nil {
+		return fmt.Errorf("Unknown time zone %q", req.TimeZone)
+	}
+	if req.startsAt, err = parseInterviewTime(req.StartsAt, loc); err != nil {
+		return errors.New("Start time is missing or invalid")
+	}
+	req.endsAt = nil
+	if req.EndsAt != "" {
+		endsAt, err := parseInterviewTime(req.EndsAt, loc)
+		if err != nil {
+			return errors.New("End time is invalid")
+		}
+		if !endsAt.After(req.startsAt) {
+			return errors.New("The interview has to end after it starts")
+		}
+		req.endsAt = &endsAt
+	}
+
+	if req.VideoLink != "" {
+		link, err := url.Parse(req.VideoLink)
+		if err != nil || (link.Scheme != "https" && link.Scheme != "http") || link.Host == "" {
+			return errors.New("Video link has to be an http(s) URL")
+		}
+	}
+
+	interviewers := []string{}
+	for _, name := range req.Interviewers {
+		name = strings.Join(strings.Fields(name), " ") // they are stored one per line
+		if name != "" {
+			interviewers = append(interviewers, name)
+		}
+	}
+	req.Interviewers = interviewers
+	return nil
+}
+
+// toInterview is only meaningful after validate
+func (req InterviewRequest) toInterview() jaegerdb.InterviewDB {
+	return jaegerdb.InterviewDB{
+		Round:        req.Round,
+		Type:         req.Type,
+		StartsAt:     req.startsAt,
+		EndsAt:       req.endsAt,
+		TimeZone:     req.TimeZone,
+		Location:     req.Location,
+		VideoLink:    req.VideoLink,
+		Interviewers: req.Interviewers,
+		Outcome:      req.Outcome,
+		PrepNotes:    req.PrepNotes,
+	}
+}
+
+// handleNoteInterviews serves GET and POST /api/notes/{id}/interviews
+func (s *Server) handleNoteInterviews(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	noteId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		interviews, err := s.notes.GetNoteInterviews(r.Context(), caller.User.ID, noteId)
+		if writeStoreError(w, err, "Failed retrieving interviews", "Failed retrieving the interviews of note %d", noteId) {
+			return
+		}
+		if interviews == nil {
+			interviews = []jaegerdb.InterviewDB{}
+		}
+		writeJSON(w, http.StatusOK, interviews)
+
+	case http.MethodPost:
+		req, ok := decodeJSON[InterviewRequest](w, r, "interview")
+		if !ok {
+			return
+		}
+		created, err := s.notes.CreateInterview(r.Context(), caller.User.ID, noteId, req.toInterview())
+		if writeStoreError(w, err, "Failed creating interview", "Failed creating an interview for note %d", noteId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditInterviewCreate, "interview:"+strconv.Itoa(created.ID), "note:"+strconv.Itoa(noteId))
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleNoteInterview serves GET, PUT and DELETE /api/notes/{id}/interviews/{interviewId}
+func (s *Server) handleNoteInterview(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	noteId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	interviewId, err := strconv.Atoi(r.PathValue("interviewId"))
+	if err != nil {
+		http.Error(w, "Invalid interview ID format", http.StatusBadRequest)
+		return
+	}
+	target := "interview:" + strconv.Itoa(interviewId)
+
+	switch r.Method {
+	case http.MethodGet:
+		interview, err := s.notes.GetInterview(r.Context(), caller.User.ID, noteId, interviewId)
+		if writeStoreError(w, err, "Failed retrieving interview", "Failed retrieving interview %d", interviewId) {
+			return
+		}
+		writeJSON(w, http.StatusOK, interview)
+
+	case http.MethodPut:
+		req, ok := decodeJSON[InterviewRequest](w, r, "interview")
+		if !ok {
+			return
+		}
+		interview := req.toInterview()
+		interview.ID = interviewId
+		updated, err := s.notes.UpdateInterview(r.Context(), caller.User.ID, noteId, interview)
+		if writeStoreError(w, err, "Failed updating interview", "Failed updating interview %d", interviewId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditInterviewUpdate, target, "")
+		writeJSON(w, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		err := s.notes.DeleteInterview(r.Context(), caller.User.ID, noteId, interviewId)
+		if writeStoreError(w, err, "Failed deleting interview", "Failed deleting interview %d", interviewId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditInterviewDelete, target, "")
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUpcomingInterviews serves GET /api/interviews/upcoming?days=N, the interviews of all notes in the next N days
+func (s *Server) handleUpcomingInterviews(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := defaultUpcomingDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxUpcomingDays {
+			http.Error(w, fmt.Sprintf("days has to be between 1 and %d", maxUpcomingDays), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	now := time.Now()
+	interviews, err := s.notes.GetUpcomingInterviews(r.Context(), caller.User.ID, now, now.AddDate(0, 0, days))
+	if writeStoreError(w, err, "Failed retrieving upcoming interviews", "Failed retrieving upcoming interviews of user %d", caller.User.ID) {
+		return
+	}
+	if interviews == nil {
+		interviews = []jaegerdb.UpcomingInterviewDB{}
+	}
+	writeJSON(w, http.StatusOK, interviews)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// createInterview adds an interview starting at startsAt to the note
+func (c *testClient) createInterview(noteId int, startsAt time.Time, outcome string) jaegerdb.InterviewDB {
+	c.t.Helper()
+	req := InterviewRequest{Round: "Round", Type: jaegerdb.InterviewVideo, StartsAt: startsAt.Format(time.RFC3339), Outcome: outcome}
+	return decodeResponse[jaegerdb.InterviewDB](c.t, c.expect(http.MethodPost, "/api/notes/"+strconv.Itoa(noteId)+"/interviews", req, http.StatusCreated))
+}
+
+func TestInterviewTimes(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	path := "/api/notes/" + strconv.Itoa(c.createNote("Acme").Id) + "/interviews"
+
+	// local times are read in the interview's time zone, times with an offset keep it
+	tests := []struct {
+		name     string
+		startsAt string
+		endsAt   string
+		timeZone string
+		status   int
+		startUTC string
+	}{
+		{"offset", "2026-10-20T14:30:00+02:00", "", "", http.StatusCreated, "2026-10-20T12:30:00Z"},
+		{"offset wins over the zone", "2026-10-20T14:30:00+02:00", "", "Asia/Tokyo", http.StatusCreated, "2026-10-20T12:30:00Z"},
+		{"local time in the zone", "2026-10-20T14:30", "", "America/New_York", http.StatusCreated, "2026-10-20T18:30:00Z"},
+		{"local time after the DST change", "2026-11-02T14:30", "", "America/New_York", http.StatusCreated, "2026-11-02T19:30:00Z"},
+		{"local time without a zone is UTC", "2026-10-20 14:30", "", "", http.StatusCreated, "2026-10-20T14:30:00Z"},
+		{"ends in the zone", "2026-10-20T09:00", "2026-10-20T12:00", "Europe/Berlin", http.StatusCreated, "2026-10-20T07:00:00Z"},
+		{"ends before it starts", "2026-10-20T14:30", "2026-10-20T14:00", "", http.StatusBadRequest, ""},
+		{"ends when it starts", "2026-10-20T14:30", "2026-10-20T14:30", "", http.StatusBadRequest, ""},
+		{"end earlier in another zone", "2026-10-20T14:30:00+02:00", "2026-10-20T13:00:00+00:00", "", http.StatusCreated, "2026-10-20T12:30:00Z"},
+		{"no start", "", "", "", http.StatusBadRequest, ""},
+		{"unknown time zone", "2026-10-20T14:30", "", "Mars/Olympus", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.t = t
+			rec := c.expect(http.MethodPost, path, InterviewRequest{Round: "Screen", Type: jaegerdb.InterviewPhone, StartsAt: tt.startsAt, EndsAt: tt.endsAt, TimeZone: tt.timeZone}, tt.status)
+			if tt.status != http.StatusCreated {
+				return
+			}
+			interview := decodeResponse[jaegerdb.InterviewDB](t, rec)
+			if got := interview.StartsAt.UTC().Format(time.RFC3339); got != tt.startUTC {
+				t.Errorf("starts at %s, want %s", got, tt.startUTC)
+			}
+			if tt.endsAt != "" && (interview.EndsAt == nil || !interview.EndsAt.After(interview.StartsAt)) {
+				t.Errorf("ends at %v, starts at %v", interview.EndsAt, interview.StartsAt)
+			}
+		})
+	}
+}
+
+func TestInterviewLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+	path := "/api/notes/" + strconv.Itoa(ada.createNote("Acme").Id) + "/interviews"
+
+	for _, req := range []InterviewRequest{
+		{Round: "  ", Type: jaegerdb.InterviewPhone, StartsAt: "2026-10-20T14:30"},
+		{Round: "Screen", Type: "chat", StartsAt: "2026-10-20T14:30"},
+		{Round: "Screen", Type: jaegerdb.InterviewPhone, StartsAt: "2026-10-20T14:30", Outcome: "ghosted"},
+		{Round: "Screen", Type: jaegerdb.InterviewVideo, StartsAt: "2026-10-20T14:30", VideoLink: "javascript:alert(1)"},
+	} {
+		ada.expect(http.MethodPost, path, req, http.StatusBadRequest)
+	}
+
+	// names are stored one per line, whitespace inside them is collapsed and empty ones dropped
+	created := decodeResponse[jaegerdb.InterviewDB](t, ada.expect(http.MethodPost, path, InterviewRequest{Round: " Onsite ", Type: "Onsite", StartsAt: "2026-10-20T09:00",
+		Interviewers: []string{" Grace   Hopper ", "", "Alan\nTuring"}}, http.StatusCreated))
+	if created.Round != "Onsite" || created.Type != jaegerdb.InterviewOnsite || created.Outcome != jaegerdb.OutcomePending || created.TimeZone != "UTC" {
+		t.Errorf("created interview = %+v", created)
+	}
+	if len(created.Interviewers) != 2 || created.Interviewers[0] != "Grace Hopper" || created.Interviewers[1] != "Alan Turing" {
+		t.Errorf("interviewers = %q", created.Interviewers)
+	}
+	interviewPath := path + "/" + strconv.Itoa(created.ID)
+
+	// the note is ada's, to bob it doesn't exist
+	hijack := InterviewRequest{Round: "Hijacked", Type: jaegerdb.InterviewPhone, StartsAt: "2026-10-20T14:30"}
+	bob.expect(http.MethodGet, path, nil, http.StatusNotFound)
+	bob.expect(http.MethodPost, path, hijack, http.StatusNotFound)
+	bob.expect(http.MethodGet, interviewPath, nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, interviewPath, hijack, http.StatusNotFound)
+	bob.expect(http.MethodDelete, interviewPath, nil, http.StatusNotFound)
+
+	ada.expect(http.MethodPut, interviewPath, InterviewRequest{Round: "Onsite", Type: jaegerdb.InterviewOnsite, StartsAt: "2026-10-20T09:00", EndsAt: "2026-10-20T08:00"}, http.StatusBadRequest)
+	ada.expect(http.MethodPut, interviewPath, InterviewRequest{Round: "Onsite", Type: jaegerdb.InterviewOnsite, StartsAt: "2026-10-20T09:00", Outcome: jaegerdb.OutcomePassed}, http.StatusOK)
+	list := decodeResponse[[]jaegerdb.InterviewDB](t, ada.expect(http.MethodGet, path, nil, http.StatusOK))
+	if len(list) != 1 || list[0].Outcome != jaegerdb.OutcomePassed || list[0].Round != "Onsite" {
+		t.Errorf("interviews after the update = %+v", list)
+	}
+
+	ada.expect(http.MethodDelete, interviewPath, nil, http.StatusOK)
+	ada.expect(http.MethodGet, interviewPath, nil, http.StatusNotFound)
+}
+
+func TestUpcomingInterviews(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+
+	acme := ada.createNote("Acme")
+	globex := ada.createNote("Globex")
+	now := time.Now().Truncate(time.Second)
+
+	ada.createInterview(acme.Id, now.Add(-time.Hour), "") // already over
+	soon := ada.createInterview(globex.Id, now.Add(time.Hour), "")
+	later := ada.createInterview(acme.Id, now.AddDate(0, 0, 3), "")
+	ada.createInterview(acme.Id, now.Add(2*time.Hour), jaegerdb.OutcomeCancelled)
+	farOff := ada.createInterview(acme.Id, now.AddDate(0, 0, 20), "")
+	bob.createInterview(bob.createNote("Initech").Id, now.Add(time.Hour), "")
+
+	// two weeks by default, soonest first, without cancelled interviews or other users' ones
+	if got := ada.upcoming(""); len(got) != 2 || got[0].ID != soon.ID || got[1].ID != later.ID {
+		t.Errorf("upcoming = %v, want [%d %d]", got, soon.ID, later.ID)
+	}
+	if got := ada.upcoming("?days=1"); len(got) != 1 || got[0].ID != soon.ID {
+		t.Errorf("upcoming in a day = %v, want [%d]", got, soon.ID)
+	}
+	if got := ada.upcoming("?days=30"); len(got) != 3 || got[2].ID != farOff.ID {
+		t.Errorf("upcoming in 30 days = %v, want [%d %d %d]", got, soon.ID, later.ID, farOff.ID)
+	}
+	if first := ada.upcoming("")[0]; first.CompanyName != "Globex" || first.Position != "Engineer" {
+		t.Errorf("upcoming interview is at %q as %q, want Globex as Engineer", first.CompanyName, first.Position)
+	}
+
+	for _, days := range []string{"0", "-1", "366", "soon"} {
+		ada.expect(http.MethodGet, "/api/interviews/upcoming?days="+days, nil, http.StatusBadRequest)
+	}
+}
+
+func TestUpcomingInterviewsTimeZones(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	path := "/api/notes/" + strconv.Itoa(c.createNote("Acme").Id) + "/interviews"
+	now := time.Now().Truncate(time.Minute)
+
+	// the window is in absolute time, read as UTC these local times would land on the wrong side of it
+	local := func(at time.Time, zone string) jaegerdb.InterviewDB {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := InterviewRequest{Round: "Screen", Type: jaegerdb.InterviewPhone, StartsAt: at.In(loc).Format("2006-01-02T15:04"), TimeZone: zone}
+		return decodeResponse[jaegerdb.InterviewDB](t, c.expect(http.MethodPost, path, req, http.StatusCreated))
+	}
+	inside := local(now.Add(20*time.Hour), "Pacific/Kiritimati") // UTC+14, 34 hours ahead on the wall clock
+	local(now.Add(30*time.Hour), "Pacific/Honolulu")             // UTC-10, 20 hours ahead on the wall clock
+
+	got := c.upcoming("?days=1")
+	if len(got) != 1 || got[0].ID != inside.ID {
+		t.Fatalf("upcoming in a day = %v, want only %d", got, inside.ID)
+	}
+	if !got[0].StartsAt.Equal(now.Add(20*time.Hour)) || got[0].TimeZone != "Pacific/Kiritimati" {
+		t.Errorf("upcoming interview starts %v in %s, want %v", got[0].StartsAt, got[0].TimeZone, now.Add(20*time.Hour))
+	}
+}
+
+// upcoming returns the client's upcoming interviews for the query
+func (c *testClient) upcoming(query string) []jaegerdb.UpcomingInterviewDB {
+	c.t.Helper()
+	return decodeResponse[[]jaegerdb.UpcomingInterviewDB](c.t, c.expect(http.MethodGet, "/api/interviews/upcoming"+query, nil, http.StatusOK))
+}
@@ -0,0 +1,100 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrAPITokenNotFound = errors.New("API token not found")
+	ErrAPITokenInvalid  = errors.New("API token is invalid, expired or revoked")
+)
+
+func CreateAPIToken(ctx context.Context, pool *pgxpool.Pool, userId int, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (APITokenDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	token := APITokenDB{Name: name, Prefix: prefix, Scopes: scopes, ExpiresAt: expiresAt}
+	err := pool.QueryRow(ctx, `INSERT INTO api_tokens (fk_user_id, name, token_prefix, token_hash, scopes, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, $6) RETURNING id, created_at`,
+		userId, name, prefix, tokenHash, strings.Join(scopes, " "), expiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		log.Printf("Unable to store the API token: %s", err)
+		return APITokenDB{}, err
+	}
+	return token, nil
+}
+
+// GetAPITokens lists the tokens of the user that weren't revoked
+func GetAPITokens(ctx context.Context, pool *pgxpool.Pool, userId int) ([]APITokenDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT id, name, token_prefix, scopes, created_at, last_used_at, expires_at
+	FROM api_tokens WHERE fk_user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APITokenDB
+	for rows.Next() {
+		var token APITokenDB
+		var scopes string
+		if err := rows.Scan(&token.ID, &token.Name, &token.Prefix, &scopes, &token.CreatedAt, &token.LastUsedAt, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func RevokeAPIToken(ctx context.Context, pool *pgxpool.Pool, userId, tokenId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cmdTag, err := pool.Exec(ctx, `UPDATE api_tokens SET revoked_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND fk_user_id = $2 AND revoked_at IS NULL`, tokenId, userId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIToken looks up a usable token by its hash, records that it was used
+// and returns the email of its owner together with the token id and scopes
+func AuthenticateAPIToken(ctx context.Context, pool *pgxpool.Pool, tokenHash string) (string, int, []string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var (
+		email   string
+		tokenId int
+		scopes  string
+	)
+	err := pool.QueryRow(ctx, `UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP
+	FROM users WHERE users.id = api_tokens.fk_user_id AND token_hash = $1 AND revoked_at IS NULL
+	AND (expires_at IS NULL OR expires_at > $2)
+	RETURNING users.email, api_tokens.id, api_tokens.scopes`, tokenHash, time.Now()).Scan(&email, &tokenId, &scopes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", 0, nil, ErrAPITokenInvalid
+	}
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return email, tokenId, strings.Fields(scopes), nil
+}
@@ -0,0 +1,97 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ScheduleUserDeletion soft deletes the user and ends all sessions, returns when the deletion was requested
+func ScheduleUserDeletion(ctx context.Context, pool *pgxpool.Pool, userId int) (time.Time, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return time.Time{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `UPDATE users SET deleted_at = COALESCE(deleted_at, CURRENT_TIMESTAMP)
+	WHERE id = $1 RETURNING deleted_at`, userId).Scan(&deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, ErrUserNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := revokeAllSessions(ctx, tx, userId); err != nil {
+		return time.Time{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return time.Time{}, err
+	}
+	return deletedAt, nil
+}
+
+// CancelUserDeletion restores a soft deleted user
+func CancelUserDeletion(ctx context.Context, pool *pgxpool.Pool, userId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := pool.Exec(ctx, `UPDATE users SET deleted_at = NULL WHERE id = $1`, userId)
+	return err
+}
+
+// PurgeDeletedUsers hard deletes the users soft deleted before the given time along with their notes,
+// everything else the user owns is removed by the ON DELETE CASCADE foreign keys
+func PurgeDeletedUsers(ctx context.Context, pool *pgxpool.Pool, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(ctx, `DELETE FROM notes WHERE fk_user_id IN
+	(SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1)`, deletedBefore); err != nil {
+		return 0, err
+	}
+	cmdTag, err := tx.Exec(ctx, `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return cmdTag.RowsAffected(), nil
+}
+
+// GetProfile returns the user's account data for the export
+func GetProfile(ctx context.Context, pool *pgxpool.Pool, userId int) (ProfileDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var profile ProfileDB
+	err := pool.QueryRow(ctx, `SELECT id, full_name, email, role, created_at, updated_at, verified_at, deleted_at
+	FROM users WHERE id = $1`, userId).Scan(&profile.ID, &profile.FullName, &profile.Email, &profile.Role,
+		&profile.CreatedAt, &profile.UpdatedAt, &profile.VerifiedAt, &profile.DeletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ProfileDB{}, ErrUserNotFound
+	}
+	if err != nil {
+		return ProfileDB{}, err
+	}
+	return profile, nil
+}
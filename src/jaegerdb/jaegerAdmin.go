@@ -0,0 +1,104 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+// GetUsersPage lists the users ordered by id along with the total number of users
+func GetUsersPage(ctx context.Context, pool *pgxpool.Pool, limit, offset int) ([]AdminUserDB, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var total int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := pool.Query(ctx, `SELECT id, full_name, email, role, verified_at IS NOT NULL, disabled_at, password_reset_required, created_at
+	FROM users ORDER BY id LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []AdminUserDB
+	for rows.Next() {
+		var user AdminUserDB
+		if err := rows.Scan(&user.ID, &user.FullName, &user.Email, &user.Role, &user.Verified, &user.DisabledAt, &user.PasswordResetRequired, &user.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// SetUserDisabled disables or re-enables the account, disabling also ends all of its sessions
+func SetUserDisabled(ctx context.Context, pool *pgxpool.Pool, userId int, disabled bool) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	cmdTag, err := tx.Exec(ctx, `UPDATE users SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, CURRENT_TIMESTAMP) END,
+	updated_at = CURRENT_TIMESTAMP WHERE id = $2`, disabled, userId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	if disabled {
+		if err := revokeAllSessions(ctx, tx, userId); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// RequirePasswordReset blocks password logins until the user resets the password and ends all sessions, returns the user's email
+func RequirePasswordReset(ctx context.Context, pool *pgxpool.Pool, userId int) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return "", err
+	}
+	defer tx.Rollback(context.Background())
+
+	var email string
+	err = tx.QueryRow(ctx, `UPDATE users SET password_reset_required = TRUE, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 RETURNING email`, userId).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := revokeAllSessions(ctx, tx, userId); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return email, nil
+}
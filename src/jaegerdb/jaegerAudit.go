@@ -0,0 +1,90 @@
+package jaegerdb
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// audit actions
+const (
+	AuditLogin              = "user.login"
+	AuditLogout             = "user.logout"
+	AuditUserUpdate         = "user.update"
+	AuditUserDelete         = "user.delete"
+	AuditNoteCreate         = "note.create"
+	AuditNoteUpdate         = "note.update"
+	AuditNoteDelete         = "note.delete"
+	AuditInterviewCreate    = "interview.create"
+	AuditInterviewUpdate    = "interview.update"
+	AuditInterviewDelete    = "interview.delete"
+	AuditContactCreate      = "contact.create"
+	AuditContactUpdate      = "contact.update"
+	AuditContactDelete      = "contact.delete"
+	AuditContactLink        = "contact.link"
+	AuditContactUnlink      = "contact.unlink"
+	AuditCompanyCreate      = "company.create"
+	AuditCompanyUpdate      = "company.update"
+	AuditCompanyDelete      = "company.delete"
+	AuditCompanyAliasAdd    = "company.alias_add"
+	AuditCompanyAliasDelete = "company.alias_delete"
+	AuditTagCreate          = "tag.create"
+	AuditTagUpdate          = "tag.update"
+	AuditTagDelete          = "tag.delete"
+	AuditNoteTag            = "note.tag"
+	AuditNoteUntag          = "note.untag"
+	AuditAdminDisable       = "admin.disable"
+	AuditAdminEnable        = "admin.enable"
+	AuditAdminPasswordReset = "admin.force_password_reset"
+)
+
+// RecordAuditEvent appends an event to the user's audit log, there is no way to change it afterwards
+func RecordAuditEvent(ctx context.Context, pool *pgxpool.Pool, userId, actorId int, action, target, details, ip, userAgent string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var actor *int // 0 when nobody in particular caused it
+	if actorId != 0 {
+		actor = &actorId
+	}
+	_, err := pool.Exec(ctx, `INSERT INTO audit_events (fk_user_id, actor_id, action, target, details, ip_address, user_agent, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)`, userId, actor, action, target, details, ip, userAgent)
+	if err != nil {
+		log.Printf("Unable to store the audit event: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetAuditEvents lists the user's events, newest first, along with the total number of events
+func GetAuditEvents(ctx context.Context, pool *pgxpool.Pool, userId, limit, offset int) ([]AuditEventDB, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var total int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_events WHERE fk_user_id = $1`, userId).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := pool.Query(ctx, `SELECT id, actor_id, action, target, details, ip_address, user_agent, created_at
+	FROM audit_events WHERE fk_user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`, userId, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []AuditEventDB
+	for rows.Next() {
+		var event AuditEventDB
+		if err := rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.Target, &event.Details, &event.IPAddress, &event.UserAgent, &event.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
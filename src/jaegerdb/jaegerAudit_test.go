@@ -0,0 +1,60 @@
+package jaegerdb
+
+import (
+	"testing"
+	"time"
+)
+
+// createSQLiteUser signs up a user in the store and returns their id
+func createSQLiteUser(t *testing.T, store *SQLiteStore, email string) int {
+	t.Helper()
+	if err := store.CreateUserJaeger(t.Context(), "Ada Lovelace", email, "correct horse battery staple"); err != nil {
+		t.Fatalf("creating %s: %s", email, err)
+	}
+	user, err := store.GetUserByEmail(t.Context(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func TestAuditEventsAppendOnly(t *testing.T) {
+	ctx := t.Context()
+	store := newSQLiteStore(t)
+	adaId := createSQLiteUser(t, store, "ada@example.com")
+	bobId := createSQLiteUser(t, store, "bob@example.com")
+	for _, id := range []int{adaId, bobId} {
+		if err := store.RecordAuditEvent(ctx, id, id, AuditLogin, "", "", "203.0.113.7", "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// someone with access to the database can't cover their tracks
+	for _, query := range []string{
+		`UPDATE audit_events SET ip_address = '198.51.100.1' WHERE fk_user_id = $1`,
+		`DELETE FROM audit_events WHERE fk_user_id = $1`,
+		`DELETE FROM audit_events WHERE fk_user_id <> $1`,
+	} {
+		if _, err := store.db.ExecContext(ctx, query, adaId); err == nil {
+			t.Errorf("%s succeeded", query)
+		}
+	}
+	if events, total, err := store.GetAuditEvents(ctx, adaId, 10, 0); err != nil || total != 1 || events[0].IPAddress != "203.0.113.7" {
+		t.Fatalf("events after the attempts = %+v, %d, %v", events, total, err)
+	}
+
+	// purging the account still takes its events with it
+	if _, err := store.ScheduleUserDeletion(ctx, adaId); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := store.PurgeDeletedUsers(ctx, time.Now().Add(time.Minute)); err != nil || purged != 1 {
+		t.Fatalf("purge = %d, %v", purged, err)
+	}
+	var left int
+	if err := store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_events WHERE fk_user_id = $1`, adaId).Scan(&left); err != nil || left != 0 {
+		t.Errorf("purged user has %d events left, %v", left, err)
+	}
+	if _, total, _ := store.GetAuditEvents(ctx, bobId, 10, 0); total != 1 {
+		t.Errorf("bob has %d events after ada's purge, want 1", total)
+	}
+}
@@ -0,0 +1,508 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrCompanyNotFound      = errors.New("company not found") // also returned for companies of other users
+	ErrCompanyExists        = errors.New("company name is already used by another company")
+	ErrCompanyHasNotes      = errors.New("company still has applications")
+	ErrCompanyAliasNotFound = errors.New("company alias not found")
+	ErrInvalidCompanyName   = errors.New("company name has no letters or digits")
+)
+
+// company sizes by number of employees
+var CompanySizes = []string{"1-10", "11-50", "51-200", "201-1000", "1001-5000", "5000+"}
+
+// legal forms are left out when matching names, "Google LLC" is the same company as "google"
+var companySuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "llc": true, "llp": true, "lp": true, "ltd": true, "limited": true,
+	"corp": true, "corporation": true, "co": true, "company": true, "plc": true, "pty": true,
+	"gmbh": true, "ag": true, "sa": true, "sarl": true, "srl": true, "bv": true, "nv": true, "ab": true, "oy": true, "as": true,
+	"doo": true, "ad": true,
+}
+
+// normalizeCompanyName returns the key company names are matched by, empty when there is nothing to match
+func normalizeCompanyName(name string) string {
+	name = strings.NewReplacer(".", "", "'", "", "’", "").Replace(strings.ToLower(name)) // so d.o.o. and Inc. stay one word
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for len(words) > 1 && companySuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// companyStats sums up the applications to a company, interviews is the number of interviews across all of them
+func companyStats(notes []NoteDB, interviews int) CompanyStatsDB {
+	stats := CompanyStatsDB{Total: len(notes), ByStatus: map[string]int{}, Interviews: interviews}
+	for _, note := range notes {
+		stats.ByStatus[note.ApplicationStatus]++
+		if len(NextStatuses(note.ApplicationStatus)) > 0 {
+			stats.Active++
+		}
+		switch note.ApplicationStatus {
+		case StatusOffer, StatusAccepted, StatusDeclined:
+			stats.Offers++
+		}
+		if stats.FirstAppliedOn == "" || note.AppliedOn < stats.FirstAppliedOn {
+			stats.FirstAppliedOn = note.AppliedOn
+		}
+		if note.AppliedOn > stats.LastAppliedOn {
+			stats.LastAppliedOn = note.AppliedOn
+		}
+	}
+	return stats
+}
+
+const companyColumns = `companies.id, companies.name, companies.website, companies.industry, companies.size, companies.location,
+	companies.created_at, companies.updated_at`
+
+func scanCompany(row pgx.Row, extra ...any) (CompanyDB, error) {
+	var company CompanyDB
+	dest := []any{&company.ID, &company.Name, &company.Website, &company.Industry, &company.Size, &company.Location,
+		&company.CreatedAt, &company.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	company.Aliases = []CompanyAliasDB{}
+	return company, err
+}
+
+// findCompany returns the id of the user's company with the normalized name or alias, 0 if there is none
+func findCompany(ctx context.Context, tx pgx.Tx, userId int, normalized string) (int, error) {
+	var id int
+	err := tx.QueryRow(ctx, `SELECT id FROM companies WHERE fk_user_id = $1 AND normalized_name = $2
+	UNION ALL SELECT fk_company_id FROM company_aliases WHERE fk_user_id = $1 AND normalized_alias = $2 LIMIT 1`, userId, normalized).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// resolveCompany returns the company a note with the company name belongs to, creating it when the name is new
+// notes whose name has nothing to match by get no company
+func resolveCompany(ctx context.Context, tx pgx.Tx, userId int, name string) (*int, error) {
+	normalized := normalizeCompanyName(name)
+	if normalized == "" {
+		return nil, nil
+	}
+	id, err := findCompany(ctx, tx, userId, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		err = tx.QueryRow(ctx, `INSERT INTO companies (fk_user_id, name, normalized_name, created_at, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (fk_user_id, normalized_name) DO UPDATE SET normalized_name = EXCLUDED.normalized_name RETURNING id`,
+			userId, strings.TrimSpace(name), normalized).Scan(&id)
+		if err != nil {
+			log.Printf("Unable to store company %q: %s", name, err)
+			return nil, err
+		}
+	}
+	return &id, nil
+}
+
+// assignCompanies links the user's notes without a company, notes from before companies existed and the ones an alias now matches
+func assignCompanies(ctx context.Context, tx pgx.Tx, userId int) error {
+	rows, err := tx.Query(ctx, `SELECT id, company_name FROM notes WHERE fk_user_id = $1 AND fk_company_id IS NULL`, userId)
+	if err != nil {
+		return err
+	}
+	names := map[int]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for noteId, name := range names {
+		companyId, err := resolveCompany(ctx, tx, userId, name)
+		if err != nil {
+			return err
+		}
+		if companyId == nil {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `UPDATE notes SET fk_company_id = $1 WHERE id = $2`, *companyId, noteId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// companyNameTaken reports whether another of the user's companies already goes by the normalized name
+func companyNameTaken(ctx context.Context, tx pgx.Tx, userId, companyId int, normalized string) (bool, error) {
+	id, err := findCompany(ctx, tx, userId, normalized)
+	return id != 0 && id != companyId, err
+}
+
+// CreateCompany adds a company, notes that go by its name are linked to it
+func CreateCompany(ctx context.Context, pool *pgxpool.Pool, userId int, company CompanyDB) (CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	normalized := normalizeCompanyName(company.Name)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return CompanyDB{}, err
+	}
+	defer tx.Rollback(context.Background()) // no-op after commit
+
+	if taken, err := companyNameTaken(ctx, tx, userId, 0, normalized); err != nil {
+		return CompanyDB{}, err
+	} else if taken {
+		return CompanyDB{}, ErrCompanyExists
+	}
+
+	var id int
+	err = tx.QueryRow(ctx, `INSERT INTO companies (fk_user_id, name, normalized_name, website, industry, size, location, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id`,
+		userId, company.Name, normalized, company.Website, company.Industry, company.Size, company.Location).Scan(&id)
+	if err != nil {
+		log.Printf("Unable to store the company: %s", err)
+		return CompanyDB{}, err
+	}
+	if err := assignCompanies(ctx, tx, userId); err != nil {
+		return CompanyDB{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return CompanyDB{}, err
+	}
+	return getCompany(ctx, pool, userId, id)
+}
+
+// GetCompanies lists the user's companies by name with their aliases and how many applications went to each
+func GetCompanies(ctx context.Context, pool *pgxpool.Pool, userId int) ([]CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return nil, err
+	}
+	defer tx.Rollback(context.Background()) // no-op after commit
+
+	if err := assignCompanies(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, `SELECT `+companyColumns+`, (SELECT COUNT(*) FROM notes WHERE notes.fk_company_id = companies.id)
+	FROM companies WHERE fk_user_id = $1 ORDER BY companies.normalized_name, companies.id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var companies []CompanyDB
+	for rows.Next() {
+		var applications int
+		company, err := scanCompany(rows, &applications)
+		if err != nil {
+			return nil, err
+		}
+		company.ApplicationCount = applications
+		companies = append(companies, company)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	byId := map[int]*CompanyDB{}
+	for i := range companies {
+		byId[companies[i].ID] = &companies[i]
+	}
+
+	aliases, err := pool.Query(ctx, `SELECT fk_company_id, id, alias FROM company_aliases WHERE fk_user_id = $1 ORDER BY id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer aliases.Close()
+
+	for aliases.Next() {
+		var companyId int
+		var alias CompanyAliasDB
+		if err := aliases.Scan(&companyId, &alias.ID, &alias.Alias); err != nil {
+			return nil, err
+		}
+		if company, ok := byId[companyId]; ok {
+			company.Aliases = append(company.Aliases, alias)
+		}
+	}
+	if err := aliases.Err(); err != nil {
+		return nil, err
+	}
+	return companies, nil
+}
+
+// getCompany reads the company with its aliases and number of applications
+func getCompany(ctx context.Context, pool *pgxpool.Pool, userId, companyId int) (CompanyDB, error) {
+	var applications int
+	company, err := scanCompany(pool.QueryRow(ctx, `SELECT `+companyColumns+`, (SELECT COUNT(*) FROM notes WHERE notes.fk_company_id = companies.id)
+	FROM companies WHERE id = $1 AND fk_user_id = $2`, companyId, userId), &applications)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+	if err != nil {
+		return CompanyDB{}, err
+	}
+	company.ApplicationCount = applications
+
+	rows, err := pool.Query(ctx, `SELECT id, alias FROM company_aliases WHERE fk_company_id = $1 ORDER BY id`, companyId)
+	if err != nil {
+		return CompanyDB{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias CompanyAliasDB
+		if err := rows.Scan(&alias.ID, &alias.Alias); err != nil {
+			return CompanyDB{}, err
+		}
+		company.Aliases = append(company.Aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return CompanyDB{}, err
+	}
+	return company, nil
+}
+
+// GetCompany returns the company with all of the user's applications to it, newest first, and how they went
+func GetCompany(ctx context.Context, pool *pgxpool.Pool, userId, companyId int) (CompanyDetailDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	company, err := getCompany(ctx, pool, userId, companyId)
+	if err != nil {
+		return CompanyDetailDB{}, err
+	}
+
+	rows, err := pool.Query(ctx, `SELECT id, note_id, company_name, "position", salary, application_status, applied_on, fk_user_id, updated_at, description
+	FROM notes WHERE fk_company_id = $1 AND fk_user_id = $2 ORDER BY applied_on DESC, id`, companyId, userId)
+	if err != nil {
+		return CompanyDetailDB{}, err
+	}
+	defer rows.Close()
+
+	notes := []NoteDB{}
+	for rows.Next() {
+		var note NoteDB
+		var appliedOn time.Time
+		var updatedAt time.Time
+
+		if err := rows.Scan(&note.Id, &note.Uuid, &note.CompanyName, &note.Position, &note.Salary, &note.ApplicationStatus, &appliedOn, &note.UserId, &updatedAt, &note.Description); err != nil {
+			return CompanyDetailDB{}, err
+		}
+		note.AppliedOn = appliedOn.Format("2006-01-02 15:04:05")
+		note.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
+
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return CompanyDetailDB{}, err
+	}
+
+	var interviews int
+	err = pool.QueryRow(ctx, `SELECT COUNT(*) FROM interviews JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE notes.fk_company_id = $1 AND notes.fk_user_id = $2`, companyId, userId).Scan(&interviews)
+	if err != nil {
+		return CompanyDetailDB{}, err
+	}
+
+	return CompanyDetailDB{CompanyDB: company, Stats: companyStats(notes, interviews), Applications: notes}, nil
+}
+
+// UpdateCompany replaces the company's fields, after a rename the old name stays on as an alias so notes keep matching it
+func UpdateCompany(ctx context.Context, pool *pgxpool.Pool, userId int, company CompanyDB) (CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	normalized := normalizeCompanyName(company.Name)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return CompanyDB{}, err
+	}
+	defer tx.Rollback(context.Background()) // no-op after commit
+
+	var oldName, oldNormalized string
+	err = tx.QueryRow(ctx, `SELECT name, normalized_name FROM companies WHERE id = $1 AND fk_user_id = $2 FOR UPDATE`,
+		company.ID, userId).Scan(&oldName, &oldNormalized)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+	if err != nil {
+		return CompanyDB{}, err
+	}
+
+	if normalized != oldNormalized {
+		if taken, err := companyNameTaken(ctx, tx, userId, company.ID, normalized); err != nil {
+			return CompanyDB{}, err
+		} else if taken {
+			return CompanyDB{}, ErrCompanyExists
+		}
+		// the new name may have been one of the aliases, the old one becomes one
+		if _, err := tx.Exec(ctx, `DELETE FROM company_aliases WHERE fk_company_id = $1 AND normalized_alias = $2`, company.ID, normalized); err != nil {
+			return CompanyDB{}, err
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO company_aliases (fk_company_id, fk_user_id, alias, normalized_alias, created_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)`, company.ID, userId, oldName, oldNormalized); err != nil {
+			return CompanyDB{}, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE companies SET name = $3, normalized_name = $4, website = $5, industry = $6, size = $7, location = $8,
+	updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND fk_user_id = $2`,
+		company.ID, userId, company.Name, normalized, company.Website, company.Industry, company.Size, company.Location)
+	if err != nil {
+		log.Printf("Unable to update company %d: %s", company.ID, err)
+		return CompanyDB{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return CompanyDB{}, err
+	}
+	return getCompany(ctx, pool, userId, company.ID)
+}
+
+// DeleteCompany removes a company without applications, ones with applications are merged into another company with an alias instead
+func DeleteCompany(ctx context.Context, pool *pgxpool.Pool, userId, companyId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists, hasNotes bool
+	err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM companies WHERE id = $1 AND fk_user_id = $2),
+	EXISTS (SELECT 1 FROM notes WHERE fk_company_id = $1)`, companyId, userId).Scan(&exists, &hasNotes)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrCompanyNotFound
+	}
+	if hasNotes {
+		return ErrCompanyHasNotes
+	}
+
+	result, err := pool.Exec(ctx, `DELETE FROM companies WHERE id = $1 AND fk_user_id = $2
+	AND NOT EXISTS (SELECT 1 FROM notes WHERE fk_company_id = $1)`, companyId, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrCompanyHasNotes // a note was linked in the meantime
+	}
+	return nil
+}
+
+// AddCompanyAlias lets notes match the company by another name
+// when the alias is the name of another of the user's companies, that company is merged into this one
+func AddCompanyAlias(ctx context.Context, pool *pgxpool.Pool, userId, companyId int, alias string) (CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	normalized := normalizeCompanyName(alias)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return CompanyDB{}, err
+	}
+	defer tx.Rollback(context.Background()) // no-op after commit
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM companies WHERE id = $1 AND fk_user_id = $2)`, companyId, userId).Scan(&exists); err != nil {
+		return CompanyDB{}, err
+	}
+	if !exists {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+
+	var otherId int
+	var otherIsAlias bool
+	err = tx.QueryRow(ctx, `SELECT id, FALSE FROM companies WHERE fk_user_id = $1 AND normalized_name = $2
+	UNION ALL SELECT fk_company_id, TRUE FROM company_aliases WHERE fk_user_id = $1 AND normalized_alias = $2 LIMIT 1`,
+		userId, normalized).Scan(&otherId, &otherIsAlias)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return CompanyDB{}, err
+	case otherId == companyId:
+		return getCompany(ctx, pool, userId, companyId) // the company already goes by that name
+	case otherIsAlias:
+		return CompanyDB{}, ErrCompanyExists
+	default:
+		if err := mergeCompany(ctx, tx, otherId, companyId); err != nil {
+			return CompanyDB{}, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO company_aliases (fk_company_id, fk_user_id, alias, normalized_alias, created_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)`, companyId, userId, alias, normalized)
+	if err != nil {
+		log.Printf("Unable to store alias %q of company %d: %s", alias, companyId, err)
+		return CompanyDB{}, err
+	}
+	if err := assignCompanies(ctx, tx, userId); err != nil {
+		return CompanyDB{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return CompanyDB{}, err
+	}
+	return getCompany(ctx, pool, userId, companyId)
+}
+
+// mergeCompany moves the notes and aliases of one company to another and deletes it
+func mergeCompany(ctx context.Context, tx pgx.Tx, fromId, intoId int) error {
+	if _, err := tx.Exec(ctx, `UPDATE notes SET fk_company_id = $2 WHERE fk_company_id = $1`, fromId, intoId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE company_aliases SET fk_company_id = $2 WHERE fk_company_id = $1`, fromId, intoId); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `DELETE FROM companies WHERE id = $1`, fromId)
+	return err
+}
+
+// DeleteCompanyAlias removes an alias, notes already linked through it stay with the company
+func DeleteCompanyAlias(ctx context.Context, pool *pgxpool.Pool, userId, companyId, aliasId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, `DELETE FROM company_aliases WHERE id = $1 AND fk_company_id = $2 AND fk_user_id = $3`, aliasId, companyId, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrCompanyAliasNotFound
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package jaegerdb
+
+import "testing"
+
+func TestNormalizeCompanyName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Google", "google"},
+		{"  GOOGLE  ", "google"},
+		{"Google LLC", "google"},
+		{"Google, Inc.", "google"},
+		{"Google Inc", "google"},
+		{"Acme Holdings Ltd.", "acme holdings"},
+		{"Nordic Semiconductor ASA", "nordic semiconductor asa"},
+		{"Infobip d.o.o.", "infobip"},
+		{"Siemens AG", "siemens"},
+		{"Procter & Gamble Co.", "procter gamble"},
+		{"McDonald's Corporation", "mcdonalds"},
+		{"McDonald’s", "mcdonalds"},
+		{"Ernst-Young GmbH", "ernst young"},
+		{"Company", "company"}, // the last word is kept even when it's a suffix
+		{"Co. Inc.", "co"},
+		{"3M Company", "3m"},
+		{"Škoda Auto a.s.", "škoda auto"},
+		{"...", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeCompanyName(tt.name); got != tt.want {
+			t.Errorf("normalizeCompanyName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompanyStats(t *testing.T) {
+	notes := []NoteDB{
+		{ApplicationStatus: StatusApplied, AppliedOn: "2026-03-01"},
+		{ApplicationStatus: StatusInterview, AppliedOn: "2026-01-15"},
+		{ApplicationStatus: StatusOffer, AppliedOn: "2026-02-01"},
+		{ApplicationStatus: StatusAccepted, AppliedOn: "2025-11-20"},
+		{ApplicationStatus: StatusRejected, AppliedOn: "2026-04-10"},
+	}
+
+	stats := companyStats(notes, 4)
+	if stats.Total != 5 || stats.Active != 3 || stats.Offers != 2 || stats.Interviews != 4 {
+		t.Errorf("stats = %+v, want 5 total, 3 active, 2 offers, 4 interviews", stats)
+	}
+	if stats.FirstAppliedOn != "2025-11-20" || stats.LastAppliedOn != "2026-04-10" {
+		t.Errorf("applied between %s and %s, want 2025-11-20 and 2026-04-10", stats.FirstAppliedOn, stats.LastAppliedOn)
+	}
+	if stats.ByStatus[StatusApplied] != 1 || stats.ByStatus[StatusAccepted] != 1 || len(stats.ByStatus) != 5 {
+		t.Errorf("by status = %v", stats.ByStatus)
+	}
+
+	if empty := companyStats(nil, 0); empty.Total != 0 || empty.FirstAppliedOn != "" || empty.ByStatus == nil {
+		t.Errorf("stats without notes = %+v", empty)
+	}
+}
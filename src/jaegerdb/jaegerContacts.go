@@ -0,0 +1,275 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrContactNotFound = errors.New("contact not found") // also returned for contacts of other users
+
+const contactColumns = `contacts.id, contacts.name, contacts.role, contacts.email, contacts.phone, contacts.linkedin_url, contacts.notes,
+	contacts.created_at, contacts.updated_at`
+
+func scanContact(row pgx.Row) (ContactDB, error) {
+	var contact ContactDB
+	err := row.Scan(&contact.ID, &contact.Name, &contact.Role, &contact.Email, &contact.Phone, &contact.LinkedInURL, &contact.Notes,
+		&contact.CreatedAt, &contact.UpdatedAt)
+	contact.NoteIDs = []int{}
+	return contact, err
+}
+
+func CreateContact(ctx context.Context, pool *pgxpool.Pool, userId int, contact ContactDB) (ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	created, err := scanContact(pool.QueryRow(ctx, `INSERT INTO contacts (fk_user_id, name, role, email, phone, linkedin_url, notes, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	RETURNING `+contactColumns, userId, contact.Name, contact.Role, contact.Email, contact.Phone, contact.LinkedInURL, contact.Notes))
+	if err != nil {
+		log.Printf("Unable to store the contact: %s", err)
+		return ContactDB{}, err
+	}
+	return created, nil
+}
+
+// GetContacts lists the user's contacts by name together with the notes they are linked to
+func GetContacts(ctx context.Context, pool *pgxpool.Pool, userId int) ([]ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT `+contactColumns+` FROM contacts WHERE fk_user_id = $1 ORDER BY LOWER(name), id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []ContactDB
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	byId := map[int]*ContactDB{}
+	for i := range contacts {
+		byId[contacts[i].ID] = &contacts[i]
+	}
+
+	links, err := pool.Query(ctx, `SELECT note_contacts.fk_contact_id, note_contacts.fk_note_id FROM note_contacts
+	JOIN contacts ON contacts.id = note_contacts.fk_contact_id
+	WHERE contacts.fk_user_id = $1 ORDER BY note_contacts.fk_note_id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer links.Close()
+
+	for links.Next() {
+		var contactId, noteId int
+		if err := links.Scan(&contactId, &noteId); err != nil {
+			return nil, err
+		}
+		if contact, ok := byId[contactId]; ok {
+			contact.NoteIDs = append(contact.NoteIDs, noteId)
+		}
+	}
+	if err := links.Err(); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+func GetContact(ctx context.Context, pool *pgxpool.Pool, userId, contactId int) (ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	contact, err := scanContact(pool.QueryRow(ctx, `SELECT `+contactColumns+` FROM contacts WHERE id = $1 AND fk_user_id = $2`, contactId, userId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ContactDB{}, ErrContactNotFound
+	}
+	if err != nil {
+		return ContactDB{}, err
+	}
+
+	rows, err := pool.Query(ctx, `SELECT fk_note_id FROM note_contacts WHERE fk_contact_id = $1 ORDER BY fk_note_id`, contactId)
+	if err != nil {
+		return ContactDB{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteId int
+		if err := rows.Scan(&noteId); err != nil {
+			return ContactDB{}, err
+		}
+		contact.NoteIDs = append(contact.NoteIDs, noteId)
+	}
+	if err := rows.Err(); err != nil {
+		return ContactDB{}, err
+	}
+	return contact, nil
+}
+
+// UpdateContact replaces all fields of the contact with the given id, the links to notes stay
+func UpdateContact(ctx context.Context, pool *pgxpool.Pool, userId int, contact ContactDB) (ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, `UPDATE contacts SET name = $3, role = $4, email = $5, phone = $6, linkedin_url = $7, notes = $8,
+	updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND fk_user_id = $2`,
+		contact.ID, userId, contact.Name, contact.Role, contact.Email, contact.Phone, contact.LinkedInURL, contact.Notes)
+	if err != nil {
+		log.Printf("Unable to update contact %d: %s", contact.ID, err)
+		return ContactDB{}, err
+	}
+	if result.RowsAffected() == 0 {
+		return ContactDB{}, ErrContactNotFound
+	}
+	return GetContact(ctx, pool, userId, contact.ID)
+}
+
+// DeleteContact removes the contact and its links, the notes stay
+func DeleteContact(ctx context.Context, pool *pgxpool.Pool, userId, contactId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, `DELETE FROM contacts WHERE id = $1 AND fk_user_id = $2`, contactId, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+// LinkContact connects one of the user's contacts to one of their notes, linking them twice changes nothing
+func LinkContact(ctx context.Context, pool *pgxpool.Pool, userId, noteId, contactId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var noteOwned, contactOwned bool
+	err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $3),
+	EXISTS (SELECT 1 FROM contacts WHERE id = $2 AND fk_user_id = $3)`, noteId, contactId, userId).Scan(&noteOwned, &contactOwned)
+	if err != nil {
+		return err
+	}
+	if !noteOwned {
+		return ErrNoteNotFound
+	}
+	if !contactOwned {
+		return ErrContactNotFound
+	}
+
+	_, err = pool.Exec(ctx, `INSERT INTO note_contacts (fk_note_id, fk_contact_id, created_at) VALUES ($1, $2, CURRENT_TIMESTAMP)
+	ON CONFLICT (fk_note_id, fk_contact_id) DO NOTHING`, noteId, contactId)
+	if err != nil {
+		log.Printf("Unable to link contact %d to note %d: %s", contactId, noteId, err)
+		return err
+	}
+	return nil
+}
+
+func UnlinkContact(ctx context.Context, pool *pgxpool.Pool, userId, noteId, contactId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, `DELETE FROM note_contacts USING contacts
+	WHERE note_contacts.fk_note_id = $1 AND note_contacts.fk_contact_id = $2
+	AND contacts.id = note_contacts.fk_contact_id AND contacts.fk_user_id = $3`, noteId, contactId, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+// GetNoteContacts lists the contacts linked to the note by name
+func GetNoteContacts(ctx context.Context, pool *pgxpool.Pool, userId, noteId int) ([]ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $2)`, noteId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := pool.Query(ctx, `SELECT `+contactColumns+` FROM contacts
+	JOIN note_contacts ON note_contacts.fk_contact_id = contacts.id
+	WHERE note_contacts.fk_note_id = $1 AND contacts.fk_user_id = $2 ORDER BY LOWER(contacts.name), contacts.id`, noteId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []ContactDB
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		contact.NoteIDs = nil // only filled in where contacts are listed on their own
+		contacts = append(contacts, contact)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+// GetContactNotes lists the applications the contact is involved in
+func GetContactNotes(ctx context.Context, pool *pgxpool.Pool, userId, contactId int) ([]NoteDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM contacts WHERE id = $1 AND fk_user_id = $2)`, contactId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrContactNotFound
+	}
+
+	rows, err := pool.Query(ctx, `SELECT notes.id, notes.note_id, notes.company_name, notes."position", notes.salary, notes.application_status,
+	notes.applied_on, notes.fk_user_id, notes.updated_at, notes.description
+	FROM notes JOIN note_contacts ON note_contacts.fk_note_id = notes.id
+	WHERE note_contacts.fk_contact_id = $1 AND notes.fk_user_id = $2 ORDER BY notes.applied_on DESC, notes.id`, contactId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []NoteDB
+	for rows.Next() {
+		var note NoteDB
+		var appliedOn time.Time
+		var updatedAt time.Time
+
+		if err := rows.Scan(&note.Id, &note.Uuid, &note.CompanyName, &note.Position, &note.Salary, &note.ApplicationStatus, &appliedOn, &note.UserId, &updatedAt, &note.Description); err != nil {
+			return nil, err
+		}
+		note.AppliedOn = appliedOn.Format("2006-01-02 15:04:05")
+		note.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
+
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
@@ -2,45 +2,106 @@ package jaegerdb
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 
 	// "fmt"
 	"log"
 	"os"
+	"strconv"
 
+	"github.com/MGavranovic/jaeger-backend/src/jaegerpassword"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func ConnectJaegerDB() *pgx.Conn {
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Printf("Failed loading the environment file: %s", err)
+var (
+	ErrUserExists   = errors.New("a user with this email already exists")
+	ErrNoteNotFound = errors.New("note not found") // also returned for notes of other users
+	// returned for unknown emails and wrong passwords alike
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+const defaultQueryTimeout = 5 * time.Second
+
+// queryTimeout limits every jaegerdb call on top of the request context, DB_QUERY_TIMEOUT overrides it
+var queryTimeout = defaultQueryTimeout
+
+// ConnectJaegerDB opens the connection pool, DB_MAX_CONNS and DB_MIN_CONNS set its size
+func ConnectJaegerDB() *pgxpool.Pool {
+	loadEnv()
+
+	config, err := pgxpool.ParseConfig(os.Getenv("DB_URL"))
+	if err != nil {
+		log.Fatalf("Invalid DB_URL: %s", err)
+	}
+	if maxConns, ok := intFromEnv("DB_MAX_CONNS"); ok {
+		config.MaxConns = int32(maxConns)
+	}
+	if minConns, ok := intFromEnv("DB_MIN_CONNS"); ok {
+		config.MinConns = int32(minConns)
+	}
+	if value := os.Getenv("DB_QUERY_TIMEOUT"); value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+			queryTimeout = timeout
+		} else {
+			log.Printf("Invalid DB_QUERY_TIMEOUT %q, using %s", value, defaultQueryTimeout)
+		}
 	}
 
-	conn, err := pgx.Connect(context.Background(), os.Getenv("DB_URL"))
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		log.Printf("Unable to establish the connection to the DB: %s", err)
+		return pool
 	}
-	log.Print("Connection has been established with the DB")
+	if err := pool.Ping(context.Background()); err != nil {
+		log.Printf("Unable to establish the connection to the DB: %s", err)
+		return pool
+	}
+	log.Printf("Connection has been established with the DB, pool size %d", config.MaxConns)
 
-	return conn
+	return pool
 }
 
-func GetUsersJaeger(conn *pgx.Conn) pgx.Row {
-	users, err := conn.Query(context.Background(), "select * from users;")
-	if err != nil {
-		log.Printf("Unable to retrieve the users from the DB: %s", err)
+// loadEnv reads ../.env, variables that are already set take precedence
+func loadEnv() {
+	if err := godotenv.Load("../.env"); err != nil {
+		log.Printf("Failed loading the environment file: %s", err)
 	}
-	defer users.Close()
-	return users
 }
 
-func CreateUserJaeger(w http.ResponseWriter, conn *pgx.Conn, fullName, email, password string) error { // return an int to be able to differentiate between server and insert error
-	tx, err := conn.Begin(context.Background()) // begin the transaction
+func intFromEnv(name string) (int, bool) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using the default", name, value)
+		return 0, false
+	}
+	return n, true
+}
+
+// withTimeout derives the context a single jaegerdb call runs with, cancelled requests still abort it right away
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout)
+}
+
+func CreateUserJaeger(ctx context.Context, pool *pgxpool.Pool, fullName, email, password string) error { // returns ErrUserExists to be able to differentiate between server and insert error
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	passwordHash, err := jaegerpassword.Hash(password) // the password arrives in plain text, only the hash is stored
+	if err != nil {
+		log.Printf("Unable to hash the password: %s", err)
+		return err
+	}
+
+	tx, err := pool.Begin(ctx) // begin the transaction
 	if err != nil {
 		log.Printf("Unable to start DB transaction: %s", err)
 		return err
@@ -49,7 +110,7 @@ func CreateUserJaeger(w http.ResponseWriter, conn *pgx.Conn, fullName, email, pa
 		if err != nil {
 			tx.Rollback(context.Background()) // rollback if an issue comes up
 		} else {
-			tx.Commit(context.Background()) // commiting the transaction
+			tx.Commit(ctx) // commiting the transaction
 		}
 	}()
 
@@ -57,7 +118,7 @@ func CreateUserJaeger(w http.ResponseWriter, conn *pgx.Conn, fullName, email, pa
 	VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	ON CONFLICT (email) DO NOTHING;` // query for inserting the user
 
-	cmdTag, err := tx.Exec(context.Background(), query, fullName, email, password) // executing the query
+	cmdTag, err := tx.Exec(ctx, query, fullName, email, passwordHash) // executing the query
 	if err != nil {
 		log.Printf("Unable to insert the data into DB: %s", err)
 		return err
@@ -65,16 +126,22 @@ func CreateUserJaeger(w http.ResponseWriter, conn *pgx.Conn, fullName, email, pa
 
 	if cmdTag.RowsAffected() == 0 { // error on unaffected rows
 		log.Printf("No rows inserted. A user with email %s already exists.", email)
-		http.Error(w, "No rows inserted. A user with this email already exists.", http.StatusConflict)
-		return nil
+		return ErrUserExists
 	}
 	return nil
 }
 
 // TODO: JWT tokens
-func GetUserByEmail(conn *pgx.Conn, email string) (*RetrievedUser, error) {
+func GetUserByEmail(ctx context.Context, pool *pgxpool.Pool, email string) (*RetrievedUser, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	var user RetrievedUser
-	err := conn.QueryRow(context.Background(), "SELECT id, full_name, email FROM users WHERE email = $1", email).Scan(&user.ID, &user.FullName, &user.Email)
+	err := pool.QueryRow(ctx, `SELECT id, full_name, email, verified_at IS NOT NULL, role, disabled_at IS NOT NULL, password_reset_required, deleted_at IS NOT NULL
+	FROM users WHERE email = $1`, email).Scan(&user.ID, &user.FullName, &user.Email, &user.Verified, &user.Role, &user.Disabled, &user.PasswordResetRequired, &user.DeletionScheduled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &RetrievedUser{}, ErrUserNotFound
+	}
 	if err != nil {
 		log.Printf("Failed to retrieve user %s", email)
 		return &RetrievedUser{}, err
@@ -82,29 +149,73 @@ func GetUserByEmail(conn *pgx.Conn, email string) (*RetrievedUser, error) {
 	return &user, nil
 }
 
-func CheckCredentialsOnLogin(conn *pgx.Conn, email, password string) error {
+func CheckCredentialsOnLogin(ctx context.Context, pool *pgxpool.Pool, email, password string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	var user LoginData
 	user.Email = email // assign the email to user
 	// get the pw for that email
-	err := conn.QueryRow(context.Background(), "SELECT password FROM users where email = $1", email).Scan(&user.Password)
-	if err != nil { // if err log and return err
+	err := pool.QueryRow(ctx, "SELECT password FROM users where email = $1", email).Scan(&user.Password)
+	if errors.Is(err, pgx.ErrNoRows) {
 		log.Printf("Failed to find user with %s email address", email)
+		jaegerpassword.VerifyDummy(password)
+		return ErrInvalidCredentials
+	}
+	if err != nil { // if err log and return err
+		log.Printf("Failed to retrieve the password for %s: %s", email, err)
 		return err
 	}
 
 	// compare the existing hash with the pw from frontend
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		log.Print("Hash doesn't match the password")
-		return err
+	ok, needsRehash, err := jaegerpassword.Verify(user.Password, password)
+	if err != nil || !ok {
+		log.Printf("Hash doesn't match the password: %v", err)
+		return ErrInvalidCredentials
+	}
+
+	// hashes made with older settings are replaced while the plain password is at hand
+	if needsRehash {
+		if err := rehashPassword(ctx, pool, email, user.Password, password); err != nil {
+			log.Printf("Failed rehashing the password of %s: %s", email, err)
+		}
 	}
 	return nil
 }
 
+func rehashPassword(ctx context.Context, pool *pgxpool.Pool, email, oldHash, password string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	newHash, err := jaegerpassword.Hash(password)
+	if err != nil {
+		return err
+	}
+	// only replaces the hash that was verified, in case the password changed in the meantime
+	_, err = pool.Exec(ctx, `UPDATE users SET password = $1 WHERE email = $2 AND password = $3`, newHash, email, oldHash)
+	return err
+}
+
 // TODO: create a function that turns 0 values into nil
-func UpdateUser(conn *pgx.Conn, id int, fullName, email, password string) (UpdatedUserDataDB, error) {
+func UpdateUser(ctx context.Context, pool *pgxpool.Pool, id int, fullName, email, password string) (UpdatedUserDataDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	var updatedUser UpdatedUserDataDB
 
-	err := conn.QueryRow(context.Background(), "UPDATE users SET full_name = COALESCE($1, full_name), email = COALESCE($2, email), password = COALESCE($3, password) WHERE id = $4 RETURNING id, full_name, email, password;;", stringToNil(&fullName), stringToNil(&email), stringToNil(&password), id).Scan(&updatedUser.id, &updatedUser.fullName, &updatedUser.email, &updatedUser.password)
+	if password != "" {
+		passwordHash, err := jaegerpassword.Hash(password)
+		if err != nil {
+			log.Printf("Unable to hash the password: %s", err)
+			return UpdatedUserDataDB{}, err
+		}
+		password = passwordHash
+	}
+
+	// changing the email means it has to be verified again
+	err := pool.QueryRow(ctx, `UPDATE users SET full_name = COALESCE($1, full_name), email = COALESCE($2, email), password = COALESCE($3, password),
+	verified_at = CASE WHEN $2::text IS NOT NULL AND $2::text <> email THEN NULL ELSE verified_at END
+	WHERE id = $4 RETURNING id, full_name, email, password;`, stringToNil(&fullName), stringToNil(&email), stringToNil(&password), id).Scan(&updatedUser.id, &updatedUser.fullName, &updatedUser.email, &updatedUser.password)
 	if err != nil {
 		log.Printf("User couldn't be updated: %s", err)
 		return UpdatedUserDataDB{}, err
@@ -120,20 +231,53 @@ func stringToNil(s *string) *string {
 	return s
 }
 
-func CreateNote(conn *pgx.Conn, uuid, companyName, position, salary, applicationStatus, appliedOn, description string, userId int) error {
+// CreateNote returns the id of the new note, the status it starts with is the first entry of its timeline
+// the note is linked to the user's company going by its company name, a new company is added for names not seen before
+func CreateNote(ctx context.Context, pool *pgxpool.Pool, uuid, companyName, position, salary, applicationStatus, appliedOn, description string, userId int) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	_, err := conn.Exec(context.Background(), `INSERT INTO notes(
-	note_id, company_name, "position", salary, application_status, applied_on, description, updated_at, fk_user_id)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, $8);`, uuid, companyName, position, salary, applicationStatus, appliedOn, description, userId)
+	status, err := newNoteStatus(applicationStatus)
+	if err != nil {
+		return 0, err
+	}
 
+	tx, err := pool.Begin(ctx)
 	if err != nil {
-		return err
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
 	}
-	return nil
+	defer tx.Rollback(context.Background()) // no-op after commit
+
+	companyId, err := resolveCompany(ctx, tx, userId, companyName)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow(ctx, `INSERT INTO notes(
+	note_id, company_name, "position", salary, application_status, applied_on, description, updated_at, fk_user_id, fk_company_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, $8, $9) RETURNING id;`, uuid, companyName, position, salary, status, appliedOn, description, userId, companyId).Scan(&id)
+
+	if err != nil {
+		return 0, err
+	}
+	if err := recordStatusChange(ctx, tx, id, "", status); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-func GetAllUserNotes(conn *pgx.Conn, id int) ([]NoteDB, error) {
-	rows, err := conn.Query(context.Background(), `SELECT * FROM notes WHERE fk_user_id = $1`, id)
+// GetAllUserNotes returns the user's notes matching the filter together with their tags
+func GetAllUserNotes(ctx context.Context, pool *pgxpool.Pool, id int, filter NoteFilter) ([]NoteDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query, args := userNotesQuery(id, filter)
+	rows, err := pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -157,32 +301,80 @@ func GetAllUserNotes(conn *pgx.Conn, id int) ([]NoteDB, error) {
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	tagRows, err := pool.Query(ctx, noteTagsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	links := map[int][]NoteTagDB{}
+	for tagRows.Next() {
+		var noteId int
+		var tag NoteTagDB
+		if err := tagRows.Scan(&noteId, &tag.ID, &tag.Name, &tag.Color); err != nil {
+			return nil, err
+		}
+		links[noteId] = append(links[noteId], tag)
+	}
+	if err := tagRows.Err(); err != nil {
+		return nil, err
+	}
+	withTags(notes, links)
 
 	return notes, nil
 }
 
-func getNote(conn *pgx.Conn, id int) CheckNoteForUpdate {
+// getNote only returns notes owned by the user, the row stays locked until tx ends
+func getNote(ctx context.Context, tx pgx.Tx, userId, id int) (CheckNoteForUpdate, error) {
 	var note CheckNoteForUpdate
-	if err := conn.QueryRow(context.Background(), `SELECT company_name, position, salary, application_status, applied_on, description FROM notes WHERE id = $1`, id).Scan(
-		&note.companyName, &note.position, &note.salary, &note.status, &note.appliedOn, &note.description); err != nil {
+	err := tx.QueryRow(ctx, `SELECT company_name, position, salary, application_status, applied_on, description FROM notes WHERE id = $1 AND fk_user_id = $2 FOR UPDATE`, id, userId).Scan(
+		&note.companyName, &note.position, &note.salary, &note.status, &note.appliedOn, &note.description)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return note, ErrNoteNotFound
+	}
+	if err != nil {
 		log.Printf("Error getting the note for updating")
+		return note, err
 	}
 
-	return note
+	return note, nil
 }
 
-func UpdateNote(conn *pgx.Conn, id int, company, pos, sal, appStat, appOn, desc string) error {
+func UpdateNote(ctx context.Context, pool *pgxpool.Pool, userId, id int, company, pos, sal, appStat, appOn, desc string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background()) // no-op after commit
+
 	query := "UPDATE notes SET" // query to append to
 	// 1. get the data for this note
-	existingData := getNote(conn, id)
+	existingData, err := getNote(ctx, tx, userId, id)
+	if err != nil {
+		return err
+	}
+	status, err := nextNoteStatus(existingData.status, appStat)
+	if err != nil {
+		return err
+	}
 	log.Printf("EXISTING NOTE DATA: \nCompany Name: %s\nPosition: %s\nSalary: %s\nApplication Status: %s\nApplied On: %s\nDescription: %s\n", existingData.companyName, existingData.position, existingData.salary, existingData.status, existingData.appliedOn, existingData.description)
 	// 2. compare it with new data
 	args := []any{}
 	counter := 1
 	if existingData.companyName != company {
-		query += fmt.Sprintf(" company_name = $%d,", counter)
-		args = append(args, company)
-		counter++
+		companyId, err := resolveCompany(ctx, tx, userId, company)
+		if err != nil {
+			return err
+		}
+		query += fmt.Sprintf(" company_name = $%d, fk_company_id = $%d,", counter, counter+1)
+		args = append(args, company, companyId)
+		counter += 2
 	}
 	if existingData.position != pos {
 		query += fmt.Sprintf(" position = $%d,", counter)
@@ -194,10 +386,13 @@ func UpdateNote(conn *pgx.Conn, id int, company, pos, sal, appStat, appOn, desc
 		args = append(args, sal)
 		counter++
 	}
-	if existingData.status != appStat {
+	if existingData.status != status {
 		query += fmt.Sprintf(" application_status = $%d,", counter)
-		args = append(args, appStat)
+		args = append(args, status)
 		counter++
+		if err := recordStatusChange(ctx, tx, id, existingData.status, status); err != nil {
+			return err
+		}
 	}
 
 	appliedOnDate, err := time.Parse("2006-01-02", appOn)
@@ -224,44 +419,58 @@ func UpdateNote(conn *pgx.Conn, id int, company, pos, sal, appStat, appOn, desc
 	log.Print("*************************args*********************************\n", args, "\n", "*************************args*********************************\n")
 
 	query = strings.TrimSuffix(query, ",")
-	query += fmt.Sprintf(" WHERE id = $%d", counter)
-	args = append(args, id)
+	query += fmt.Sprintf(" WHERE id = $%d AND fk_user_id = $%d", counter, counter+1)
+	args = append(args, id, userId)
 
-	if len(args) > 1 {
-		_, err := conn.Exec(context.Background(), query, args...)
+	if len(args) > 2 {
+		_, err := tx.Exec(ctx, query, args...)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return tx.Commit(ctx)
 }
 
 // DEBUG: date format is the problem cause it has time along with date
-func GetUpdatedNote(conn *pgx.Conn, id int) NoteDB {
+func GetUpdatedNote(ctx context.Context, pool *pgxpool.Pool, userId, id int) (NoteDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	var appliedOn time.Time
 	var updatedAt time.Time
 
 	var note NoteDB
-	if err := conn.QueryRow(context.Background(),
+	err := pool.QueryRow(ctx,
 		`SELECT id, note_id, company_name, position, salary, application_status, applied_on, fk_user_id, updated_at, description
-	FROM notes WHERE id = $1`, id).
+	FROM notes WHERE id = $1 AND fk_user_id = $2`, id, userId).
 		Scan(&note.Id, &note.Uuid, &note.CompanyName, &note.Position,
 			&note.Salary, &note.ApplicationStatus, &appliedOn,
-			&note.UserId, &updatedAt, &note.Description); err != nil {
+			&note.UserId, &updatedAt, &note.Description)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NoteDB{}, ErrNoteNotFound
+	}
+	if err != nil {
 		log.Printf("Couldn't get the note after updating: %s", err)
+		return NoteDB{}, err
 	}
 
 	note.AppliedOn = appliedOn.Format("2006-01-02 15:04:05")
 	note.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-	return note
+	return note, nil
 }
 
-func DeleteNote(conn *pgx.Conn, id int) error {
-	result, err := conn.Exec(context.Background(), "DELETE FROM notes WHERE id = $1", id)
+func DeleteNote(ctx context.Context, pool *pgxpool.Pool, userId, id int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, "DELETE FROM notes WHERE id = $1 AND fk_user_id = $2", id, userId)
 	if err != nil {
 		return err
 	}
 	affected := result.RowsAffected()
 	log.Printf("Deleted %d rows", affected)
+	if affected == 0 {
+		return ErrNoteNotFound
+	}
 	return nil
 }
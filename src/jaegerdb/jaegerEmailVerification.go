@@ -0,0 +1,86 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrVerificationTokenInvalid = errors.New("email verification token is invalid or expired")
+
+// CreateEmailVerificationToken stores a token for verifying the given email of the user, older tokens stop working
+func CreateEmailVerificationToken(ctx context.Context, pool *pgxpool.Pool, userId int, email, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(ctx, `UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP
+	WHERE fk_user_id = $1 AND used_at IS NULL`, userId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO email_verification_tokens (token_hash, fk_user_id, email, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)`, tokenHash, userId, email, expiresAt); err != nil {
+		log.Printf("Unable to store the email verification token: %s", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// VerifyEmailWithToken consumes the token and marks the user's email as verified
+// the token only works if the user still has the email it was sent to
+func VerifyEmailWithToken(ctx context.Context, pool *pgxpool.Pool, tokenHash string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var (
+		userId    int
+		email     string
+		expiresAt time.Time
+		usedAt    *time.Time
+	)
+	err = tx.QueryRow(ctx, `SELECT fk_user_id, email, expires_at, used_at FROM email_verification_tokens
+	WHERE token_hash = $1 FOR UPDATE`, tokenHash).Scan(&userId, &email, &expiresAt, &usedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	if usedAt != nil || time.Now().After(expiresAt) {
+		return 0, ErrVerificationTokenInvalid
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1`, tokenHash); err != nil {
+		return 0, err
+	}
+	cmdTag, err := tx.Exec(ctx, `UPDATE users SET verified_at = CURRENT_TIMESTAMP WHERE id = $1 AND email = $2`, userId, email)
+	if err != nil {
+		return 0, err
+	}
+	if cmdTag.RowsAffected() == 0 { // email changed since the token was sent
+		return 0, ErrVerificationTokenInvalid
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
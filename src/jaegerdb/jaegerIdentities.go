@@ -0,0 +1,115 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrIdentityNotFound = errors.New("identity not found")
+
+// GetUserByIdentity returns the email of the user linked to the provider's subject and updates the last login
+func GetUserByIdentity(ctx context.Context, pool *pgxpool.Pool, provider, subject string) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var email string
+	err := pool.QueryRow(ctx, `UPDATE user_identities SET last_login_at = CURRENT_TIMESTAMP
+	FROM users WHERE users.id = user_identities.fk_user_id AND provider = $1 AND subject = $2
+	RETURNING users.email`, provider, subject).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrIdentityNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
+func LinkIdentity(ctx context.Context, pool *pgxpool.Pool, userId int, provider, subject, email string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := pool.Exec(ctx, `INSERT INTO user_identities (provider, subject, fk_user_id, email, created_at, last_login_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`, provider, subject, userId, email)
+	if err != nil {
+		log.Printf("Unable to link identity %s/%s to user %d: %s", provider, subject, userId, err)
+		return err
+	}
+	return nil
+}
+
+// CreateExternalUser creates a user that logs in through an identity provider and links the identity to it
+// the user has no usable password until they set one through the password reset flow
+func CreateExternalUser(ctx context.Context, pool *pgxpool.Pool, fullName, email string, verified bool, provider, subject string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	var userId int
+	err = tx.QueryRow(ctx, `INSERT INTO users (full_name, email, password, created_at, updated_at, verified_at)
+	VALUES ($1, $2, '', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CASE WHEN $3 THEN CURRENT_TIMESTAMP END)
+	ON CONFLICT (email) DO NOTHING
+	RETURNING id`, fullName, email, verified).Scan(&userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO user_identities (provider, subject, fk_user_id, email, created_at, last_login_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`, provider, subject, userId, email); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func GetUserIdentities(ctx context.Context, pool *pgxpool.Pool, userId int) ([]IdentityDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT id, provider, email, created_at, last_login_at
+	FROM user_identities WHERE fk_user_id = $1 ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []IdentityDB
+	for rows.Next() {
+		var identity IdentityDB
+		if err := rows.Scan(&identity.ID, &identity.Provider, &identity.Email, &identity.CreatedAt, &identity.LastLoginAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func UnlinkIdentity(ctx context.Context, pool *pgxpool.Pool, userId, identityId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cmdTag, err := pool.Exec(ctx, `DELETE FROM user_identities WHERE id = $1 AND fk_user_id = $2`, identityId, userId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrInterviewNotFound = errors.New("interview not found") // also returned for interviews of other users' notes
+
+// interview types
+const (
+	InterviewPhone     = "phone"
+	InterviewVideo     = "video"
+	InterviewOnsite    = "onsite"
+	InterviewTechnical = "technical"
+	InterviewOther     = "other"
+)
+
+// interview outcomes, every interview starts out pending
+const (
+	OutcomePending   = "pending"
+	OutcomePassed    = "passed"
+	OutcomeFailed    = "failed"
+	OutcomeCancelled = "cancelled"
+)
+
+var (
+	InterviewTypes    = []string{InterviewPhone, InterviewVideo, InterviewOnsite, InterviewTechnical, InterviewOther}
+	InterviewOutcomes = []string{OutcomePending, OutcomePassed, OutcomeFailed, OutcomeCancelled}
+)
+
+// interviewers are stored one per line
+func joinInterviewers(interviewers []string) string {
+	return strings.Join(interviewers, "\n")
+}
+
+func splitInterviewers(interviewers string) []string {
+	if interviewers == "" {
+		return []string{}
+	}
+	return strings.Split(interviewers, "\n")
+}
+
+// inTimeZone shows the times in the zone the interview takes place in, they are stored in UTC
+func (i *InterviewDB) inTimeZone() {
+	loc, err := time.LoadLocation(i.TimeZone)
+	if err != nil {
+		return // validated when it was stored, UTC is good enough if the zone went away
+	}
+	i.StartsAt = i.StartsAt.In(loc)
+	if i.EndsAt != nil {
+		endsAt := i.EndsAt.In(loc)
+		i.EndsAt = &endsAt
+	}
+}
+
+const interviewColumns = `interviews.id, interviews.fk_note_id, interviews.round, interviews.interview_type, interviews.starts_at, interviews.ends_at,
+	interviews.time_zone, interviews.location, interviews.video_link, interviews.interviewers, interviews.outcome, interviews.prep_notes,
+	interviews.created_at, interviews.updated_at`
+
+func scanInterview(row pgx.Row, extra ...any) (InterviewDB, error) {
+	var interview InterviewDB
+	var interviewers string
+	dest := []any{&interview.ID, &interview.NoteID, &interview.Round, &interview.Type, &interview.StartsAt, &interview.EndsAt,
+		&interview.TimeZone, &interview.Location, &interview.VideoLink, &interviewers, &interview.Outcome, &interview.PrepNotes,
+		&interview.CreatedAt, &interview.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return InterviewDB{}, err
+	}
+	interview.Interviewers = splitInterviewers(interviewers)
+	interview.inTimeZone()
+	return interview, nil
+}
+
+// CreateInterview adds an interview to one of the user's notes
+func CreateInterview(ctx context.Context, pool *pgxpool.Pool, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// the insert only happens when the note belongs to the user
+	created, err := scanInterview(pool.QueryRow(ctx, `INSERT INTO interviews (fk_note_id, round, interview_type, starts_at, ends_at, time_zone,
+	location, video_link, interviewers, outcome, prep_notes, created_at, updated_at)
+	SELECT id, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP FROM notes WHERE id = $1 AND fk_user_id = $2
+	RETURNING `+interviewColumns, noteId, userId, interview.Round, interview.Type, interview.StartsAt, interview.EndsAt, interview.TimeZone,
+		interview.Location, interview.VideoLink, joinInterviewers(interview.Interviewers), interview.Outcome, interview.PrepNotes))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return InterviewDB{}, ErrNoteNotFound
+	}
+	if err != nil {
+		log.Printf("Unable to store the interview: %s", err)
+		return InterviewDB{}, err
+	}
+	return created, nil
+}
+
+// GetNoteInterviews lists the note's interviews in the order they take place
+func GetNoteInterviews(ctx context.Context, pool *pgxpool.Pool, userId, noteId int) ([]InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $2)`, noteId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := pool.Query(ctx, `SELECT `+interviewColumns+` FROM interviews
+	WHERE fk_note_id = $1 ORDER BY starts_at, id`, noteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interviews []InterviewDB
+	for rows.Next() {
+		interview, err := scanInterview(rows)
+		if err != nil {
+			return nil, err
+		}
+		interviews = append(interviews, interview)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return interviews, nil
+}
+
+func GetInterview(ctx context.Context, pool *pgxpool.Pool, userId, noteId, interviewId int) (InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	interview, err := scanInterview(pool.QueryRow(ctx, `SELECT `+interviewColumns+` FROM interviews
+	JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE interviews.id = $1 AND interviews.fk_note_id = $2 AND notes.fk_user_id = $3`, interviewId, noteId, userId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return InterviewDB{}, ErrInterviewNotFound
+	}
+	if err != nil {
+		return InterviewDB{}, err
+	}
+	return interview, nil
+}
+
+// UpdateInterview replaces all fields of the interview with the given id
+func UpdateInterview(ctx context.Context, pool *pgxpool.Pool, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	updated, err := scanInterview(pool.QueryRow(ctx, `UPDATE interviews SET round = $4, interview_type = $5, starts_at = $6, ends_at = $7,
+	time_zone = $8, location = $9, video_link = $10, interviewers = $11, outcome = $12, prep_notes = $13, updated_at = CURRENT_TIMESTAMP
+	FROM notes WHERE interviews.id = $1 AND interviews.fk_note_id = $2 AND notes.id = interviews.fk_note_id AND notes.fk_user_id = $3
+	RETURNING `+interviewColumns, interview.ID, noteId, userId, interview.Round, interview.Type, interview.StartsAt, interview.EndsAt,
+		interview.TimeZone, interview.Location, interview.VideoLink, joinInterviewers(interview.Interviewers), interview.Outcome, interview.PrepNotes))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return InterviewDB{}, ErrInterviewNotFound
+	}
+	if err != nil {
+		log.Printf("Unable to update interview %d: %s", interview.ID, err)
+		return InterviewDB{}, err
+	}
+	return updated, nil
+}
+
+func DeleteInterview(ctx context.Context, pool *pgxpool.Pool, userId, noteId, interviewId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, `DELETE FROM interviews USING notes
+	WHERE interviews.id = $1 AND interviews.fk_note_id = $2 AND notes.id = interviews.fk_note_id AND notes.fk_user_id = $3`, interviewId, noteId, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrInterviewNotFound
+	}
+	return nil
+}
+
+// GetUpcomingInterviews returns the interviews of all of the user's notes starting between from and until, cancelled ones are left out
+func GetUpcomingInterviews(ctx context.Context, pool *pgxpool.Pool, userId int, from, until time.Time) ([]UpcomingInterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT `+interviewColumns+`, notes.company_name, notes."position", notes.application_status
+	FROM interviews JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE notes.fk_user_id = $1 AND interviews.starts_at >= $2 AND interviews.starts_at < $3 AND interviews.outcome <> $4
+	ORDER BY interviews.starts_at, interviews.id`, userId, from, until, OutcomeCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interviews []UpcomingInterviewDB
+	for rows.Next() {
+		var upcoming UpcomingInterviewDB
+		upcoming.InterviewDB, err = scanInterview(rows, &upcoming.CompanyName, &upcoming.Position, &upcoming.ApplicationStatus)
+		if err != nil {
+			return nil, err
+		}
+		interviews = append(interviews, upcoming)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return interviews, nil
+}
+
+// GetUserInterviews returns the interviews of all of the user's notes, for the data export
+func GetUserInterviews(ctx context.Context, pool *pgxpool.Pool, userId int) ([]InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT `+interviewColumns+` FROM interviews
+	JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE notes.fk_user_id = $1 ORDER BY interviews.fk_note_id, interviews.starts_at, interviews.id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interviews []InterviewDB
+	for rows.Next() {
+		interview, err := scanInterview(rows)
+		if err != nil {
+			return nil, err
+		}
+		interviews = append(interviews, interview)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return interviews, nil
+}
@@ -0,0 +1,93 @@
+package jaegerdb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RecordLoginAttempt stores the outcome of a login, the user is looked up by email so unknown emails are tracked too
+func RecordLoginAttempt(ctx context.Context, pool *pgxpool.Pool, email, ip, userAgent string, succeeded bool) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := pool.Exec(ctx, `INSERT INTO login_attempts (email, fk_user_id, ip_address, user_agent, succeeded, attempted_at)
+	VALUES ($1, (SELECT id FROM users WHERE LOWER(email) = $1 LIMIT 1), $2, $3, $4, CURRENT_TIMESTAMP)`, email, ip, userAgent, succeeded)
+	if err != nil {
+		log.Printf("Unable to store the login attempt: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetLoginFailures counts the failed attempts after since, for the account a successful login starts the count over
+func GetLoginFailures(ctx context.Context, pool *pgxpool.Pool, email, ip string, since time.Time) (LoginFailuresDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var failures LoginFailuresDB
+
+	err := pool.QueryRow(ctx, `SELECT COUNT(*), MAX(attempted_at) FROM login_attempts
+	WHERE email = $1 AND NOT succeeded AND attempted_at > GREATEST($2,
+		COALESCE((SELECT MAX(attempted_at) FROM login_attempts WHERE email = $1 AND succeeded), $2))`, email, since).
+		Scan(&failures.AccountFailures, &failures.AccountLastFailure)
+	if err != nil {
+		return LoginFailuresDB{}, err
+	}
+
+	// a successful login doesn't reset the ip, otherwise logging into an own account would clear it
+	err = pool.QueryRow(ctx, `SELECT COUNT(*), MAX(attempted_at) FROM login_attempts
+	WHERE ip_address = $1 AND NOT succeeded AND attempted_at > $2`, ip, since).
+		Scan(&failures.IPFailures, &failures.IPLastFailure)
+	if err != nil {
+		return LoginFailuresDB{}, err
+	}
+	return failures, nil
+}
+
+// GetRecentLoginFailures lists the latest failed logins to the user's account
+func GetRecentLoginFailures(ctx context.Context, pool *pgxpool.Pool, userId, limit int) ([]LoginAttemptDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT id, ip_address, user_agent, succeeded, attempted_at FROM login_attempts
+	WHERE fk_user_id = $1 AND NOT succeeded ORDER BY attempted_at DESC LIMIT $2`, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanLoginAttempts(rows)
+}
+
+// GetLoginHistory lists every login attempt to the user's account
+func GetLoginHistory(ctx context.Context, pool *pgxpool.Pool, userId int) ([]LoginAttemptDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT id, ip_address, user_agent, succeeded, attempted_at FROM login_attempts
+	WHERE fk_user_id = $1 ORDER BY attempted_at DESC`, userId)
+	if err != nil {
+		return nil, err
+	}
+	return scanLoginAttempts(rows)
+}
+
+func scanLoginAttempts(rows pgx.Rows) ([]LoginAttemptDB, error) {
+	defer rows.Close()
+
+	var attempts []LoginAttemptDB
+	for rows.Next() {
+		var attempt LoginAttemptDB
+		if err := rows.Scan(&attempt.ID, &attempt.IPAddress, &attempt.UserAgent, &attempt.Succeeded, &attempt.AttemptedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
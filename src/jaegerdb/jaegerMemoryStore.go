@@ -0,0 +1,2042 @@
+package jaegerdb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerpassword"
+)
+
+// MemoryStore implements UserStore and NoteStore without a database, for running the API locally and in tests
+// it behaves like the Postgres implementation including the cascades, but nothing survives a restart
+type MemoryStore struct {
+	mu sync.Mutex
+
+	lastId        map[string]int // per table, like the SERIAL columns
+	users         map[int]*memUser
+	notes         map[int]*memNote
+	sessions      map[string]*memSession
+	refreshTokens map[string]*memRefreshToken
+	resetTokens   map[string]*memOneTimeToken
+	verifyTokens  map[string]*memOneTimeToken
+	recoveryCodes []*memRecoveryCode
+	identities    []*memIdentity
+	apiTokens     []*memAPIToken
+	loginAttempts []*memLoginAttempt
+	auditEvents   []*memAuditEvent
+	statusHistory []*memStatusChange
+	interviews    []*memInterview
+	contacts      map[int]*memContact
+	noteContacts  []memNoteContact
+	companies     map[int]*memCompany
+	aliases       []*memCompanyAlias
+	tags          map[int]*memTag
+	noteTags      []memNoteTag
+}
+
+type memUser struct {
+	id                    int
+	fullName              string
+	email                 string
+	password              string
+	role                  string
+	createdAt             time.Time
+	updatedAt             time.Time
+	verifiedAt            *time.Time
+	disabledAt            *time.Time
+	passwordResetRequired bool
+	deletedAt             *time.Time
+	totpSecret            string
+	totpEnabledAt         *time.Time
+	totpLastCounter       *int64
+}
+
+type memNote struct {
+	id                int
+	uuid              string
+	companyName       string
+	position          string
+	salary            string
+	applicationStatus string
+	appliedOn         time.Time
+	userId            int
+	updatedAt         time.Time
+	description       string
+	companyId         int // 0 when the company name has nothing to match by
+}
+
+type memStatusChange struct {
+	NoteStatusChangeDB
+	userId int
+}
+
+type memInterview struct {
+	InterviewDB
+	userId int
+}
+
+type memContact struct {
+	ContactDB
+	userId int
+}
+
+type memNoteContact struct {
+	noteId    int
+	contactId int
+}
+
+type memCompany struct {
+	CompanyDB
+	userId         int
+	normalizedName string
+}
+
+type memCompanyAlias struct {
+	CompanyAliasDB
+	companyId  int
+	userId     int
+	normalized string
+}
+
+type memTag struct {
+	TagDB
+	userId int
+}
+
+type memNoteTag struct {
+	noteId int
+	tagId  int
+}
+
+type memSession struct {
+	SessionDB
+	userId    int
+	revokedAt *time.Time
+}
+
+type memRefreshToken struct {
+	familyId  string
+	userId    int
+	expiresAt time.Time
+	usedAt    *time.Time
+	revokedAt *time.Time
+}
+
+// password reset and email verification tokens
+type memOneTimeToken struct {
+	userId    int
+	email     string
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+type memRecoveryCode struct {
+	userId   int
+	codeHash string
+	usedAt   *time.Time
+}
+
+type memIdentity struct {
+	IdentityDB
+	subject string
+	userId  int
+}
+
+type memAPIToken struct {
+	APITokenDB
+	userId    int
+	tokenHash string
+	revokedAt *time.Time
+}
+
+type memLoginAttempt struct {
+	LoginAttemptDB
+	email  string
+	userId int // 0 for unknown emails
+}
+
+type memAuditEvent struct {
+	AuditEventDB
+	userId int
+}
+
+var (
+	_ UserStore = (*MemoryStore)(nil)
+	_ NoteStore = (*MemoryStore)(nil)
+)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lastId:        map[string]int{},
+		users:         map[int]*memUser{},
+		notes:         map[int]*memNote{},
+		contacts:      map[int]*memContact{},
+		companies:     map[int]*memCompany{},
+		tags:          map[int]*memTag{},
+		sessions:      map[string]*memSession{},
+		refreshTokens: map[string]*memRefreshToken{},
+		resetTokens:   map[string]*memOneTimeToken{},
+		verifyTokens:  map[string]*memOneTimeToken{},
+	}
+}
+
+// Close does nothing, it's there to satisfy Store
+func (s *MemoryStore) Close() {}
+
+func (s *MemoryStore) nextId(table string) int {
+	s.lastId[table]++
+	return s.lastId[table]
+}
+
+func (s *MemoryStore) userByEmail(email string) *memUser {
+	for _, user := range s.users {
+		if user.email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+// sortedUsers returns the users ordered by id since map iteration order is random
+func (s *MemoryStore) sortedUsers() []*memUser {
+	users := make([]*memUser, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].id < users[j].id })
+	return users
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// users
+
+func (s *MemoryStore) CreateUserJaeger(ctx context.Context, fullName, email, password string) error {
+	passwordHash, err := jaegerpassword.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userByEmail(email) != nil {
+		return ErrUserExists
+	}
+	now := time.Now()
+	id := s.nextId("users")
+	s.users[id] = &memUser{id: id, fullName: fullName, email: email, password: passwordHash, role: RoleUser, createdAt: now, updatedAt: now}
+	return nil
+}
+
+func (s *MemoryStore) GetUserByEmail(ctx context.Context, email string) (*RetrievedUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.userByEmail(email)
+	if user == nil {
+		return &RetrievedUser{}, ErrUserNotFound
+	}
+	return &RetrievedUser{
+		ID:                    user.id,
+		FullName:              user.fullName,
+		Email:                 user.email,
+		Verified:              user.verifiedAt != nil,
+		Role:                  user.role,
+		Disabled:              user.disabledAt != nil,
+		PasswordResetRequired: user.passwordResetRequired,
+		DeletionScheduled:     user.deletedAt != nil,
+	}, nil
+}
+
+func (s *MemoryStore) CheckCredentialsOnLogin(ctx context.Context, email, password string) error {
+	s.mu.Lock()
+	user := s.userByEmail(email)
+	var hash string
+	if user != nil {
+		hash = user.password
+	}
+	s.mu.Unlock()
+
+	if user == nil {
+		jaegerpassword.VerifyDummy(password)
+		return ErrInvalidCredentials
+	}
+	ok, needsRehash, err := jaegerpassword.Verify(hash, password)
+	if err != nil || !ok {
+		return ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		if newHash, err := jaegerpassword.Hash(password); err == nil {
+			s.mu.Lock()
+			if user.password == hash {
+				user.password = newHash
+			}
+			s.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateUser(ctx context.Context, id int, fullName, email, password string) (UpdatedUserDataDB, error) {
+	if password != "" {
+		passwordHash, err := jaegerpassword.Hash(password)
+		if err != nil {
+			return UpdatedUserDataDB{}, err
+		}
+		password = passwordHash
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return UpdatedUserDataDB{}, ErrUserNotFound
+	}
+	if email != "" && email != user.email {
+		if s.userByEmail(email) != nil {
+			return UpdatedUserDataDB{}, ErrUserExists
+		}
+		user.email = email
+		user.verifiedAt = nil // changing the email means it has to be verified again
+	}
+	if fullName != "" {
+		user.fullName = fullName
+	}
+	if password != "" {
+		user.password = password
+	}
+	updated := *user // the result must not change along with the stored user
+	return UpdatedUserDataDB{id: updated.id, fullName: &updated.fullName, email: &updated.email, password: &updated.password}, nil
+}
+
+func (s *MemoryStore) GetProfile(ctx context.Context, userId int) (ProfileDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok {
+		return ProfileDB{}, ErrUserNotFound
+	}
+	return ProfileDB{
+		ID:         user.id,
+		FullName:   user.fullName,
+		Email:      user.email,
+		Role:       user.role,
+		CreatedAt:  user.createdAt,
+		UpdatedAt:  user.updatedAt,
+		VerifiedAt: user.verifiedAt,
+		DeletedAt:  user.deletedAt,
+	}, nil
+}
+
+// sessions and tokens
+
+func (s *MemoryStore) CreateSession(ctx context.Context, jti string, userId int, userAgent, ip string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return ErrUserNotFound
+	}
+	now := time.Now()
+	s.sessions[jti] = &memSession{
+		SessionDB: SessionDB{ID: jti, UserAgent: userAgent, IPAddress: ip, CreatedAt: now, LastSeenAt: now, ExpiresAt: expiresAt},
+		userId:    userId,
+	}
+	return nil
+}
+
+func (s *MemoryStore) IsSessionActive(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[jti]
+	if !ok {
+		return false, nil
+	}
+	return session.revokedAt == nil && session.ExpiresAt.After(time.Now()), nil
+}
+
+func (s *MemoryStore) GetActiveSessions(ctx context.Context, userId int) ([]SessionDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var sessions []SessionDB
+	for _, session := range s.sessions {
+		if session.userId == userId && session.revokedAt == nil && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session.SessionDB)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+func (s *MemoryStore) RevokeSession(ctx context.Context, userId int, jti string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[jti]
+	if !ok || session.userId != userId || session.revokedAt != nil {
+		return ErrSessionNotFound
+	}
+	session.revokedAt = timePtr(time.Now())
+	s.revokeRefreshTokens(func(token *memRefreshToken) bool { return token.familyId == jti })
+	return nil
+}
+
+func (s *MemoryStore) RevokeAllSessions(ctx context.Context, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeAllSessions(userId)
+	return nil
+}
+
+func (s *MemoryStore) revokeAllSessions(userId int) {
+	now := time.Now()
+	for _, session := range s.sessions {
+		if session.userId == userId && session.revokedAt == nil {
+			session.revokedAt = &now
+		}
+	}
+	s.revokeRefreshTokens(func(token *memRefreshToken) bool { return token.userId == userId })
+}
+
+func (s *MemoryStore) revokeRefreshTokens(match func(*memRefreshToken) bool) {
+	now := time.Now()
+	for _, token := range s.refreshTokens {
+		if token.revokedAt == nil && match(token) {
+			token.revokedAt = &now
+		}
+	}
+}
+
+func (s *MemoryStore) CreateRefreshToken(ctx context.Context, userId int, familyId, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := s.refreshTokens[tokenHash]; ok {
+		return fmt.Errorf("refresh token already exists")
+	}
+	s.refreshTokens[tokenHash] = &memRefreshToken{familyId: familyId, userId: userId, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.refreshTokens[oldHash]
+	if !ok || old.revokedAt != nil {
+		return "", "", ErrRefreshTokenInvalid
+	}
+	now := time.Now()
+
+	// reuse detection
+	if old.usedAt != nil {
+		s.revokeRefreshTokens(func(token *memRefreshToken) bool { return token.familyId == old.familyId })
+		if session, ok := s.sessions[old.familyId]; ok && session.revokedAt == nil {
+			session.revokedAt = &now
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+
+	if now.After(old.expiresAt) {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	old.usedAt = &now
+	s.refreshTokens[newHash] = &memRefreshToken{familyId: old.familyId, userId: old.userId, expiresAt: expiresAt}
+	if session, ok := s.sessions[old.familyId]; ok {
+		session.LastSeenAt = now
+		session.ExpiresAt = expiresAt
+	}
+	return s.users[old.userId].email, old.familyId, nil
+}
+
+func (s *MemoryStore) RevokeRefreshTokenFamily(ctx context.Context, tokenHash string) (int, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return 0, "", nil // nothing to revoke
+	}
+	s.revokeRefreshTokens(func(t *memRefreshToken) bool { return t.familyId == token.familyId })
+	if session, ok := s.sessions[token.familyId]; ok && session.revokedAt == nil {
+		session.revokedAt = timePtr(time.Now())
+	}
+	return token.userId, token.familyId, nil
+}
+
+// password reset and email verification
+
+func (s *MemoryStore) CreatePasswordResetToken(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createOneTimeToken(s.resetTokens, userId, "", tokenHash, expiresAt)
+}
+
+// createOneTimeToken invalidates the user's unused tokens and stores the new one
+func (s *MemoryStore) createOneTimeToken(tokens map[string]*memOneTimeToken, userId int, email, tokenHash string, expiresAt time.Time) error {
+	if _, ok := s.users[userId]; !ok {
+		return ErrUserNotFound
+	}
+	now := time.Now()
+	for _, token := range tokens {
+		if token.userId == userId && token.usedAt == nil {
+			token.usedAt = &now
+		}
+	}
+	tokens[tokenHash] = &memOneTimeToken{userId: userId, email: email, expiresAt: expiresAt}
+	return nil
+}
+
+// useOneTimeToken marks a valid token as used and returns it
+func useOneTimeToken(tokens map[string]*memOneTimeToken, tokenHash string) (*memOneTimeToken, bool) {
+	token, ok := tokens[tokenHash]
+	now := time.Now()
+	if !ok || token.usedAt != nil || now.After(token.expiresAt) {
+		return nil, false
+	}
+	token.usedAt = &now
+	return token, true
+}
+
+func (s *MemoryStore) GetPasswordResetUser(ctx context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.resetTokens[tokenHash]
+	if !ok || token.usedAt != nil || time.Now().After(token.expiresAt) {
+		return 0, ErrResetTokenInvalid
+	}
+	return token.userId, nil
+}
+
+func (s *MemoryStore) ResetPasswordWithToken(ctx context.Context, tokenHash, password string) (int, error) {
+	passwordHash, err := jaegerpassword.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := useOneTimeToken(s.resetTokens, tokenHash)
+	if !ok {
+		return 0, ErrResetTokenInvalid
+	}
+	user := s.users[token.userId]
+	user.password = passwordHash
+	user.passwordResetRequired = false
+	user.updatedAt = time.Now()
+	for _, apiToken := range s.apiTokens {
+		if apiToken.userId == user.id && apiToken.revokedAt == nil {
+			apiToken.revokedAt = timePtr(user.updatedAt)
+		}
+	}
+	return user.id, nil
+}
+
+func (s *MemoryStore) CreateEmailVerificationToken(ctx context.Context, userId int, email, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createOneTimeToken(s.verifyTokens, userId, email, tokenHash, expiresAt)
+}
+
+func (s *MemoryStore) VerifyEmailWithToken(ctx context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.verifyTokens[tokenHash]
+	if !ok || token.usedAt != nil || time.Now().After(token.expiresAt) {
+		return 0, ErrVerificationTokenInvalid
+	}
+	user := s.users[token.userId]
+	if user.email != token.email { // email changed since the token was sent, the token stays unused like in a rolled back transaction
+		return 0, ErrVerificationTokenInvalid
+	}
+	useOneTimeToken(s.verifyTokens, tokenHash)
+	user.verifiedAt = timePtr(time.Now())
+	return user.id, nil
+}
+
+// two factor authentication
+
+func (s *MemoryStore) GetTOTPState(ctx context.Context, userId int) (TOTPStateDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok {
+		return TOTPStateDB{}, ErrUserNotFound
+	}
+	state := TOTPStateDB{Secret: user.totpSecret, Enabled: user.totpEnabledAt != nil}
+	if user.totpLastCounter != nil {
+		state.LastCounter = *user.totpLastCounter
+	}
+	for _, code := range s.recoveryCodes {
+		if code.userId == userId && code.usedAt == nil {
+			state.RecoveryCodesLeft++
+		}
+	}
+	return state, nil
+}
+
+func (s *MemoryStore) SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok || user.totpEnabledAt != nil {
+		return ErrTOTPAlreadyEnabled
+	}
+	user.totpSecret = secret
+	user.totpLastCounter = nil
+	return nil
+}
+
+func (s *MemoryStore) EnableTOTP(ctx context.Context, userId int, counter int64, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok || user.totpSecret == "" || user.totpEnabledAt != nil {
+		return ErrTOTPAlreadyEnabled
+	}
+	user.totpEnabledAt = timePtr(time.Now())
+	user.totpLastCounter = &counter
+	s.replaceRecoveryCodes(userId, recoveryCodeHashes)
+	return nil
+}
+
+func (s *MemoryStore) RegenerateRecoveryCodes(ctx context.Context, userId int, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replaceRecoveryCodes(userId, recoveryCodeHashes)
+	return nil
+}
+
+func (s *MemoryStore) replaceRecoveryCodes(userId int, recoveryCodeHashes []string) {
+	s.deleteRecoveryCodes(userId)
+	for _, hash := range recoveryCodeHashes {
+		s.recoveryCodes = append(s.recoveryCodes, &memRecoveryCode{userId: userId, codeHash: hash})
+	}
+}
+
+func (s *MemoryStore) deleteRecoveryCodes(userId int) {
+	kept := s.recoveryCodes[:0]
+	for _, code := range s.recoveryCodes {
+		if code.userId != userId {
+			kept = append(kept, code)
+		}
+	}
+	s.recoveryCodes = kept
+}
+
+func (s *MemoryStore) UseTOTPCounter(ctx context.Context, userId int, counter int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok || (user.totpLastCounter != nil && *user.totpLastCounter >= counter) {
+		return ErrTOTPCodeReused
+	}
+	user.totpLastCounter = &counter
+	return nil
+}
+
+func (s *MemoryStore) UseRecoveryCode(ctx context.Context, userId int, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.recoveryCodes {
+		if code.userId == userId && code.codeHash == codeHash && code.usedAt == nil {
+			code.usedAt = timePtr(time.Now())
+			return nil
+		}
+	}
+	return ErrRecoveryCodeInvalid
+}
+
+func (s *MemoryStore) DisableTOTP(ctx context.Context, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userId]; ok {
+		user.totpSecret = ""
+		user.totpEnabledAt = nil
+		user.totpLastCounter = nil
+	}
+	s.deleteRecoveryCodes(userId)
+	return nil
+}
+
+// external identities
+
+func (s *MemoryStore) GetUserByIdentity(ctx context.Context, provider, subject string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.subject == subject {
+			identity.LastLoginAt = time.Now()
+			return s.users[identity.userId].email, nil
+		}
+	}
+	return "", ErrIdentityNotFound
+}
+
+func (s *MemoryStore) LinkIdentity(ctx context.Context, userId int, provider, subject, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.linkIdentity(userId, provider, subject, email)
+}
+
+func (s *MemoryStore) linkIdentity(userId int, provider, subject, email string) error {
+	if _, ok := s.users[userId]; !ok {
+		return ErrUserNotFound
+	}
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.subject == subject {
+			return fmt.Errorf("identity %s/%s is already linked", provider, subject)
+		}
+	}
+	now := time.Now()
+	s.identities = append(s.identities, &memIdentity{
+		IdentityDB: IdentityDB{ID: s.nextId("user_identities"), Provider: provider, Email: email, CreatedAt: now, LastLoginAt: now},
+		subject:    subject,
+		userId:     userId,
+	})
+	return nil
+}
+
+func (s *MemoryStore) CreateExternalUser(ctx context.Context, fullName, email string, verified bool, provider, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userByEmail(email) != nil {
+		return ErrUserExists
+	}
+	now := time.Now()
+	user := &memUser{fullName: fullName, email: email, role: RoleUser, createdAt: now, updatedAt: now}
+	if verified {
+		user.verifiedAt = &now
+	}
+	user.id = s.nextId("users")
+	s.users[user.id] = user
+	if err := s.linkIdentity(user.id, provider, subject, email); err != nil {
+		delete(s.users, user.id)
+		return err
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetUserIdentities(ctx context.Context, userId int) ([]IdentityDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var identities []IdentityDB
+	for _, identity := range s.identities {
+		if identity.userId == userId {
+			identities = append(identities, identity.IdentityDB)
+		}
+	}
+	return identities, nil
+}
+
+func (s *MemoryStore) UnlinkIdentity(ctx context.Context, userId, identityId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, identity := range s.identities {
+		if identity.ID == identityId && identity.userId == userId {
+			s.identities = append(s.identities[:i], s.identities[i+1:]...)
+			return nil
+		}
+	}
+	return ErrIdentityNotFound
+}
+
+// personal access tokens
+
+func (s *MemoryStore) CreateAPIToken(ctx context.Context, userId int, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (APITokenDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return APITokenDB{}, ErrUserNotFound
+	}
+	token := &memAPIToken{
+		APITokenDB: APITokenDB{ID: s.nextId("api_tokens"), Name: name, Prefix: prefix, Scopes: scopes, CreatedAt: time.Now(), ExpiresAt: expiresAt},
+		userId:     userId,
+		tokenHash:  tokenHash,
+	}
+	s.apiTokens = append(s.apiTokens, token)
+	return token.APITokenDB, nil
+}
+
+func (s *MemoryStore) GetAPITokens(ctx context.Context, userId int) ([]APITokenDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []APITokenDB
+	for i := len(s.apiTokens) - 1; i >= 0; i-- { // newest first
+		if token := s.apiTokens[i]; token.userId == userId && token.revokedAt == nil {
+			tokens = append(tokens, token.APITokenDB)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *MemoryStore) RevokeAPIToken(ctx context.Context, userId, tokenId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.apiTokens {
+		if token.ID == tokenId && token.userId == userId && token.revokedAt == nil {
+			token.revokedAt = timePtr(time.Now())
+			return nil
+		}
+	}
+	return ErrAPITokenNotFound
+}
+
+func (s *MemoryStore) AuthenticateAPIToken(ctx context.Context, tokenHash string) (string, int, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range s.apiTokens {
+		if token.tokenHash != tokenHash || token.revokedAt != nil || (token.ExpiresAt != nil && !token.ExpiresAt.After(now)) {
+			continue
+		}
+		token.LastUsedAt = &now
+		return s.users[token.userId].email, token.ID, token.Scopes, nil
+	}
+	return "", 0, nil, ErrAPITokenInvalid
+}
+
+// login throttling
+
+func (s *MemoryStore) RecordLoginAttempt(ctx context.Context, email, ip, userAgent string, succeeded bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := &memLoginAttempt{
+		LoginAttemptDB: LoginAttemptDB{ID: s.nextId("login_attempts"), IPAddress: ip, UserAgent: userAgent, Succeeded: succeeded, AttemptedAt: time.Now()},
+		email:          email,
+	}
+	for _, user := range s.sortedUsers() {
+		if strings.ToLower(user.email) == email {
+			attempt.userId = user.id
+			break
+		}
+	}
+	s.loginAttempts = append(s.loginAttempts, attempt)
+	return nil
+}
+
+func (s *MemoryStore) GetLoginFailures(ctx context.Context, email, ip string, since time.Time) (LoginFailuresDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// for the account a successful login starts the count over
+	accountSince := since
+	for _, attempt := range s.loginAttempts {
+		if attempt.email == email && attempt.Succeeded && attempt.AttemptedAt.After(accountSince) {
+			accountSince = attempt.AttemptedAt
+		}
+	}
+
+	var failures LoginFailuresDB
+	for _, attempt := range s.loginAttempts {
+		if attempt.Succeeded {
+			continue
+		}
+		at := attempt.AttemptedAt
+		if attempt.email == email && at.After(accountSince) {
+			failures.AccountFailures++
+			if failures.AccountLastFailure == nil || at.After(*failures.AccountLastFailure) {
+				failures.AccountLastFailure = timePtr(at)
+			}
+		}
+		if attempt.IPAddress == ip && at.After(since) {
+			failures.IPFailures++
+			if failures.IPLastFailure == nil || at.After(*failures.IPLastFailure) {
+				failures.IPLastFailure = timePtr(at)
+			}
+		}
+	}
+	return failures, nil
+}
+
+func (s *MemoryStore) GetRecentLoginFailures(ctx context.Context, userId, limit int) ([]LoginAttemptDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts []LoginAttemptDB
+	for i := len(s.loginAttempts) - 1; i >= 0 && len(attempts) < limit; i-- {
+		if attempt := s.loginAttempts[i]; attempt.userId == userId && !attempt.Succeeded {
+			attempts = append(attempts, attempt.LoginAttemptDB)
+		}
+	}
+	return attempts, nil
+}
+
+func (s *MemoryStore) GetLoginHistory(ctx context.Context, userId int) ([]LoginAttemptDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts []LoginAttemptDB
+	for i := len(s.loginAttempts) - 1; i >= 0; i-- {
+		if attempt := s.loginAttempts[i]; attempt.userId == userId {
+			attempts = append(attempts, attempt.LoginAttemptDB)
+		}
+	}
+	return attempts, nil
+}
+
+// administration and account deletion
+
+func (s *MemoryStore) GetUsersPage(ctx context.Context, limit, offset int) ([]AdminUserDB, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := s.sortedUsers()
+	var users []AdminUserDB
+	for i := offset; i < len(all) && len(users) < limit; i++ {
+		user := all[i]
+		users = append(users, AdminUserDB{
+			ID:                    user.id,
+			FullName:              user.fullName,
+			Email:                 user.email,
+			Role:                  user.role,
+			Verified:              user.verifiedAt != nil,
+			DisabledAt:            user.disabledAt,
+			PasswordResetRequired: user.passwordResetRequired,
+			CreatedAt:             user.createdAt,
+		})
+	}
+	return users, len(all), nil
+}
+
+func (s *MemoryStore) SetUserDisabled(ctx context.Context, userId int, disabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok {
+		return ErrUserNotFound
+	}
+	now := time.Now()
+	user.updatedAt = now
+	if !disabled {
+		user.disabledAt = nil
+		return nil
+	}
+	if user.disabledAt == nil {
+		user.disabledAt = &now
+	}
+	s.revokeAllSessions(userId)
+	return nil
+}
+
+func (s *MemoryStore) RequirePasswordReset(ctx context.Context, userId int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok {
+		return "", ErrUserNotFound
+	}
+	user.passwordResetRequired = true
+	user.updatedAt = time.Now()
+	s.revokeAllSessions(userId)
+	return user.email, nil
+}
+
+func (s *MemoryStore) ScheduleUserDeletion(ctx context.Context, userId int) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userId]
+	if !ok {
+		return time.Time{}, ErrUserNotFound
+	}
+	if user.deletedAt == nil {
+		user.deletedAt = timePtr(time.Now())
+	}
+	s.revokeAllSessions(userId)
+	return *user.deletedAt, nil
+}
+
+func (s *MemoryStore) CancelUserDeletion(ctx context.Context, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userId]; ok {
+		user.deletedAt = nil
+	}
+	return nil
+}
+
+// PurgeDeletedUsers removes the users together with everything the foreign keys would cascade to
+func (s *MemoryStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, user := range s.users {
+		if user.deletedAt == nil || !user.deletedAt.Before(deletedBefore) {
+			continue
+		}
+		s.deleteUserData(id)
+		delete(s.users, id)
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *MemoryStore) deleteUserData(userId int) {
+	for id, note := range s.notes {
+		if note.userId == userId {
+			delete(s.notes, id)
+		}
+	}
+	s.statusHistory = deleteWhere(s.statusHistory, func(change *memStatusChange) bool { return change.userId == userId })
+	s.interviews = deleteWhere(s.interviews, func(interview *memInterview) bool { return interview.userId == userId })
+	for id, contact := range s.contacts {
+		if contact.userId == userId {
+			s.deleteContact(id)
+		}
+	}
+	for id, company := range s.companies {
+		if company.userId == userId {
+			delete(s.companies, id)
+		}
+	}
+	s.aliases = deleteWhere(s.aliases, func(alias *memCompanyAlias) bool { return alias.userId == userId })
+	for id, tag := range s.tags {
+		if tag.userId == userId {
+			s.deleteTag(id)
+		}
+	}
+	for jti, session := range s.sessions {
+		if session.userId == userId {
+			delete(s.sessions, jti)
+		}
+	}
+	for hash, token := range s.refreshTokens {
+		if token.userId == userId {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for _, tokens := range []map[string]*memOneTimeToken{s.resetTokens, s.verifyTokens} {
+		for hash, token := range tokens {
+			if token.userId == userId {
+				delete(tokens, hash)
+			}
+		}
+	}
+	s.deleteRecoveryCodes(userId)
+	s.identities = deleteWhere(s.identities, func(identity *memIdentity) bool { return identity.userId == userId })
+	s.apiTokens = deleteWhere(s.apiTokens, func(token *memAPIToken) bool { return token.userId == userId })
+	s.auditEvents = deleteWhere(s.auditEvents, func(event *memAuditEvent) bool { return event.userId == userId })
+	s.loginAttempts = deleteWhere(s.loginAttempts, func(attempt *memLoginAttempt) bool { return attempt.userId == userId })
+}
+
+func deleteWhere[T any](rows []T, match func(T) bool) []T {
+	kept := rows[:0]
+	for _, row := range rows {
+		if !match(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// audit log
+
+func (s *MemoryStore) RecordAuditEvent(ctx context.Context, userId, actorId int, action, target, details, ip, userAgent string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return ErrUserNotFound
+	}
+	event := &memAuditEvent{
+		AuditEventDB: AuditEventDB{ID: s.nextId("audit_events"), Action: action, Target: target, Details: details, IPAddress: ip, UserAgent: userAgent, CreatedAt: time.Now()},
+		userId:       userId,
+	}
+	if actorId != 0 { // 0 when nobody in particular caused it
+		event.ActorID = &actorId
+	}
+	s.auditEvents = append(s.auditEvents, event)
+	return nil
+}
+
+func (s *MemoryStore) GetAuditEvents(ctx context.Context, userId, limit, offset int) ([]AuditEventDB, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var all []AuditEventDB
+	for i := len(s.auditEvents) - 1; i >= 0; i-- { // newest first
+		if event := s.auditEvents[i]; event.userId == userId {
+			all = append(all, event.AuditEventDB)
+		}
+	}
+	if offset >= len(all) {
+		return nil, len(all), nil
+	}
+	end := min(offset+limit, len(all))
+	return all[offset:end], len(all), nil
+}
+
+// notes
+
+func (s *MemoryStore) CreateNote(ctx context.Context, uuid, companyName, position, salary, applicationStatus, appliedOn, description string, userId int) (int, error) {
+	status, err := newNoteStatus(applicationStatus)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := parseAppliedOn(appliedOn)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return 0, ErrUserNotFound
+	}
+	id := s.nextId("notes")
+	s.notes[id] = &memNote{
+		companyId:         s.resolveCompany(userId, companyName),
+		id:                id,
+		uuid:              uuid,
+		companyName:       companyName,
+		position:          position,
+		salary:            salary,
+		applicationStatus: status,
+		appliedOn:         applied,
+		userId:            userId,
+		updatedAt:         time.Now(),
+		description:       description,
+	}
+	s.recordStatusChange(s.notes[id], "", status)
+	return id, nil
+}
+
+func (s *MemoryStore) recordStatusChange(note *memNote, from, to string) {
+	change := &memStatusChange{
+		NoteStatusChangeDB: NoteStatusChangeDB{ID: s.nextId("note_status_history"), NoteID: note.id, ToStatus: to, ChangedAt: time.Now()},
+		userId:             note.userId,
+	}
+	if from != "" {
+		change.FromStatus = &from
+	}
+	s.statusHistory = append(s.statusHistory, change)
+}
+
+func (n *memNote) toNoteDB() NoteDB {
+	return NoteDB{
+		Id:                n.id,
+		Uuid:              n.uuid,
+		CompanyName:       n.companyName,
+		Position:          n.position,
+		Salary:            n.salary,
+		ApplicationStatus: n.applicationStatus,
+		AppliedOn:         n.appliedOn.Format("2006-01-02 15:04:05"),
+		UserId:            fmt.Sprint(n.userId),
+		UpdatedAt:         n.updatedAt.Format("2006-01-02 15:04:05"),
+		Description:       n.description,
+	}
+}
+
+func (s *MemoryStore) GetAllUserNotes(ctx context.Context, id int, filter NoteFilter) ([]NoteDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var notes []NoteDB
+	for _, note := range s.notes {
+		if note.userId != id {
+			continue
+		}
+		tags := s.noteTagsOf(note.id)
+		tagIds := make([]int, len(tags))
+		for i, tag := range tags {
+			tagIds[i] = tag.ID
+		}
+		if filter.matches(tagIds) {
+			stored := note.toNoteDB()
+			stored.Tags = tags
+			notes = append(notes, stored)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Id < notes[j].Id })
+	return notes, nil
+}
+
+func (s *MemoryStore) UpdateNote(ctx context.Context, userId, id int, company, pos, sal, appStat, appOn, desc string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok || note.userId != userId {
+		return ErrNoteNotFound
+	}
+
+	status, err := nextNoteStatus(note.applicationStatus, appStat)
+	if err != nil {
+		return err
+	}
+	if status != note.applicationStatus {
+		s.recordStatusChange(note, note.applicationStatus, status)
+	}
+
+	// the applied on date keeps the time of the update, same as the Postgres implementation
+	appliedOnDate, _ := time.Parse("2006-01-02", appOn)
+	now := time.Now()
+	if note.companyName != company {
+		note.companyId = s.resolveCompany(userId, company)
+	}
+	note.companyName = company
+	note.position = pos
+	note.salary = sal
+	note.applicationStatus = status
+	note.appliedOn = time.Date(appliedOnDate.Year(), appliedOnDate.Month(), appliedOnDate.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+	note.description = desc
+	note.updatedAt = now
+	return nil
+}
+
+func (s *MemoryStore) GetUpdatedNote(ctx context.Context, userId, id int) (NoteDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok || note.userId != userId {
+		return NoteDB{}, ErrNoteNotFound
+	}
+	return note.toNoteDB(), nil
+}
+
+func (s *MemoryStore) DeleteNote(ctx context.Context, userId, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok || note.userId != userId {
+		return ErrNoteNotFound
+	}
+	delete(s.notes, id)
+	s.statusHistory = deleteWhere(s.statusHistory, func(change *memStatusChange) bool { return change.NoteID == id })
+	s.interviews = deleteWhere(s.interviews, func(interview *memInterview) bool { return interview.NoteID == id })
+	s.noteContacts = deleteWhere(s.noteContacts, func(link memNoteContact) bool { return link.noteId == id })
+	s.noteTags = deleteWhere(s.noteTags, func(link memNoteTag) bool { return link.noteId == id })
+	return nil
+}
+
+// status timeline
+
+func (s *MemoryStore) GetNoteStatusHistory(ctx context.Context, userId, noteId int) ([]NoteStatusChangeDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteId]; !ok || note.userId != userId {
+		return nil, ErrNoteNotFound
+	}
+	var changes []NoteStatusChangeDB
+	for _, change := range s.statusHistory { // appended in order, oldest first
+		if change.NoteID == noteId {
+			changes = append(changes, change.NoteStatusChangeDB)
+		}
+	}
+	return changes, nil
+}
+
+func (s *MemoryStore) GetStatusHistory(ctx context.Context, userId int) ([]NoteStatusChangeDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []NoteStatusChangeDB
+	for _, change := range s.statusHistory {
+		if change.userId == userId {
+			changes = append(changes, change.NoteStatusChangeDB)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].NoteID < changes[j].NoteID })
+	return changes, nil
+}
+
+// interviews
+
+// stored returns the interview like the database would, in its time zone and without sharing the interviewers
+func (i *memInterview) stored() InterviewDB {
+	interview := i.InterviewDB
+	interview.Interviewers = append([]string{}, i.Interviewers...)
+	interview.inTimeZone()
+	return interview
+}
+
+func (s *MemoryStore) interview(userId, noteId, interviewId int) *memInterview {
+	for _, interview := range s.interviews {
+		if interview.ID == interviewId && interview.NoteID == noteId && interview.userId == userId {
+			return interview
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteId]; !ok || note.userId != userId {
+		return InterviewDB{}, ErrNoteNotFound
+	}
+	now := time.Now()
+	interview.ID = s.nextId("interviews")
+	interview.NoteID = noteId
+	interview.StartsAt = interview.StartsAt.UTC()
+	interview.EndsAt = utcPtr(interview.EndsAt)
+	interview.Interviewers = append([]string{}, interview.Interviewers...)
+	interview.CreatedAt, interview.UpdatedAt = now, now
+	created := &memInterview{InterviewDB: interview, userId: userId}
+	s.interviews = append(s.interviews, created)
+	return created.stored(), nil
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func (s *MemoryStore) GetNoteInterviews(ctx context.Context, userId, noteId int) ([]InterviewDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteId]; !ok || note.userId != userId {
+		return nil, ErrNoteNotFound
+	}
+	var interviews []InterviewDB
+	for _, interview := range s.interviews {
+		if interview.NoteID == noteId {
+			interviews = append(interviews, interview.stored())
+		}
+	}
+	sortInterviews(interviews)
+	return interviews, nil
+}
+
+func sortInterviews(interviews []InterviewDB) {
+	sort.Slice(interviews, func(i, j int) bool {
+		if !interviews[i].StartsAt.Equal(interviews[j].StartsAt) {
+			return interviews[i].StartsAt.Before(interviews[j].StartsAt)
+		}
+		return interviews[i].ID < interviews[j].ID
+	})
+}
+
+func (s *MemoryStore) GetInterview(ctx context.Context, userId, noteId, interviewId int) (InterviewDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	interview := s.interview(userId, noteId, interviewId)
+	if interview == nil {
+		return InterviewDB{}, ErrInterviewNotFound
+	}
+	return interview.stored(), nil
+}
+
+func (s *MemoryStore) UpdateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.interview(userId, noteId, interview.ID)
+	if existing == nil {
+		return InterviewDB{}, ErrInterviewNotFound
+	}
+	existing.Round = interview.Round
+	existing.Type = interview.Type
+	existing.StartsAt = interview.StartsAt.UTC()
+	existing.EndsAt = utcPtr(interview.EndsAt)
+	existing.TimeZone = interview.TimeZone
+	existing.Location = interview.Location
+	existing.VideoLink = interview.VideoLink
+	existing.Interviewers = append([]string{}, interview.Interviewers...)
+	existing.Outcome = interview.Outcome
+	existing.PrepNotes = interview.PrepNotes
+	existing.UpdatedAt = time.Now()
+	return existing.stored(), nil
+}
+
+func (s *MemoryStore) DeleteInterview(ctx context.Context, userId, noteId, interviewId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.interview(userId, noteId, interviewId) == nil {
+		return ErrInterviewNotFound
+	}
+	s.interviews = deleteWhere(s.interviews, func(interview *memInterview) bool { return interview.ID == interviewId })
+	return nil
+}
+
+func (s *MemoryStore) GetUpcomingInterviews(ctx context.Context, userId int, from, until time.Time) ([]UpcomingInterviewDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var interviews []InterviewDB
+	for _, interview := range s.interviews {
+		if interview.userId == userId && !interview.StartsAt.Before(from) && interview.StartsAt.Before(until) && interview.Outcome != OutcomeCancelled {
+			interviews = append(interviews, interview.stored())
+		}
+	}
+	sortInterviews(interviews)
+
+	var upcoming []UpcomingInterviewDB
+	for _, interview := range interviews {
+		note := s.notes[interview.NoteID]
+		upcoming = append(upcoming, UpcomingInterviewDB{InterviewDB: interview, CompanyName: note.companyName, Position: note.position, ApplicationStatus: note.applicationStatus})
+	}
+	return upcoming, nil
+}
+
+func (s *MemoryStore) GetUserInterviews(ctx context.Context, userId int) ([]InterviewDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var interviews []InterviewDB
+	for _, interview := range s.interviews {
+		if interview.userId == userId {
+			interviews = append(interviews, interview.stored())
+		}
+	}
+	sortInterviews(interviews)
+	sort.SliceStable(interviews, func(i, j int) bool { return interviews[i].NoteID < interviews[j].NoteID })
+	return interviews, nil
+}
+
+// contacts
+
+// withNotes returns the contact together with the notes it's linked to
+func (s *MemoryStore) withNotes(contact *memContact) ContactDB {
+	stored := contact.ContactDB
+	stored.NoteIDs = []int{}
+	for _, link := range s.noteContacts {
+		if link.contactId == contact.ID {
+			stored.NoteIDs = append(stored.NoteIDs, link.noteId)
+		}
+	}
+	sort.Ints(stored.NoteIDs)
+	return stored
+}
+
+func (s *MemoryStore) contact(userId, contactId int) *memContact {
+	contact, ok := s.contacts[contactId]
+	if !ok || contact.userId != userId {
+		return nil
+	}
+	return contact
+}
+
+func (s *MemoryStore) deleteContact(contactId int) {
+	delete(s.contacts, contactId)
+	s.noteContacts = deleteWhere(s.noteContacts, func(link memNoteContact) bool { return link.contactId == contactId })
+}
+
+func sortContacts(contacts []ContactDB) {
+	sort.Slice(contacts, func(i, j int) bool {
+		a, b := strings.ToLower(contacts[i].Name), strings.ToLower(contacts[j].Name)
+		if a != b {
+			return a < b
+		}
+		return contacts[i].ID < contacts[j].ID
+	})
+}
+
+func (s *MemoryStore) CreateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return ContactDB{}, ErrUserNotFound
+	}
+	now := time.Now()
+	contact.ID = s.nextId("contacts")
+	contact.NoteIDs = nil // the links are kept in noteContacts
+	contact.CreatedAt, contact.UpdatedAt = now, now
+	created := &memContact{ContactDB: contact, userId: userId}
+	s.contacts[contact.ID] = created
+	return s.withNotes(created), nil
+}
+
+func (s *MemoryStore) GetContacts(ctx context.Context, userId int) ([]ContactDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var contacts []ContactDB
+	for _, contact := range s.contacts {
+		if contact.userId == userId {
+			contacts = append(contacts, s.withNotes(contact))
+		}
+	}
+	sortContacts(contacts)
+	return contacts, nil
+}
+
+func (s *MemoryStore) GetContact(ctx context.Context, userId, contactId int) (ContactDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contact := s.contact(userId, contactId)
+	if contact == nil {
+		return ContactDB{}, ErrContactNotFound
+	}
+	return s.withNotes(contact), nil
+}
+
+func (s *MemoryStore) UpdateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.contact(userId, contact.ID)
+	if existing == nil {
+		return ContactDB{}, ErrContactNotFound
+	}
+	existing.Name = contact.Name
+	existing.Role = contact.Role
+	existing.Email = contact.Email
+	existing.Phone = contact.Phone
+	existing.LinkedInURL = contact.LinkedInURL
+	existing.Notes = contact.Notes
+	existing.UpdatedAt = time.Now()
+	return s.withNotes(existing), nil
+}
+
+func (s *MemoryStore) DeleteContact(ctx context.Context, userId, contactId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.contact(userId, contactId) == nil {
+		return ErrContactNotFound
+	}
+	s.deleteContact(contactId)
+	return nil
+}
+
+func (s *MemoryStore) LinkContact(ctx context.Context, userId, noteId, contactId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteId]; !ok || note.userId != userId {
+		return ErrNoteNotFound
+	}
+	if s.contact(userId, contactId) == nil {
+		return ErrContactNotFound
+	}
+	link := memNoteContact{noteId: noteId, contactId: contactId}
+	if !slices.Contains(s.noteContacts, link) {
+		s.noteContacts = append(s.noteContacts, link)
+	}
+	return nil
+}
+
+func (s *MemoryStore) UnlinkContact(ctx context.Context, userId, noteId, contactId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link := memNoteContact{noteId: noteId, contactId: contactId}
+	if s.contact(userId, contactId) == nil || !slices.Contains(s.noteContacts, link) {
+		return ErrContactNotFound
+	}
+	s.noteContacts = deleteWhere(s.noteContacts, func(l memNoteContact) bool { return l == link })
+	return nil
+}
+
+func (s *MemoryStore) GetNoteContacts(ctx context.Context, userId, noteId int) ([]ContactDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteId]; !ok || note.userId != userId {
+		return nil, ErrNoteNotFound
+	}
+	var contacts []ContactDB
+	for _, link := range s.noteContacts {
+		if contact := s.contact(userId, link.contactId); link.noteId == noteId && contact != nil {
+			contacts = append(contacts, contact.ContactDB)
+		}
+	}
+	sortContacts(contacts)
+	return contacts, nil
+}
+
+func (s *MemoryStore) GetContactNotes(ctx context.Context, userId, contactId int) ([]NoteDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.contact(userId, contactId) == nil {
+		return nil, ErrContactNotFound
+	}
+	var notes []*memNote
+	for _, link := range s.noteContacts {
+		if note, ok := s.notes[link.noteId]; link.contactId == contactId && ok && note.userId == userId {
+			notes = append(notes, note)
+		}
+	}
+	// newest applications first, like the Postgres query
+	sort.Slice(notes, func(i, j int) bool {
+		if !notes[i].appliedOn.Equal(notes[j].appliedOn) {
+			return notes[i].appliedOn.After(notes[j].appliedOn)
+		}
+		return notes[i].id < notes[j].id
+	})
+	var result []NoteDB
+	for _, note := range notes {
+		result = append(result, note.toNoteDB())
+	}
+	return result, nil
+}
+
+// companies
+
+// findCompany returns the id of the user's company with the normalized name or alias, 0 if there is none
+func (s *MemoryStore) findCompany(userId int, normalized string) int {
+	for _, company := range s.companies {
+		if company.userId == userId && company.normalizedName == normalized {
+			return company.ID
+		}
+	}
+	for _, alias := range s.aliases {
+		if alias.userId == userId && alias.normalized == normalized {
+			return alias.companyId
+		}
+	}
+	return 0
+}
+
+// resolveCompany returns the company for a note's company name, creating it when the name is new
+func (s *MemoryStore) resolveCompany(userId int, name string) int {
+	normalized := normalizeCompanyName(name)
+	if normalized == "" {
+		return 0
+	}
+	if id := s.findCompany(userId, normalized); id != 0 {
+		return id
+	}
+	now := time.Now()
+	company := &memCompany{
+		CompanyDB:      CompanyDB{ID: s.nextId("companies"), Name: strings.TrimSpace(name), CreatedAt: now, UpdatedAt: now},
+		userId:         userId,
+		normalizedName: normalized,
+	}
+	s.companies[company.ID] = company
+	return company.ID
+}
+
+// assignCompanies links the user's notes without a company, like the Postgres implementation
+func (s *MemoryStore) assignCompanies(userId int) {
+	for _, note := range s.notes {
+		if note.userId == userId && note.companyId == 0 {
+			note.companyId = s.resolveCompany(userId, note.companyName)
+		}
+	}
+}
+
+func (s *MemoryStore) company(userId, companyId int) *memCompany {
+	company, ok := s.companies[companyId]
+	if !ok || company.userId != userId {
+		return nil
+	}
+	return company
+}
+
+// toCompanyDB fills in the aliases and the number of applications
+func (s *MemoryStore) toCompanyDB(company *memCompany) CompanyDB {
+	stored := company.CompanyDB
+	stored.Aliases = []CompanyAliasDB{}
+	for _, alias := range s.aliases {
+		if alias.companyId == company.ID {
+			stored.Aliases = append(stored.Aliases, alias.CompanyAliasDB)
+		}
+	}
+	for _, note := range s.notes {
+		if note.companyId == company.ID {
+			stored.ApplicationCount++
+		}
+	}
+	return stored
+}
+
+func (s *MemoryStore) addAlias(company *memCompany, alias, normalized string) {
+	s.aliases = append(s.aliases, &memCompanyAlias{
+		CompanyAliasDB: CompanyAliasDB{ID: s.nextId("company_aliases"), Alias: alias},
+		companyId:      company.ID,
+		userId:         company.userId,
+		normalized:     normalized,
+	})
+}
+
+func (s *MemoryStore) CreateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error) {
+	normalized := normalizeCompanyName(company.Name)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return CompanyDB{}, ErrUserNotFound
+	}
+	if s.findCompany(userId, normalized) != 0 {
+		return CompanyDB{}, ErrCompanyExists
+	}
+	now := time.Now()
+	company.ID = s.nextId("companies")
+	company.Aliases, company.ApplicationCount = nil, 0
+	company.CreatedAt, company.UpdatedAt = now, now
+	stored := &memCompany{CompanyDB: company, userId: userId, normalizedName: normalized}
+	s.companies[company.ID] = stored
+	s.assignCompanies(userId)
+	return s.toCompanyDB(stored), nil
+}
+
+func (s *MemoryStore) GetCompanies(ctx context.Context, userId int) ([]CompanyDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assignCompanies(userId)
+	var companies []*memCompany
+	for _, company := range s.companies {
+		if company.userId == userId {
+			companies = append(companies, company)
+		}
+	}
+	sort.Slice(companies, func(i, j int) bool {
+		if companies[i].normalizedName != companies[j].normalizedName {
+			return companies[i].normalizedName < companies[j].normalizedName
+		}
+		return companies[i].ID < companies[j].ID
+	})
+
+	var stored []CompanyDB
+	for _, company := range companies {
+		stored = append(stored, s.toCompanyDB(company))
+	}
+	return stored, nil
+}
+
+func (s *MemoryStore) GetCompany(ctx context.Context, userId, companyId int) (CompanyDetailDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	company := s.company(userId, companyId)
+	if company == nil {
+		return CompanyDetailDB{}, ErrCompanyNotFound
+	}
+	notes := []NoteDB{}
+	for _, note := range s.notes {
+		if note.companyId == companyId {
+			notes = append(notes, note.toNoteDB())
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].AppliedOn != notes[j].AppliedOn {
+			return notes[i].AppliedOn > notes[j].AppliedOn
+		}
+		return notes[i].Id < notes[j].Id
+	})
+	interviews := 0
+	for _, interview := range s.interviews {
+		if note, ok := s.notes[interview.NoteID]; ok && note.companyId == companyId {
+			interviews++
+		}
+	}
+	return CompanyDetailDB{CompanyDB: s.toCompanyDB(company), Stats: companyStats(notes, interviews), Applications: notes}, nil
+}
+
+func (s *MemoryStore) UpdateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error) {
+	normalized := normalizeCompanyName(company.Name)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.company(userId, company.ID)
+	if stored == nil {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+	if normalized != stored.normalizedName {
+		if id := s.findCompany(userId, normalized); id != 0 && id != company.ID {
+			return CompanyDB{}, ErrCompanyExists
+		}
+		// the new name may have been one of the aliases, the old one becomes one
+		s.aliases = deleteWhere(s.aliases, func(alias *memCompanyAlias) bool {
+			return alias.companyId == company.ID && alias.normalized == normalized
+		})
+		s.addAlias(stored, stored.Name, stored.normalizedName)
+	}
+	stored.Name = company.Name
+	stored.normalizedName = normalized
+	stored.Website = company.Website
+	stored.Industry = company.Industry
+	stored.Size = company.Size
+	stored.Location = company.Location
+	stored.UpdatedAt = time.Now()
+	return s.toCompanyDB(stored), nil
+}
+
+func (s *MemoryStore) DeleteCompany(ctx context.Context, userId, companyId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.company(userId, companyId) == nil {
+		return ErrCompanyNotFound
+	}
+	for _, note := range s.notes {
+		if note.companyId == companyId {
+			return ErrCompanyHasNotes
+		}
+	}
+	delete(s.companies, companyId)
+	s.aliases = deleteWhere(s.aliases, func(alias *memCompanyAlias) bool { return alias.companyId == companyId })
+	return nil
+}
+
+func (s *MemoryStore) AddCompanyAlias(ctx context.Context, userId, companyId int, alias string) (CompanyDB, error) {
+	normalized := normalizeCompanyName(alias)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	company := s.company(userId, companyId)
+	if company == nil {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+	switch otherId := s.findCompany(userId, normalized); {
+	case otherId == 0:
+	case otherId == companyId:
+		return s.toCompanyDB(company), nil // the company already goes by that name
+	case s.companies[otherId].normalizedName != normalized:
+		return CompanyDB{}, ErrCompanyExists // an alias of another company
+	default:
+		// merge the other company into this one
+		for _, note := range s.notes {
+			if note.companyId == otherId {
+				note.companyId = companyId
+			}
+		}
+		for _, alias := range s.aliases {
+			if alias.companyId == otherId {
+				alias.companyId = companyId
+			}
+		}
+		delete(s.companies, otherId)
+	}
+	s.addAlias(company, alias, normalized)
+	s.assignCompanies(userId)
+	return s.toCompanyDB(company), nil
+}
+
+func (s *MemoryStore) DeleteCompanyAlias(ctx context.Context, userId, companyId, aliasId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, alias := range s.aliases {
+		if alias.ID == aliasId && alias.companyId == companyId && alias.userId == userId {
+			s.aliases = slices.Delete(s.aliases, i, i+1)
+			return nil
+		}
+	}
+	return ErrCompanyAliasNotFound
+}
+
+// tags
+
+func (s *MemoryStore) tag(userId, tagId int) *memTag {
+	tag, ok := s.tags[tagId]
+	if !ok || tag.userId != userId {
+		return nil
+	}
+	return tag
+}
+
+func (s *MemoryStore) tagNameTaken(userId, tagId int, name string) bool {
+	for _, tag := range s.tags {
+		if tag.userId == userId && tag.ID != tagId && strings.EqualFold(tag.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) deleteTag(tagId int) {
+	delete(s.tags, tagId)
+	s.noteTags = deleteWhere(s.noteTags, func(link memNoteTag) bool { return link.tagId == tagId })
+}
+
+// noteTagsOf returns the tags on the note by name
+func (s *MemoryStore) noteTagsOf(noteId int) []NoteTagDB {
+	var tags []NoteTagDB
+	for _, link := range s.noteTags {
+		if link.noteId == noteId {
+			tag := s.tags[link.tagId]
+			tags = append(tags, NoteTagDB{ID: tag.ID, Name: tag.Name, Color: tag.Color})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := strings.ToLower(tags[i].Name), strings.ToLower(tags[j].Name)
+		if a != b {
+			return a < b
+		}
+		return tags[i].ID < tags[j].ID
+	})
+	return tags
+}
+
+// withNoteCount counts the notes the tag is on
+func (s *MemoryStore) withNoteCount(tag *memTag) TagDB {
+	stored := tag.TagDB
+	stored.NoteCount = 0
+	for _, link := range s.noteTags {
+		if link.tagId == tag.ID {
+			stored.NoteCount++
+		}
+	}
+	return stored
+}
+
+func (s *MemoryStore) CreateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		return TagDB{}, ErrUserNotFound
+	}
+	if s.tagNameTaken(userId, 0, tag.Name) {
+		return TagDB{}, ErrTagExists
+	}
+	now := time.Now()
+	tag.ID = s.nextId("tags")
+	tag.NoteCount = 0
+	tag.CreatedAt, tag.UpdatedAt = now, now
+	s.tags[tag.ID] = &memTag{TagDB: tag, userId: userId}
+	return tag, nil
+}
+
+func (s *MemoryStore) GetTags(ctx context.Context, userId int) ([]TagDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tags []TagDB
+	for _, tag := range s.tags {
+		if tag.userId == userId {
+			tags = append(tags, s.withNoteCount(tag))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := strings.ToLower(tags[i].Name), strings.ToLower(tags[j].Name)
+		if a != b {
+			return a < b
+		}
+		return tags[i].ID < tags[j].ID
+	})
+	return tags, nil
+}
+
+func (s *MemoryStore) UpdateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tagNameTaken(userId, tag.ID, tag.Name) {
+		return TagDB{}, ErrTagExists
+	}
+	stored := s.tag(userId, tag.ID)
+	if stored == nil {
+		return TagDB{}, ErrTagNotFound
+	}
+	stored.Name = tag.Name
+	stored.Color = tag.Color
+	stored.UpdatedAt = time.Now()
+	return s.withNoteCount(stored), nil
+}
+
+func (s *MemoryStore) DeleteTag(ctx context.Context, userId, tagId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tag(userId, tagId) == nil {
+		return ErrTagNotFound
+	}
+	s.deleteTag(tagId)
+	return nil
+}
+
+func (s *MemoryStore) TagNote(ctx context.Context, userId, noteId, tagId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteId]; !ok || note.userId != userId {
+		return ErrNoteNotFound
+	}
+	if s.tag(userId, tagId) == nil {
+		return ErrTagNotFound
+	}
+	link := memNoteTag{noteId: noteId, tagId: tagId}
+	if !slices.Contains(s.noteTags, link) {
+		s.noteTags = append(s.noteTags, link)
+	}
+	return nil
+}
+
+func (s *MemoryStore) UntagNote(ctx context.Context, userId, noteId, tagId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link := memNoteTag{noteId: noteId, tagId: tagId}
+	if s.tag(userId, tagId) == nil || !slices.Contains(s.noteTags, link) {
+		return ErrTagNotFound
+	}
+	s.noteTags = deleteWhere(s.noteTags, func(l memNoteTag) bool { return l == link })
+	return nil
+}
+
+func (s *MemoryStore) GetNoteTags(ctx context.Context, userId, noteId int) ([]NoteTagDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[noteId]; !ok || note.userId != userId {
+		return nil, ErrNoteNotFound
+	}
+	return s.noteTagsOf(noteId), nil
+}
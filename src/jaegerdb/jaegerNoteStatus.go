@@ -0,0 +1,151 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrInvalidStatus           = errors.New("unknown application status")
+	ErrInvalidStatusTransition = errors.New("application status can't change that way")
+)
+
+// application statuses a note can have
+const (
+	StatusApplied   = "applied"
+	StatusScreening = "screening"
+	StatusInterview = "interview"
+	StatusOffer     = "offer"
+	StatusAccepted  = "accepted"
+	StatusDeclined  = "declined"
+	StatusRejected  = "rejected"
+	StatusWithdrawn = "withdrawn"
+)
+
+// statusTransitions lists where every status can go next, accepted, declined, rejected and withdrawn are final
+var statusTransitions = map[string][]string{
+	StatusApplied:   {StatusScreening, StatusInterview, StatusOffer, StatusRejected, StatusWithdrawn},
+	StatusScreening: {StatusInterview, StatusOffer, StatusRejected, StatusWithdrawn},
+	StatusInterview: {StatusOffer, StatusRejected, StatusWithdrawn},
+	StatusOffer:     {StatusAccepted, StatusDeclined, StatusRejected},
+	StatusAccepted:  {},
+	StatusDeclined:  {},
+	StatusRejected:  {},
+	StatusWithdrawn: {},
+}
+
+// NoteStatuses returns the statuses in the order an application goes through them
+func NoteStatuses() []string {
+	return []string{StatusApplied, StatusScreening, StatusInterview, StatusOffer, StatusAccepted, StatusDeclined, StatusRejected, StatusWithdrawn}
+}
+
+// NextStatuses returns the statuses a note can move to from status, notes from before the statuses were defined can move to any of them
+func NextStatuses(status string) []string {
+	next, ok := statusTransitions[status]
+	if !ok {
+		return NoteStatuses()
+	}
+	return next
+}
+
+func normalizeStatus(status string) string {
+	return strings.ToLower(strings.TrimSpace(status))
+}
+
+// newNoteStatus checks the status a note is created with, notes without one start as applied
+func newNoteStatus(status string) (string, error) {
+	status = normalizeStatus(status)
+	if status == "" {
+		return StatusApplied, nil
+	}
+	if _, ok := statusTransitions[status]; !ok {
+		return "", ErrInvalidStatus
+	}
+	return status, nil
+}
+
+// nextNoteStatus checks a status update and returns the status to store, an empty status leaves it as it is
+// so does sending back the current one, legacy statuses from before 0013 are only normalized for the comparison and kept as they are
+func nextNoteStatus(current, requested string) (string, error) {
+	requested = normalizeStatus(requested)
+	if requested == "" || requested == current || requested == normalizeStatus(current) {
+		return current, nil
+	}
+	if _, ok := statusTransitions[requested]; !ok {
+		return "", ErrInvalidStatus
+	}
+	for _, status := range NextStatuses(current) {
+		if status == requested {
+			return requested, nil
+		}
+	}
+	return "", ErrInvalidStatusTransition
+}
+
+// recordStatusChange adds a row to the note's timeline, from is empty for the status the note was created with
+func recordStatusChange(ctx context.Context, tx pgx.Tx, noteId int, from, to string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO note_status_history (fk_note_id, from_status, to_status, changed_at)
+	VALUES ($1, $2, $3, CURRENT_TIMESTAMP)`, noteId, stringToNil(&from), to)
+	if err != nil {
+		log.Printf("Unable to record the status change of note %d: %s", noteId, err)
+	}
+	return err
+}
+
+// GetNoteStatusHistory returns the note's status changes, oldest first
+func GetNoteStatusHistory(ctx context.Context, pool *pgxpool.Pool, userId, noteId int) ([]NoteStatusChangeDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $2)`, noteId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := pool.Query(ctx, `SELECT id, fk_note_id, from_status, to_status, changed_at FROM note_status_history
+	WHERE fk_note_id = $1 ORDER BY changed_at, id`, noteId)
+	if err != nil {
+		return nil, err
+	}
+	return scanStatusChanges(rows)
+}
+
+// GetStatusHistory returns the status changes of all of the user's notes
+func GetStatusHistory(ctx context.Context, pool *pgxpool.Pool, userId int) ([]NoteStatusChangeDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT h.id, h.fk_note_id, h.from_status, h.to_status, h.changed_at
+	FROM note_status_history h JOIN notes ON notes.id = h.fk_note_id
+	WHERE notes.fk_user_id = $1 ORDER BY h.fk_note_id, h.changed_at, h.id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	return scanStatusChanges(rows)
+}
+
+func scanStatusChanges(rows pgx.Rows) ([]NoteStatusChangeDB, error) {
+	defer rows.Close()
+
+	var changes []NoteStatusChangeDB
+	for rows.Next() {
+		var change NoteStatusChangeDB
+		if err := rows.Scan(&change.ID, &change.NoteID, &change.FromStatus, &change.ToStatus, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
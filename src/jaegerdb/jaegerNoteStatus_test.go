@@ -0,0 +1,42 @@
+package jaegerdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNextNoteStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   string
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{"empty keeps the status", StatusApplied, "", StatusApplied, nil},
+		{"same status", StatusInterview, StatusInterview, StatusInterview, nil},
+		{"same status in another case", StatusInterview, " Interview ", StatusInterview, nil},
+		{"allowed transition", StatusApplied, StatusScreening, StatusScreening, nil},
+		{"skipping ahead", StatusApplied, StatusOffer, StatusOffer, nil},
+		{"normalizes the requested status", StatusOffer, "ACCEPTED", StatusAccepted, nil},
+		{"going back", StatusInterview, StatusApplied, "", ErrInvalidStatusTransition},
+		{"leaving a final status", StatusRejected, StatusApplied, "", ErrInvalidStatusTransition},
+		{"unknown status", StatusApplied, "ghosted", "", ErrInvalidStatus},
+		{"legacy status sent back", "Pending Review", "Pending Review", "Pending Review", nil},
+		{"legacy status sent back normalized", "Pending Review", "pending review", "Pending Review", nil},
+		{"legacy status moves anywhere", "Pending Review", StatusWithdrawn, StatusWithdrawn, nil},
+		{"legacy status to an unknown one", "Pending Review", "ghosted", "", ErrInvalidStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextNoteStatus(tt.current, tt.requested)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("nextNoteStatus(%q, %q) error = %v, want %v", tt.current, tt.requested, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("nextNoteStatus(%q, %q) = %q, want %q", tt.current, tt.requested, got, tt.want)
+			}
+		})
+	}
+}
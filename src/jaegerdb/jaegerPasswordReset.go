@@ -0,0 +1,109 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerpassword"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+// CreatePasswordResetToken stores a new reset token and invalidates the ones requested before it
+func CreatePasswordResetToken(ctx context.Context, pool *pgxpool.Pool, userId int, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(ctx, `UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+	WHERE fk_user_id = $1 AND used_at IS NULL`, userId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO password_reset_tokens (token_hash, fk_user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, CURRENT_TIMESTAMP)`, tokenHash, userId, expiresAt); err != nil {
+		log.Printf("Unable to store the password reset token: %s", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetPasswordResetUser returns the id of the user a valid reset token was sent to without using the token
+func GetPasswordResetUser(ctx context.Context, pool *pgxpool.Pool, tokenHash string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var userId int
+	err := pool.QueryRow(ctx, `SELECT fk_user_id FROM password_reset_tokens
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP`, tokenHash).Scan(&userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// ResetPasswordWithToken consumes the token, sets the new (already hashed) password and revokes the user's API tokens
+// returns the id of the user whose password was reset
+func ResetPasswordWithToken(ctx context.Context, pool *pgxpool.Pool, tokenHash, password string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	passwordHash, err := jaegerpassword.Hash(password)
+	if err != nil {
+		log.Printf("Unable to hash the password: %s", err)
+		return 0, err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var (
+		userId    int
+		expiresAt time.Time
+		usedAt    *time.Time
+	)
+	err = tx.QueryRow(ctx, `SELECT fk_user_id, expires_at, used_at FROM password_reset_tokens
+	WHERE token_hash = $1 FOR UPDATE`, tokenHash).Scan(&userId, &expiresAt, &usedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	if usedAt != nil || time.Now().After(expiresAt) {
+		return 0, ErrResetTokenInvalid
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1`, tokenHash); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET password = $1, password_reset_required = FALSE, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, passwordHash, userId); err != nil {
+		return 0, err
+	}
+	// whoever took over the account could have created tokens, they don't survive the reset
+	if _, err := tx.Exec(ctx, `UPDATE api_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE fk_user_id = $1 AND revoked_at IS NULL`, userId); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
@@ -0,0 +1,451 @@
+package jaegerdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore implements UserStore and NoteStore on top of the package functions
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var (
+	_ UserStore = (*PostgresStore)(nil)
+	_ NoteStore = (*PostgresStore)(nil)
+	_ Migrator  = (*PostgresStore)(nil)
+)
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) Close() {
+	s.pool.Close()
+}
+
+// arbitrary key for the advisory lock so two instances starting together don't migrate at the same time
+const migrationLockKey = 72610018
+
+type pgMigrationTarget struct {
+	conn *pgx.Conn
+}
+
+func (t pgMigrationTarget) dialect() string {
+	return dialectPostgres
+}
+
+func (t pgMigrationTarget) exec(ctx context.Context, sql string) error {
+	_, err := t.conn.Exec(ctx, sql)
+	return err
+}
+
+func (t pgMigrationTarget) appliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := t.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (t pgMigrationTarget) apply(ctx context.Context, sql, record string, args ...any) error {
+	tx, err := t.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// withMigrationLock runs fn on a single connection holding the migration lock, migrations aren't bound by DB_QUERY_TIMEOUT
+func (s *PostgresStore) withMigrationLock(ctx context.Context, fn func(target migrationTarget) error) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	return fn(pgMigrationTarget{conn: conn.Conn()})
+}
+
+func (s *PostgresStore) MigrateUp(ctx context.Context) (done []Migration, err error) {
+	err = s.withMigrationLock(ctx, func(target migrationTarget) error {
+		done, err = migrateUp(ctx, target)
+		return err
+	})
+	return done, err
+}
+
+func (s *PostgresStore) MigrateDown(ctx context.Context, steps int) (done []Migration, err error) {
+	err = s.withMigrationLock(ctx, func(target migrationTarget) error {
+		done, err = migrateDown(ctx, target, steps)
+		return err
+	})
+	return done, err
+}
+
+func (s *PostgresStore) MigrationStatus(ctx context.Context) (status []MigrationStatusDB, err error) {
+	err = s.withMigrationLock(ctx, func(target migrationTarget) error {
+		status, err = migrationStatus(ctx, target)
+		return err
+	})
+	return status, err
+}
+
+func (s *PostgresStore) CreateAPIToken(ctx context.Context, userId int, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (APITokenDB, error) {
+	return CreateAPIToken(ctx, s.pool, userId, name, prefix, tokenHash, scopes, expiresAt)
+}
+
+func (s *PostgresStore) GetAPITokens(ctx context.Context, userId int) ([]APITokenDB, error) {
+	return GetAPITokens(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) RevokeAPIToken(ctx context.Context, userId, tokenId int) error {
+	return RevokeAPIToken(ctx, s.pool, userId, tokenId)
+}
+
+func (s *PostgresStore) AuthenticateAPIToken(ctx context.Context, tokenHash string) (string, int, []string, error) {
+	return AuthenticateAPIToken(ctx, s.pool, tokenHash)
+}
+
+func (s *PostgresStore) ScheduleUserDeletion(ctx context.Context, userId int) (time.Time, error) {
+	return ScheduleUserDeletion(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) CancelUserDeletion(ctx context.Context, userId int) error {
+	return CancelUserDeletion(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return PurgeDeletedUsers(ctx, s.pool, deletedBefore)
+}
+
+func (s *PostgresStore) GetProfile(ctx context.Context, userId int) (ProfileDB, error) {
+	return GetProfile(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) GetUsersPage(ctx context.Context, limit, offset int) ([]AdminUserDB, int, error) {
+	return GetUsersPage(ctx, s.pool, limit, offset)
+}
+
+func (s *PostgresStore) SetUserDisabled(ctx context.Context, userId int, disabled bool) error {
+	return SetUserDisabled(ctx, s.pool, userId, disabled)
+}
+
+func (s *PostgresStore) RequirePasswordReset(ctx context.Context, userId int) (string, error) {
+	return RequirePasswordReset(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) RecordAuditEvent(ctx context.Context, userId, actorId int, action, target, details, ip, userAgent string) error {
+	return RecordAuditEvent(ctx, s.pool, userId, actorId, action, target, details, ip, userAgent)
+}
+
+func (s *PostgresStore) GetAuditEvents(ctx context.Context, userId, limit, offset int) ([]AuditEventDB, int, error) {
+	return GetAuditEvents(ctx, s.pool, userId, limit, offset)
+}
+
+func (s *PostgresStore) CreateUserJaeger(ctx context.Context, fullName, email, password string) error {
+	return CreateUserJaeger(ctx, s.pool, fullName, email, password)
+}
+
+func (s *PostgresStore) GetUserByEmail(ctx context.Context, email string) (*RetrievedUser, error) {
+	return GetUserByEmail(ctx, s.pool, email)
+}
+
+func (s *PostgresStore) CheckCredentialsOnLogin(ctx context.Context, email, password string) error {
+	return CheckCredentialsOnLogin(ctx, s.pool, email, password)
+}
+
+func (s *PostgresStore) UpdateUser(ctx context.Context, id int, fullName, email, password string) (UpdatedUserDataDB, error) {
+	return UpdateUser(ctx, s.pool, id, fullName, email, password)
+}
+
+func (s *PostgresStore) CreateNote(ctx context.Context, uuid, companyName, position, salary, applicationStatus, appliedOn, description string, userId int) (int, error) {
+	return CreateNote(ctx, s.pool, uuid, companyName, position, salary, applicationStatus, appliedOn, description, userId)
+}
+
+func (s *PostgresStore) GetAllUserNotes(ctx context.Context, id int, filter NoteFilter) ([]NoteDB, error) {
+	return GetAllUserNotes(ctx, s.pool, id, filter)
+}
+
+func (s *PostgresStore) UpdateNote(ctx context.Context, userId, id int, company, pos, sal, appStat, appOn, desc string) error {
+	return UpdateNote(ctx, s.pool, userId, id, company, pos, sal, appStat, appOn, desc)
+}
+
+func (s *PostgresStore) GetUpdatedNote(ctx context.Context, userId, id int) (NoteDB, error) {
+	return GetUpdatedNote(ctx, s.pool, userId, id)
+}
+
+func (s *PostgresStore) DeleteNote(ctx context.Context, userId, id int) error {
+	return DeleteNote(ctx, s.pool, userId, id)
+}
+
+func (s *PostgresStore) GetNoteStatusHistory(ctx context.Context, userId, noteId int) ([]NoteStatusChangeDB, error) {
+	return GetNoteStatusHistory(ctx, s.pool, userId, noteId)
+}
+
+func (s *PostgresStore) GetStatusHistory(ctx context.Context, userId int) ([]NoteStatusChangeDB, error) {
+	return GetStatusHistory(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) CreateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	return CreateInterview(ctx, s.pool, userId, noteId, interview)
+}
+
+func (s *PostgresStore) GetNoteInterviews(ctx context.Context, userId, noteId int) ([]InterviewDB, error) {
+	return GetNoteInterviews(ctx, s.pool, userId, noteId)
+}
+
+func (s *PostgresStore) GetInterview(ctx context.Context, userId, noteId, interviewId int) (InterviewDB, error) {
+	return GetInterview(ctx, s.pool, userId, noteId, interviewId)
+}
+
+func (s *PostgresStore) UpdateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	return UpdateInterview(ctx, s.pool, userId, noteId, interview)
+}
+
+func (s *PostgresStore) DeleteInterview(ctx context.Context, userId, noteId, interviewId int) error {
+	return DeleteInterview(ctx, s.pool, userId, noteId, interviewId)
+}
+
+func (s *PostgresStore) GetUpcomingInterviews(ctx context.Context, userId int, from, until time.Time) ([]UpcomingInterviewDB, error) {
+	return GetUpcomingInterviews(ctx, s.pool, userId, from, until)
+}
+
+func (s *PostgresStore) GetUserInterviews(ctx context.Context, userId int) ([]InterviewDB, error) {
+	return GetUserInterviews(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) CreateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error) {
+	return CreateContact(ctx, s.pool, userId, contact)
+}
+
+func (s *PostgresStore) GetContacts(ctx context.Context, userId int) ([]ContactDB, error) {
+	return GetContacts(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) GetContact(ctx context.Context, userId, contactId int) (ContactDB, error) {
+	return GetContact(ctx, s.pool, userId, contactId)
+}
+
+func (s *PostgresStore) UpdateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error) {
+	return UpdateContact(ctx, s.pool, userId, contact)
+}
+
+func (s *PostgresStore) DeleteContact(ctx context.Context, userId, contactId int) error {
+	return DeleteContact(ctx, s.pool, userId, contactId)
+}
+
+func (s *PostgresStore) LinkContact(ctx context.Context, userId, noteId, contactId int) error {
+	return LinkContact(ctx, s.pool, userId, noteId, contactId)
+}
+
+func (s *PostgresStore) UnlinkContact(ctx context.Context, userId, noteId, contactId int) error {
+	return UnlinkContact(ctx, s.pool, userId, noteId, contactId)
+}
+
+func (s *PostgresStore) GetNoteContacts(ctx context.Context, userId, noteId int) ([]ContactDB, error) {
+	return GetNoteContacts(ctx, s.pool, userId, noteId)
+}
+
+func (s *PostgresStore) GetContactNotes(ctx context.Context, userId, contactId int) ([]NoteDB, error) {
+	return GetContactNotes(ctx, s.pool, userId, contactId)
+}
+
+func (s *PostgresStore) CreateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error) {
+	return CreateCompany(ctx, s.pool, userId, company)
+}
+
+func (s *PostgresStore) GetCompanies(ctx context.Context, userId int) ([]CompanyDB, error) {
+	return GetCompanies(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) GetCompany(ctx context.Context, userId, companyId int) (CompanyDetailDB, error) {
+	return GetCompany(ctx, s.pool, userId, companyId)
+}
+
+func (s *PostgresStore) UpdateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error) {
+	return UpdateCompany(ctx, s.pool, userId, company)
+}
+
+func (s *PostgresStore) DeleteCompany(ctx context.Context, userId, companyId int) error {
+	return DeleteCompany(ctx, s.pool, userId, companyId)
+}
+
+func (s *PostgresStore) AddCompanyAlias(ctx context.Context, userId, companyId int, alias string) (CompanyDB, error) {
+	return AddCompanyAlias(ctx, s.pool, userId, companyId, alias)
+}
+
+func (s *PostgresStore) DeleteCompanyAlias(ctx context.Context, userId, companyId, aliasId int) error {
+	return DeleteCompanyAlias(ctx, s.pool, userId, companyId, aliasId)
+}
+
+func (s *PostgresStore) CreateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error) {
+	return CreateTag(ctx, s.pool, userId, tag)
+}
+
+func (s *PostgresStore) GetTags(ctx context.Context, userId int) ([]TagDB, error) {
+	return GetTags(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) UpdateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error) {
+	return UpdateTag(ctx, s.pool, userId, tag)
+}
+
+func (s *PostgresStore) DeleteTag(ctx context.Context, userId, tagId int) error {
+	return DeleteTag(ctx, s.pool, userId, tagId)
+}
+
+func (s *PostgresStore) TagNote(ctx context.Context, userId, noteId, tagId int) error {
+	return TagNote(ctx, s.pool, userId, noteId, tagId)
+}
+
+func (s *PostgresStore) UntagNote(ctx context.Context, userId, noteId, tagId int) error {
+	return UntagNote(ctx, s.pool, userId, noteId, tagId)
+}
+
+func (s *PostgresStore) GetNoteTags(ctx context.Context, userId, noteId int) ([]NoteTagDB, error) {
+	return GetNoteTags(ctx, s.pool, userId, noteId)
+}
+
+func (s *PostgresStore) CreateEmailVerificationToken(ctx context.Context, userId int, email, tokenHash string, expiresAt time.Time) error {
+	return CreateEmailVerificationToken(ctx, s.pool, userId, email, tokenHash, expiresAt)
+}
+
+func (s *PostgresStore) VerifyEmailWithToken(ctx context.Context, tokenHash string) (int, error) {
+	return VerifyEmailWithToken(ctx, s.pool, tokenHash)
+}
+
+func (s *PostgresStore) GetUserByIdentity(ctx context.Context, provider, subject string) (string, error) {
+	return GetUserByIdentity(ctx, s.pool, provider, subject)
+}
+
+func (s *PostgresStore) LinkIdentity(ctx context.Context, userId int, provider, subject, email string) error {
+	return LinkIdentity(ctx, s.pool, userId, provider, subject, email)
+}
+
+func (s *PostgresStore) CreateExternalUser(ctx context.Context, fullName, email string, verified bool, provider, subject string) error {
+	return CreateExternalUser(ctx, s.pool, fullName, email, verified, provider, subject)
+}
+
+func (s *PostgresStore) GetUserIdentities(ctx context.Context, userId int) ([]IdentityDB, error) {
+	return GetUserIdentities(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) UnlinkIdentity(ctx context.Context, userId, identityId int) error {
+	return UnlinkIdentity(ctx, s.pool, userId, identityId)
+}
+
+func (s *PostgresStore) RecordLoginAttempt(ctx context.Context, email, ip, userAgent string, succeeded bool) error {
+	return RecordLoginAttempt(ctx, s.pool, email, ip, userAgent, succeeded)
+}
+
+func (s *PostgresStore) GetLoginFailures(ctx context.Context, email, ip string, since time.Time) (LoginFailuresDB, error) {
+	return GetLoginFailures(ctx, s.pool, email, ip, since)
+}
+
+func (s *PostgresStore) GetRecentLoginFailures(ctx context.Context, userId, limit int) ([]LoginAttemptDB, error) {
+	return GetRecentLoginFailures(ctx, s.pool, userId, limit)
+}
+
+func (s *PostgresStore) GetLoginHistory(ctx context.Context, userId int) ([]LoginAttemptDB, error) {
+	return GetLoginHistory(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) CreatePasswordResetToken(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error {
+	return CreatePasswordResetToken(ctx, s.pool, userId, tokenHash, expiresAt)
+}
+
+func (s *PostgresStore) GetPasswordResetUser(ctx context.Context, tokenHash string) (int, error) {
+	return GetPasswordResetUser(ctx, s.pool, tokenHash)
+}
+
+func (s *PostgresStore) ResetPasswordWithToken(ctx context.Context, tokenHash, password string) (int, error) {
+	return ResetPasswordWithToken(ctx, s.pool, tokenHash, password)
+}
+
+func (s *PostgresStore) CreateRefreshToken(ctx context.Context, userId int, familyId, tokenHash string, expiresAt time.Time) error {
+	return CreateRefreshToken(ctx, s.pool, userId, familyId, tokenHash, expiresAt)
+}
+
+func (s *PostgresStore) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (string, string, error) {
+	return RotateRefreshToken(ctx, s.pool, oldHash, newHash, expiresAt)
+}
+
+func (s *PostgresStore) RevokeRefreshTokenFamily(ctx context.Context, tokenHash string) (int, string, error) {
+	return RevokeRefreshTokenFamily(ctx, s.pool, tokenHash)
+}
+
+func (s *PostgresStore) CreateSession(ctx context.Context, jti string, userId int, userAgent, ip string, expiresAt time.Time) error {
+	return CreateSession(ctx, s.pool, jti, userId, userAgent, ip, expiresAt)
+}
+
+func (s *PostgresStore) IsSessionActive(ctx context.Context, jti string) (bool, error) {
+	return IsSessionActive(ctx, s.pool, jti)
+}
+
+func (s *PostgresStore) GetActiveSessions(ctx context.Context, userId int) ([]SessionDB, error) {
+	return GetActiveSessions(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) RevokeSession(ctx context.Context, userId int, jti string) error {
+	return RevokeSession(ctx, s.pool, userId, jti)
+}
+
+func (s *PostgresStore) RevokeAllSessions(ctx context.Context, userId int) error {
+	return RevokeAllSessions(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) GetTOTPState(ctx context.Context, userId int) (TOTPStateDB, error) {
+	return GetTOTPState(ctx, s.pool, userId)
+}
+
+func (s *PostgresStore) SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error {
+	return SetPendingTOTPSecret(ctx, s.pool, userId, secret)
+}
+
+func (s *PostgresStore) EnableTOTP(ctx context.Context, userId int, counter int64, recoveryCodeHashes []string) error {
+	return EnableTOTP(ctx, s.pool, userId, counter, recoveryCodeHashes)
+}
+
+func (s *PostgresStore) RegenerateRecoveryCodes(ctx context.Context, userId int, recoveryCodeHashes []string) error {
+	return RegenerateRecoveryCodes(ctx, s.pool, userId, recoveryCodeHashes)
+}
+
+func (s *PostgresStore) UseTOTPCounter(ctx context.Context, userId int, counter int64) error {
+	return UseTOTPCounter(ctx, s.pool, userId, counter)
+}
+
+func (s *PostgresStore) UseRecoveryCode(ctx context.Context, userId int, codeHash string) error {
+	return UseRecoveryCode(ctx, s.pool, userId, codeHash)
+}
+
+func (s *PostgresStore) DisableTOTP(ctx context.Context, userId int) error {
+	return DisableTOTP(ctx, s.pool, userId)
+}
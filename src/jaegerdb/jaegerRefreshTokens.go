@@ -0,0 +1,140 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+func CreateRefreshToken(ctx context.Context, pool *pgxpool.Pool, userId int, familyId, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := pool.Exec(ctx, `INSERT INTO refresh_tokens (token_hash, family_id, fk_user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)`, tokenHash, familyId, userId, expiresAt)
+	if err != nil {
+		log.Printf("Unable to store the refresh token: %s", err)
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken marks the old token as used and stores its replacement in the same family
+// if the old token was already used the whole family (and its session) gets revoked since someone is replaying it
+// returns the email of the owner and the family id, which is also the session id
+func RotateRefreshToken(ctx context.Context, pool *pgxpool.Pool, oldHash, newHash string, expiresAt time.Time) (string, string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return "", "", err
+	}
+	defer tx.Rollback(context.Background()) // no-op after commit
+
+	var (
+		userId    int
+		familyId  string
+		expires   time.Time
+		usedAt    *time.Time
+		revokedAt *time.Time
+	)
+	err = tx.QueryRow(ctx, `SELECT fk_user_id, family_id, expires_at, used_at, revoked_at
+	FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, oldHash).Scan(&userId, &familyId, &expires, &usedAt, &revokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if revokedAt != nil {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	// reuse detection
+	if usedAt != nil {
+		log.Printf("Refresh token reuse detected for family %s, revoking the family", familyId)
+		if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL`, familyId); err != nil {
+			return "", "", err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE jti = $1 AND revoked_at IS NULL`, familyId); err != nil {
+			return "", "", err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expires) {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1`, oldHash); err != nil {
+		return "", "", err
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO refresh_tokens (token_hash, family_id, fk_user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)`, newHash, familyId, userId, expiresAt); err != nil {
+		return "", "", err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, expires_at = $2 WHERE jti = $1`, familyId, expiresAt); err != nil {
+		return "", "", err
+	}
+
+	var email string
+	if err := tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, userId).Scan(&email); err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", "", err
+	}
+	return email, familyId, nil
+}
+
+// RevokeRefreshTokenFamily revokes every token in the family of the given token and the session it belongs to, used on logout
+// returns the user and the session id, userId is 0 if the token is unknown
+func RevokeRefreshTokenFamily(ctx context.Context, pool *pgxpool.Pool, tokenHash string) (int, string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var (
+		userId   int
+		familyId string
+	)
+	err := pool.QueryRow(ctx, `SELECT fk_user_id, family_id FROM refresh_tokens WHERE token_hash = $1`, tokenHash).Scan(&userId, &familyId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, "", nil // nothing to revoke
+	}
+	if err != nil {
+		log.Printf("Unable to find the refresh token family: %s", err)
+		return 0, "", err
+	}
+
+	if _, err := pool.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+	WHERE family_id = $1 AND revoked_at IS NULL`, familyId); err != nil {
+		log.Printf("Unable to revoke the refresh token family: %s", err)
+		return 0, "", err
+	}
+	if _, err := pool.Exec(ctx, `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+	WHERE jti = $1 AND revoked_at IS NULL`, familyId); err != nil {
+		log.Printf("Unable to revoke the session: %s", err)
+		return 0, "", err
+	}
+	return userId, familyId, nil
+}
@@ -0,0 +1,1069 @@
+package jaegerdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerpassword"
+)
+
+// the SQLite versions of the account queries, they follow the Postgres functions of the same name
+
+// sessions and tokens
+
+func (s *SQLiteStore) CreateSession(ctx context.Context, jti string, userId int, userAgent, ip string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := sqliteTime(time.Now())
+	_, err := s.db.ExecContext(ctx, `INSERT INTO sessions (jti, fk_user_id, user_agent, ip_address, created_at, last_seen_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $5, $6)`, jti, userId, userAgent, ip, now, sqliteTime(expiresAt))
+	if err != nil {
+		log.Printf("Unable to store the session: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) IsSessionActive(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var active bool
+	err := s.db.QueryRowContext(ctx, `SELECT revoked_at IS NULL AND expires_at > $2 FROM sessions WHERE jti = $1`, jti, sqliteTime(time.Now())).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+func (s *SQLiteStore) GetActiveSessions(ctx context.Context, userId int) ([]SessionDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT jti, user_agent, ip_address, created_at, last_seen_at, expires_at
+	FROM sessions WHERE fk_user_id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY last_seen_at DESC`, userId, sqliteTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []SessionDB
+	for rows.Next() {
+		var session SessionDB
+		var createdAt, lastSeenAt, expiresAt sqliteTimestamp
+		if err := rows.Scan(&session.ID, &session.UserAgent, &session.IPAddress, &createdAt, &lastSeenAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt = createdAt.Time, lastSeenAt.Time, expiresAt.Time
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s *SQLiteStore) RevokeSession(ctx context.Context, userId int, jti string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	now := sqliteTime(time.Now())
+	result, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = $3
+	WHERE jti = $1 AND fk_user_id = $2 AND revoked_at IS NULL`, jti, userId, now)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrSessionNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = $2
+	WHERE family_id = $1 AND revoked_at IS NULL`, jti, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) RevokeAllSessions(ctx context.Context, userId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := sqliteRevokeAllSessions(ctx, tx, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func sqliteRevokeAllSessions(ctx context.Context, tx *sql.Tx, userId int) error {
+	now := sqliteTime(time.Now())
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = $2
+	WHERE fk_user_id = $1 AND revoked_at IS NULL`, userId, now); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = $2
+	WHERE fk_user_id = $1 AND revoked_at IS NULL`, userId, now)
+	return err
+}
+
+func (s *SQLiteStore) CreateRefreshToken(ctx context.Context, userId int, familyId, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO refresh_tokens (token_hash, family_id, fk_user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5)`, tokenHash, familyId, userId, sqliteTime(expiresAt), sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to store the refresh token: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (string, string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return "", "", err
+	}
+	defer tx.Rollback() // no-op after commit
+
+	var (
+		userId    int
+		familyId  string
+		expires   sqliteTimestamp
+		usedAt    sqliteTimestamp
+		revokedAt sqliteTimestamp
+	)
+	err = tx.QueryRowContext(ctx, `SELECT fk_user_id, family_id, expires_at, used_at, revoked_at
+	FROM refresh_tokens WHERE token_hash = $1`, oldHash).Scan(&userId, &familyId, &expires, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if revokedAt.Valid {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	now := time.Now()
+	// reuse detection
+	if usedAt.Valid {
+		log.Printf("Refresh token reuse detected for family %s, revoking the family", familyId)
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = $2
+		WHERE family_id = $1 AND revoked_at IS NULL`, familyId, sqliteTime(now)); err != nil {
+			return "", "", err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = $2
+		WHERE jti = $1 AND revoked_at IS NULL`, familyId, sqliteTime(now)); err != nil {
+			return "", "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+
+	if now.After(expires.Time) {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = $2 WHERE token_hash = $1`, oldHash, sqliteTime(now)); err != nil {
+		return "", "", err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO refresh_tokens (token_hash, family_id, fk_user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5)`, newHash, familyId, userId, sqliteTime(expiresAt), sqliteTime(now)); err != nil {
+		return "", "", err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET last_seen_at = $3, expires_at = $2 WHERE jti = $1`, familyId, sqliteTime(expiresAt), sqliteTime(now)); err != nil {
+		return "", "", err
+	}
+
+	var email string
+	if err := tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userId).Scan(&email); err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+	return email, familyId, nil
+}
+
+func (s *SQLiteStore) RevokeRefreshTokenFamily(ctx context.Context, tokenHash string) (int, string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var (
+		userId   int
+		familyId string
+	)
+	err := s.db.QueryRowContext(ctx, `SELECT fk_user_id, family_id FROM refresh_tokens WHERE token_hash = $1`, tokenHash).Scan(&userId, &familyId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", nil // nothing to revoke
+	}
+	if err != nil {
+		log.Printf("Unable to find the refresh token family: %s", err)
+		return 0, "", err
+	}
+
+	now := sqliteTime(time.Now())
+	if _, err := s.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = $2
+	WHERE family_id = $1 AND revoked_at IS NULL`, familyId, now); err != nil {
+		log.Printf("Unable to revoke the refresh token family: %s", err)
+		return 0, "", err
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET revoked_at = $2
+	WHERE jti = $1 AND revoked_at IS NULL`, familyId, now); err != nil {
+		log.Printf("Unable to revoke the session: %s", err)
+		return 0, "", err
+	}
+	return userId, familyId, nil
+}
+
+// password reset and email verification
+
+func (s *SQLiteStore) CreatePasswordResetToken(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	now := sqliteTime(time.Now())
+	if _, err := tx.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = $2
+	WHERE fk_user_id = $1 AND used_at IS NULL`, userId, now); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO password_reset_tokens (token_hash, fk_user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, $4)`, tokenHash, userId, sqliteTime(expiresAt), now); err != nil {
+		log.Printf("Unable to store the password reset token: %s", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetPasswordResetUser(ctx context.Context, tokenHash string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var (
+		userId    int
+		expiresAt sqliteTimestamp
+		usedAt    sqliteTimestamp
+	)
+	err := s.db.QueryRowContext(ctx, `SELECT fk_user_id, expires_at, used_at FROM password_reset_tokens
+	WHERE token_hash = $1`, tokenHash).Scan(&userId, &expiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	if usedAt.Valid || time.Now().After(expiresAt.Time) {
+		return 0, ErrResetTokenInvalid
+	}
+	return userId, nil
+}
+
+func (s *SQLiteStore) ResetPasswordWithToken(ctx context.Context, tokenHash, password string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	passwordHash, err := jaegerpassword.Hash(password)
+	if err != nil {
+		log.Printf("Unable to hash the password: %s", err)
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		userId    int
+		expiresAt sqliteTimestamp
+		usedAt    sqliteTimestamp
+	)
+	err = tx.QueryRowContext(ctx, `SELECT fk_user_id, expires_at, used_at FROM password_reset_tokens
+	WHERE token_hash = $1`, tokenHash).Scan(&userId, &expiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if usedAt.Valid || now.After(expiresAt.Time) {
+		return 0, ErrResetTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = $2 WHERE token_hash = $1`, tokenHash, sqliteTime(now)); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET password = $1, password_reset_required = FALSE, updated_at = $3 WHERE id = $2`, passwordHash, userId, sqliteTime(now)); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = $2 WHERE fk_user_id = $1 AND revoked_at IS NULL`, userId, sqliteTime(now)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+func (s *SQLiteStore) CreateEmailVerificationToken(ctx context.Context, userId int, email, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	now := sqliteTime(time.Now())
+	if _, err := tx.ExecContext(ctx, `UPDATE email_verification_tokens SET used_at = $2
+	WHERE fk_user_id = $1 AND used_at IS NULL`, userId, now); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO email_verification_tokens (token_hash, fk_user_id, email, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5)`, tokenHash, userId, email, sqliteTime(expiresAt), now); err != nil {
+		log.Printf("Unable to store the email verification token: %s", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) VerifyEmailWithToken(ctx context.Context, tokenHash string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		userId    int
+		email     string
+		expiresAt sqliteTimestamp
+		usedAt    sqliteTimestamp
+	)
+	err = tx.QueryRowContext(ctx, `SELECT fk_user_id, email, expires_at, used_at FROM email_verification_tokens
+	WHERE token_hash = $1`, tokenHash).Scan(&userId, &email, &expiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if usedAt.Valid || now.After(expiresAt.Time) {
+		return 0, ErrVerificationTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE email_verification_tokens SET used_at = $2 WHERE token_hash = $1`, tokenHash, sqliteTime(now)); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE users SET verified_at = $3 WHERE id = $1 AND email = $2`, userId, email, sqliteTime(now))
+	if err != nil {
+		return 0, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 { // email changed since the token was sent
+		return 0, ErrVerificationTokenInvalid
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// two factor authentication
+
+func (s *SQLiteStore) GetTOTPState(ctx context.Context, userId int) (TOTPStateDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var state TOTPStateDB
+	var secret sql.NullString
+	var lastCounter sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_counter,
+	(SELECT COUNT(*) FROM totp_recovery_codes WHERE fk_user_id = users.id AND used_at IS NULL)
+	FROM users WHERE id = $1`, userId).Scan(&secret, &state.Enabled, &lastCounter, &state.RecoveryCodesLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TOTPStateDB{}, ErrUserNotFound
+	}
+	if err != nil {
+		return TOTPStateDB{}, err
+	}
+	state.Secret = secret.String
+	state.LastCounter = lastCounter.Int64
+	return state, nil
+}
+
+func (s *SQLiteStore) SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET totp_secret = $1, totp_last_counter = NULL
+	WHERE id = $2 AND totp_enabled_at IS NULL`, secret, userId)
+	if err != nil {
+		log.Printf("Unable to store the TOTP secret: %s", err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+func (s *SQLiteStore) EnableTOTP(ctx context.Context, userId int, counter int64, recoveryCodeHashes []string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET totp_enabled_at = $3, totp_last_counter = $1
+	WHERE id = $2 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`, counter, userId, sqliteTime(time.Now()))
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	if err := sqliteReplaceRecoveryCodes(ctx, tx, userId, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) RegenerateRecoveryCodes(ctx context.Context, userId int, recoveryCodeHashes []string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := sqliteReplaceRecoveryCodes(ctx, tx, userId, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func sqliteReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId int, recoveryCodeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE fk_user_id = $1`, userId); err != nil {
+		return err
+	}
+	now := sqliteTime(time.Now())
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO totp_recovery_codes (fk_user_id, code_hash, created_at)
+		VALUES ($1, $2, $3)`, userId, hash, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UseTOTPCounter(ctx context.Context, userId int, counter int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET totp_last_counter = $1
+	WHERE id = $2 AND (totp_last_counter IS NULL OR totp_last_counter < $1)`, counter, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrTOTPCodeReused
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UseRecoveryCode(ctx context.Context, userId int, codeHash string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE totp_recovery_codes SET used_at = $3
+	WHERE fk_user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userId, codeHash, sqliteTime(time.Now()))
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DisableTOTP(ctx context.Context, userId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL
+	WHERE id = $1`, userId); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE fk_user_id = $1`, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// external identities
+
+func (s *SQLiteStore) GetUserByIdentity(ctx context.Context, provider, subject string) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// RETURNING can't reach into other tables in SQLite, so the email is read in a second step
+	var email string
+	err := s.db.QueryRowContext(ctx, `UPDATE user_identities SET last_login_at = $3
+	WHERE provider = $1 AND subject = $2 RETURNING fk_user_id`, provider, subject, sqliteTime(time.Now())).Scan(new(int))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrIdentityNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	err = s.db.QueryRowContext(ctx, `SELECT users.email FROM users JOIN user_identities ON users.id = user_identities.fk_user_id
+	WHERE provider = $1 AND subject = $2`, provider, subject).Scan(&email)
+	if err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
+func (s *SQLiteStore) LinkIdentity(ctx context.Context, userId int, provider, subject, email string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := sqliteTime(time.Now())
+	_, err := s.db.ExecContext(ctx, `INSERT INTO user_identities (provider, subject, fk_user_id, email, created_at, last_login_at)
+	VALUES ($1, $2, $3, $4, $5, $5)`, provider, subject, userId, email, now)
+	if err != nil {
+		log.Printf("Unable to link identity %s/%s to user %d: %s", provider, subject, userId, err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateExternalUser(ctx context.Context, fullName, email string, verified bool, provider, subject string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	now := sqliteTime(time.Now())
+	var verifiedAt any
+	if verified {
+		verifiedAt = now
+	}
+	var userId int
+	err = tx.QueryRowContext(ctx, `INSERT INTO users (full_name, email, password, created_at, updated_at, verified_at)
+	VALUES ($1, $2, '', $3, $3, $4)
+	ON CONFLICT (email) DO NOTHING
+	RETURNING id`, fullName, email, now, verifiedAt).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO user_identities (provider, subject, fk_user_id, email, created_at, last_login_at)
+	VALUES ($1, $2, $3, $4, $5, $5)`, provider, subject, userId, email, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetUserIdentities(ctx context.Context, userId int) ([]IdentityDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, provider, email, created_at, last_login_at
+	FROM user_identities WHERE fk_user_id = $1 ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []IdentityDB
+	for rows.Next() {
+		var identity IdentityDB
+		var createdAt, lastLoginAt sqliteTimestamp
+		if err := rows.Scan(&identity.ID, &identity.Provider, &identity.Email, &createdAt, &lastLoginAt); err != nil {
+			return nil, err
+		}
+		identity.CreatedAt, identity.LastLoginAt = createdAt.Time, lastLoginAt.Time
+		identities = append(identities, identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (s *SQLiteStore) UnlinkIdentity(ctx context.Context, userId, identityId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM user_identities WHERE id = $1 AND fk_user_id = $2`, identityId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+// personal access tokens
+
+func (s *SQLiteStore) CreateAPIToken(ctx context.Context, userId int, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (APITokenDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	token := APITokenDB{Name: name, Prefix: prefix, Scopes: scopes, ExpiresAt: expiresAt, CreatedAt: time.Now().UTC()}
+	err := s.db.QueryRowContext(ctx, `INSERT INTO api_tokens (fk_user_id, name, token_prefix, token_hash, scopes, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		userId, name, prefix, tokenHash, strings.Join(scopes, " "), sqliteTime(token.CreatedAt), sqliteNullTime(expiresAt)).Scan(&token.ID)
+	if err != nil {
+		log.Printf("Unable to store the API token: %s", err)
+		return APITokenDB{}, err
+	}
+	return token, nil
+}
+
+func (s *SQLiteStore) GetAPITokens(ctx context.Context, userId int) ([]APITokenDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, token_prefix, scopes, created_at, last_used_at, expires_at
+	FROM api_tokens WHERE fk_user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APITokenDB
+	for rows.Next() {
+		var token APITokenDB
+		var scopes string
+		var createdAt, lastUsedAt, expiresAt sqliteTimestamp
+		if err := rows.Scan(&token.ID, &token.Name, &token.Prefix, &scopes, &createdAt, &lastUsedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		token.CreatedAt, token.LastUsedAt, token.ExpiresAt = createdAt.Time, lastUsedAt.ptr(), expiresAt.ptr()
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *SQLiteStore) RevokeAPIToken(ctx context.Context, userId, tokenId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = $3
+	WHERE id = $1 AND fk_user_id = $2 AND revoked_at IS NULL`, tokenId, userId, sqliteTime(time.Now()))
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) AuthenticateAPIToken(ctx context.Context, tokenHash string) (string, int, []string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var (
+		userId  int
+		tokenId int
+		scopes  string
+		email   string
+	)
+	now := sqliteTime(time.Now())
+	err := s.db.QueryRowContext(ctx, `UPDATE api_tokens SET last_used_at = $2
+	WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $2)
+	RETURNING fk_user_id, id, scopes`, tokenHash, now).Scan(&userId, &tokenId, &scopes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil, ErrAPITokenInvalid
+	}
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if err := s.db.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userId).Scan(&email); err != nil {
+		return "", 0, nil, err
+	}
+	return email, tokenId, strings.Fields(scopes), nil
+}
+
+// login throttling
+
+func (s *SQLiteStore) RecordLoginAttempt(ctx context.Context, email, ip, userAgent string, succeeded bool) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO login_attempts (email, fk_user_id, ip_address, user_agent, succeeded, attempted_at)
+	VALUES ($1, (SELECT id FROM users WHERE LOWER(email) = $1 LIMIT 1), $2, $3, $4, $5)`, email, ip, userAgent, succeeded, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to store the login attempt: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetLoginFailures(ctx context.Context, email, ip string, since time.Time) (LoginFailuresDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var failures LoginFailuresDB
+	var accountLast, ipLast sqliteTimestamp
+
+	// MAX with two arguments is SQLite's GREATEST
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(attempted_at) FROM login_attempts
+	WHERE email = $1 AND NOT succeeded AND attempted_at > MAX($2,
+		COALESCE((SELECT MAX(attempted_at) FROM login_attempts WHERE email = $1 AND succeeded), $2))`, email, sqliteTime(since)).
+		Scan(&failures.AccountFailures, &accountLast)
+	if err != nil {
+		return LoginFailuresDB{}, err
+	}
+
+	// a successful login doesn't reset the ip, otherwise logging into an own account would clear it
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(attempted_at) FROM login_attempts
+	WHERE ip_address = $1 AND NOT succeeded AND attempted_at > $2`, ip, sqliteTime(since)).
+		Scan(&failures.IPFailures, &ipLast)
+	if err != nil {
+		return LoginFailuresDB{}, err
+	}
+	failures.AccountLastFailure, failures.IPLastFailure = accountLast.ptr(), ipLast.ptr()
+	return failures, nil
+}
+
+func (s *SQLiteStore) GetRecentLoginFailures(ctx context.Context, userId, limit int) ([]LoginAttemptDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, ip_address, user_agent, succeeded, attempted_at FROM login_attempts
+	WHERE fk_user_id = $1 AND NOT succeeded ORDER BY attempted_at DESC LIMIT $2`, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteScanLoginAttempts(rows)
+}
+
+func (s *SQLiteStore) GetLoginHistory(ctx context.Context, userId int) ([]LoginAttemptDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, ip_address, user_agent, succeeded, attempted_at FROM login_attempts
+	WHERE fk_user_id = $1 ORDER BY attempted_at DESC`, userId)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteScanLoginAttempts(rows)
+}
+
+func sqliteScanLoginAttempts(rows *sql.Rows) ([]LoginAttemptDB, error) {
+	defer rows.Close()
+
+	var attempts []LoginAttemptDB
+	for rows.Next() {
+		var attempt LoginAttemptDB
+		var attemptedAt sqliteTimestamp
+		if err := rows.Scan(&attempt.ID, &attempt.IPAddress, &attempt.UserAgent, &attempt.Succeeded, &attemptedAt); err != nil {
+			return nil, err
+		}
+		attempt.AttemptedAt = attemptedAt.Time
+		attempts = append(attempts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// administration and account deletion
+
+func (s *SQLiteStore) GetUsersPage(ctx context.Context, limit, offset int) ([]AdminUserDB, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, full_name, email, role, verified_at IS NOT NULL, disabled_at, password_reset_required, created_at
+	FROM users ORDER BY id LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []AdminUserDB
+	for rows.Next() {
+		var user AdminUserDB
+		var disabledAt, createdAt sqliteTimestamp
+		if err := rows.Scan(&user.ID, &user.FullName, &user.Email, &user.Role, &user.Verified, &disabledAt, &user.PasswordResetRequired, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		user.DisabledAt, user.CreatedAt = disabledAt.ptr(), createdAt.Time
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *SQLiteStore) SetUserDisabled(ctx context.Context, userId int, disabled bool) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, $3) END,
+	updated_at = $3 WHERE id = $2`, disabled, userId, sqliteTime(time.Now()))
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrUserNotFound
+	}
+
+	if disabled {
+		if err := sqliteRevokeAllSessions(ctx, tx, userId); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) RequirePasswordReset(ctx context.Context, userId int) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRowContext(ctx, `UPDATE users SET password_reset_required = TRUE, updated_at = $2
+	WHERE id = $1 RETURNING email`, userId, sqliteTime(time.Now())).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := sqliteRevokeAllSessions(ctx, tx, userId); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
+func (s *SQLiteStore) ScheduleUserDeletion(ctx context.Context, userId int) (time.Time, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	var deletedAt sqliteTimestamp
+	err = tx.QueryRowContext(ctx, `UPDATE users SET deleted_at = COALESCE(deleted_at, $2)
+	WHERE id = $1 RETURNING deleted_at`, userId, sqliteTime(time.Now())).Scan(&deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrUserNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := sqliteRevokeAllSessions(ctx, tx, userId); err != nil {
+		return time.Time{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+	return deletedAt.Time, nil
+}
+
+func (s *SQLiteStore) CancelUserDeletion(ctx context.Context, userId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET deleted_at = NULL WHERE id = $1`, userId)
+	return err
+}
+
+func (s *SQLiteStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before := sqliteTime(deletedBefore)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM notes WHERE fk_user_id IN
+	(SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1)`, before); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// audit log
+
+func (s *SQLiteStore) RecordAuditEvent(ctx context.Context, userId, actorId int, action, target, details, ip, userAgent string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var actor *int // 0 when nobody in particular caused it
+	if actorId != 0 {
+		actor = &actorId
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO audit_events (fk_user_id, actor_id, action, target, details, ip_address, user_agent, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, userId, actor, action, target, details, ip, userAgent, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to store the audit event: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetAuditEvents(ctx context.Context, userId, limit, offset int) ([]AuditEventDB, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_events WHERE fk_user_id = $1`, userId).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, actor_id, action, target, details, ip_address, user_agent, created_at
+	FROM audit_events WHERE fk_user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`, userId, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []AuditEventDB
+	for rows.Next() {
+		var event AuditEventDB
+		var createdAt sqliteTimestamp
+		if err := rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.Target, &event.Details, &event.IPAddress, &event.UserAgent, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		event.CreatedAt = createdAt.Time
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
@@ -0,0 +1,1049 @@
+package jaegerdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// the SQLite versions of the queries for what hangs off a note, they follow the Postgres functions of the same name
+
+// interviews
+
+// sqliteInterviewColumns is interviewColumns without the table names, RETURNING only takes the bare columns
+const sqliteInterviewColumns = `id, fk_note_id, round, interview_type, starts_at, ends_at, time_zone, location, video_link,
+	interviewers, outcome, prep_notes, created_at, updated_at`
+
+func sqliteScanInterview(row interface{ Scan(...any) error }, extra ...any) (InterviewDB, error) {
+	var interview InterviewDB
+	var interviewers string
+	var startsAt, endsAt, createdAt, updatedAt sqliteTimestamp
+	dest := []any{&interview.ID, &interview.NoteID, &interview.Round, &interview.Type, &startsAt, &endsAt,
+		&interview.TimeZone, &interview.Location, &interview.VideoLink, &interviewers, &interview.Outcome, &interview.PrepNotes,
+		&createdAt, &updatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return InterviewDB{}, err
+	}
+	interview.StartsAt, interview.EndsAt = startsAt.Time, endsAt.ptr()
+	interview.CreatedAt, interview.UpdatedAt = createdAt.Time, updatedAt.Time
+	interview.Interviewers = splitInterviewers(interviewers)
+	interview.inTimeZone()
+	return interview, nil
+}
+
+// sqliteOwnsNote reports whether the note belongs to the user
+func sqliteOwnsNote(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}, userId, noteId int) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $2)`, noteId, userId).Scan(&exists)
+	return exists, err
+}
+
+func (s *SQLiteStore) CreateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return InterviewDB{}, err
+	}
+	defer tx.Rollback()
+
+	owned, err := sqliteOwnsNote(ctx, tx, userId, noteId)
+	if err != nil {
+		return InterviewDB{}, err
+	}
+	if !owned {
+		return InterviewDB{}, ErrNoteNotFound
+	}
+
+	now := sqliteTime(time.Now())
+	created, err := sqliteScanInterview(tx.QueryRowContext(ctx, `INSERT INTO interviews (fk_note_id, round, interview_type, starts_at, ends_at, time_zone,
+	location, video_link, interviewers, outcome, prep_notes, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+	RETURNING `+sqliteInterviewColumns, noteId, interview.Round, interview.Type, sqliteTime(interview.StartsAt), sqliteNullTime(interview.EndsAt),
+		interview.TimeZone, interview.Location, interview.VideoLink, joinInterviewers(interview.Interviewers), interview.Outcome, interview.PrepNotes, now))
+	if err != nil {
+		log.Printf("Unable to store the interview: %s", err)
+		return InterviewDB{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return InterviewDB{}, err
+	}
+	return created, nil
+}
+
+func (s *SQLiteStore) GetNoteInterviews(ctx context.Context, userId, noteId int) ([]InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	owned, err := sqliteOwnsNote(ctx, s.db, userId, noteId)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+interviewColumns+` FROM interviews
+	WHERE fk_note_id = $1 ORDER BY starts_at, id`, noteId)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteScanInterviews(rows)
+}
+
+func sqliteScanInterviews(rows *sql.Rows) ([]InterviewDB, error) {
+	defer rows.Close()
+
+	var interviews []InterviewDB
+	for rows.Next() {
+		interview, err := sqliteScanInterview(rows)
+		if err != nil {
+			return nil, err
+		}
+		interviews = append(interviews, interview)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return interviews, nil
+}
+
+func (s *SQLiteStore) GetInterview(ctx context.Context, userId, noteId, interviewId int) (InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	interview, err := sqliteScanInterview(s.db.QueryRowContext(ctx, `SELECT `+interviewColumns+` FROM interviews
+	JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE interviews.id = $1 AND interviews.fk_note_id = $2 AND notes.fk_user_id = $3`, interviewId, noteId, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return InterviewDB{}, ErrInterviewNotFound
+	}
+	if err != nil {
+		return InterviewDB{}, err
+	}
+	return interview, nil
+}
+
+func (s *SQLiteStore) UpdateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	updated, err := sqliteScanInterview(s.db.QueryRowContext(ctx, `UPDATE interviews SET round = $4, interview_type = $5, starts_at = $6, ends_at = $7,
+	time_zone = $8, location = $9, video_link = $10, interviewers = $11, outcome = $12, prep_notes = $13, updated_at = $14
+	WHERE id = $1 AND fk_note_id = $2 AND fk_note_id IN (SELECT id FROM notes WHERE fk_user_id = $3)
+	RETURNING `+sqliteInterviewColumns, interview.ID, noteId, userId, interview.Round, interview.Type, sqliteTime(interview.StartsAt),
+		sqliteNullTime(interview.EndsAt), interview.TimeZone, interview.Location, interview.VideoLink, joinInterviewers(interview.Interviewers),
+		interview.Outcome, interview.PrepNotes, sqliteTime(time.Now())))
+	if errors.Is(err, sql.ErrNoRows) {
+		return InterviewDB{}, ErrInterviewNotFound
+	}
+	if err != nil {
+		log.Printf("Unable to update interview %d: %s", interview.ID, err)
+		return InterviewDB{}, err
+	}
+	return updated, nil
+}
+
+func (s *SQLiteStore) DeleteInterview(ctx context.Context, userId, noteId, interviewId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM interviews
+	WHERE id = $1 AND fk_note_id = $2 AND fk_note_id IN (SELECT id FROM notes WHERE fk_user_id = $3)`, interviewId, noteId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrInterviewNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetUpcomingInterviews(ctx context.Context, userId int, from, until time.Time) ([]UpcomingInterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+interviewColumns+`, notes.company_name, notes."position", notes.application_status
+	FROM interviews JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE notes.fk_user_id = $1 AND interviews.starts_at >= $2 AND interviews.starts_at < $3 AND interviews.outcome <> $4
+	ORDER BY interviews.starts_at, interviews.id`, userId, sqliteTime(from), sqliteTime(until), OutcomeCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interviews []UpcomingInterviewDB
+	for rows.Next() {
+		var upcoming UpcomingInterviewDB
+		upcoming.InterviewDB, err = sqliteScanInterview(rows, &upcoming.CompanyName, &upcoming.Position, &upcoming.ApplicationStatus)
+		if err != nil {
+			return nil, err
+		}
+		interviews = append(interviews, upcoming)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return interviews, nil
+}
+
+func (s *SQLiteStore) GetUserInterviews(ctx context.Context, userId int) ([]InterviewDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+interviewColumns+` FROM interviews
+	JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE notes.fk_user_id = $1 ORDER BY interviews.fk_note_id, interviews.starts_at, interviews.id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteScanInterviews(rows)
+}
+
+// contacts
+
+func sqliteScanContact(row interface{ Scan(...any) error }) (ContactDB, error) {
+	var contact ContactDB
+	var createdAt, updatedAt sqliteTimestamp
+	err := row.Scan(&contact.ID, &contact.Name, &contact.Role, &contact.Email, &contact.Phone, &contact.LinkedInURL, &contact.Notes,
+		&createdAt, &updatedAt)
+	contact.CreatedAt, contact.UpdatedAt = createdAt.Time, updatedAt.Time
+	contact.NoteIDs = []int{}
+	return contact, err
+}
+
+func (s *SQLiteStore) CreateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	created, err := sqliteScanContact(s.db.QueryRowContext(ctx, `INSERT INTO contacts (fk_user_id, name, role, email, phone, linkedin_url, notes, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+	RETURNING id, name, role, email, phone, linkedin_url, notes, created_at, updated_at`,
+		userId, contact.Name, contact.Role, contact.Email, contact.Phone, contact.LinkedInURL, contact.Notes, sqliteTime(time.Now())))
+	if err != nil {
+		log.Printf("Unable to store the contact: %s", err)
+		return ContactDB{}, err
+	}
+	return created, nil
+}
+
+func (s *SQLiteStore) GetContacts(ctx context.Context, userId int) ([]ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+contactColumns+` FROM contacts WHERE fk_user_id = $1 ORDER BY LOWER(name), id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := sqliteScanContacts(rows)
+	if err != nil {
+		return nil, err
+	}
+	byId := map[int]*ContactDB{}
+	for i := range contacts {
+		byId[contacts[i].ID] = &contacts[i]
+	}
+
+	links, err := s.db.QueryContext(ctx, `SELECT note_contacts.fk_contact_id, note_contacts.fk_note_id FROM note_contacts
+	JOIN contacts ON contacts.id = note_contacts.fk_contact_id
+	WHERE contacts.fk_user_id = $1 ORDER BY note_contacts.fk_note_id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer links.Close()
+
+	for links.Next() {
+		var contactId, noteId int
+		if err := links.Scan(&contactId, &noteId); err != nil {
+			return nil, err
+		}
+		if contact, ok := byId[contactId]; ok {
+			contact.NoteIDs = append(contact.NoteIDs, noteId)
+		}
+	}
+	if err := links.Err(); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+func sqliteScanContacts(rows *sql.Rows) ([]ContactDB, error) {
+	defer rows.Close()
+
+	var contacts []ContactDB
+	for rows.Next() {
+		contact, err := sqliteScanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+func (s *SQLiteStore) GetContact(ctx context.Context, userId, contactId int) (ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	contact, err := sqliteScanContact(s.db.QueryRowContext(ctx, `SELECT `+contactColumns+` FROM contacts WHERE id = $1 AND fk_user_id = $2`, contactId, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ContactDB{}, ErrContactNotFound
+	}
+	if err != nil {
+		return ContactDB{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT fk_note_id FROM note_contacts WHERE fk_contact_id = $1 ORDER BY fk_note_id`, contactId)
+	if err != nil {
+		return ContactDB{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteId int
+		if err := rows.Scan(&noteId); err != nil {
+			return ContactDB{}, err
+		}
+		contact.NoteIDs = append(contact.NoteIDs, noteId)
+	}
+	if err := rows.Err(); err != nil {
+		return ContactDB{}, err
+	}
+	return contact, nil
+}
+
+func (s *SQLiteStore) UpdateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE contacts SET name = $3, role = $4, email = $5, phone = $6, linkedin_url = $7, notes = $8,
+	updated_at = $9 WHERE id = $1 AND fk_user_id = $2`,
+		contact.ID, userId, contact.Name, contact.Role, contact.Email, contact.Phone, contact.LinkedInURL, contact.Notes, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to update contact %d: %s", contact.ID, err)
+		return ContactDB{}, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ContactDB{}, ErrContactNotFound
+	}
+	return s.GetContact(ctx, userId, contact.ID)
+}
+
+func (s *SQLiteStore) DeleteContact(ctx context.Context, userId, contactId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM contacts WHERE id = $1 AND fk_user_id = $2`, contactId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) LinkContact(ctx context.Context, userId, noteId, contactId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var noteOwned, contactOwned bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $3),
+	EXISTS (SELECT 1 FROM contacts WHERE id = $2 AND fk_user_id = $3)`, noteId, contactId, userId).Scan(&noteOwned, &contactOwned)
+	if err != nil {
+		return err
+	}
+	if !noteOwned {
+		return ErrNoteNotFound
+	}
+	if !contactOwned {
+		return ErrContactNotFound
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO note_contacts (fk_note_id, fk_contact_id, created_at) VALUES ($1, $2, $3)
+	ON CONFLICT (fk_note_id, fk_contact_id) DO NOTHING`, noteId, contactId, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to link contact %d to note %d: %s", contactId, noteId, err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UnlinkContact(ctx context.Context, userId, noteId, contactId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM note_contacts
+	WHERE fk_note_id = $1 AND fk_contact_id = $2 AND fk_contact_id IN (SELECT id FROM contacts WHERE fk_user_id = $3)`, noteId, contactId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetNoteContacts(ctx context.Context, userId, noteId int) ([]ContactDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	owned, err := sqliteOwnsNote(ctx, s.db, userId, noteId)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+contactColumns+` FROM contacts
+	JOIN note_contacts ON note_contacts.fk_contact_id = contacts.id
+	WHERE note_contacts.fk_note_id = $1 AND contacts.fk_user_id = $2 ORDER BY LOWER(contacts.name), contacts.id`, noteId, userId)
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := sqliteScanContacts(rows)
+	for i := range contacts {
+		contacts[i].NoteIDs = nil // only filled in where contacts are listed on their own
+	}
+	return contacts, err
+}
+
+func (s *SQLiteStore) GetContactNotes(ctx context.Context, userId, contactId int) ([]NoteDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM contacts WHERE id = $1 AND fk_user_id = $2)`, contactId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrContactNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT notes.id, notes.note_id, notes.company_name, notes."position", notes.salary, notes.application_status,
+	notes.applied_on, notes.fk_user_id, notes.updated_at, notes.description
+	FROM notes JOIN note_contacts ON note_contacts.fk_note_id = notes.id
+	WHERE note_contacts.fk_contact_id = $1 AND notes.fk_user_id = $2 ORDER BY notes.applied_on DESC, notes.id`, contactId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []NoteDB
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// companies
+
+func sqliteScanCompany(row interface{ Scan(...any) error }) (CompanyDB, error) {
+	var company CompanyDB
+	var createdAt, updatedAt sqliteTimestamp
+	err := row.Scan(&company.ID, &company.Name, &company.Website, &company.Industry, &company.Size, &company.Location,
+		&createdAt, &updatedAt, &company.ApplicationCount)
+	company.CreatedAt, company.UpdatedAt = createdAt.Time, updatedAt.Time
+	company.Aliases = []CompanyAliasDB{}
+	return company, err
+}
+
+func sqliteFindCompany(ctx context.Context, tx *sql.Tx, userId int, normalized string) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM companies WHERE fk_user_id = $1 AND normalized_name = $2
+	UNION ALL SELECT fk_company_id FROM company_aliases WHERE fk_user_id = $1 AND normalized_alias = $2 LIMIT 1`, userId, normalized).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+func sqliteResolveCompany(ctx context.Context, tx *sql.Tx, userId int, name string) (*int, error) {
+	normalized := normalizeCompanyName(name)
+	if normalized == "" {
+		return nil, nil
+	}
+	id, err := sqliteFindCompany(ctx, tx, userId, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		now := sqliteTime(time.Now())
+		err = tx.QueryRowContext(ctx, `INSERT INTO companies (fk_user_id, name, normalized_name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (fk_user_id, normalized_name) DO UPDATE SET normalized_name = excluded.normalized_name RETURNING id`,
+			userId, strings.TrimSpace(name), normalized, now).Scan(&id)
+		if err != nil {
+			log.Printf("Unable to store company %q: %s", name, err)
+			return nil, err
+		}
+	}
+	return &id, nil
+}
+
+func sqliteAssignCompanies(ctx context.Context, tx *sql.Tx, userId int) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, company_name FROM notes WHERE fk_user_id = $1 AND fk_company_id IS NULL`, userId)
+	if err != nil {
+		return err
+	}
+	names := map[int]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for noteId, name := range names {
+		companyId, err := sqliteResolveCompany(ctx, tx, userId, name)
+		if err != nil {
+			return err
+		}
+		if companyId == nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE notes SET fk_company_id = $1 WHERE id = $2`, *companyId, noteId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	normalized := normalizeCompanyName(company.Name)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return CompanyDB{}, err
+	}
+	defer tx.Rollback()
+
+	if id, err := sqliteFindCompany(ctx, tx, userId, normalized); err != nil {
+		return CompanyDB{}, err
+	} else if id != 0 {
+		return CompanyDB{}, ErrCompanyExists
+	}
+
+	now := sqliteTime(time.Now())
+	var id int
+	err = tx.QueryRowContext(ctx, `INSERT INTO companies (fk_user_id, name, normalized_name, website, industry, size, location, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8) RETURNING id`,
+		userId, company.Name, normalized, company.Website, company.Industry, company.Size, company.Location, now).Scan(&id)
+	if err != nil {
+		log.Printf("Unable to store the company: %s", err)
+		return CompanyDB{}, err
+	}
+	if err := sqliteAssignCompanies(ctx, tx, userId); err != nil {
+		return CompanyDB{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return CompanyDB{}, err
+	}
+	return s.getCompany(ctx, userId, id)
+}
+
+func (s *SQLiteStore) GetCompanies(ctx context.Context, userId int) ([]CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := sqliteAssignCompanies(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+companyColumns+`, (SELECT COUNT(*) FROM notes WHERE notes.fk_company_id = companies.id)
+	FROM companies WHERE fk_user_id = $1 ORDER BY companies.normalized_name, companies.id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var companies []CompanyDB
+	for rows.Next() {
+		company, err := sqliteScanCompany(rows)
+		if err != nil {
+			return nil, err
+		}
+		companies = append(companies, company)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	byId := map[int]*CompanyDB{}
+	for i := range companies {
+		byId[companies[i].ID] = &companies[i]
+	}
+
+	aliases, err := s.db.QueryContext(ctx, `SELECT fk_company_id, id, alias FROM company_aliases WHERE fk_user_id = $1 ORDER BY id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer aliases.Close()
+
+	for aliases.Next() {
+		var companyId int
+		var alias CompanyAliasDB
+		if err := aliases.Scan(&companyId, &alias.ID, &alias.Alias); err != nil {
+			return nil, err
+		}
+		if company, ok := byId[companyId]; ok {
+			company.Aliases = append(company.Aliases, alias)
+		}
+	}
+	if err := aliases.Err(); err != nil {
+		return nil, err
+	}
+	return companies, nil
+}
+
+func (s *SQLiteStore) getCompany(ctx context.Context, userId, companyId int) (CompanyDB, error) {
+	company, err := sqliteScanCompany(s.db.QueryRowContext(ctx, `SELECT `+companyColumns+`, (SELECT COUNT(*) FROM notes WHERE notes.fk_company_id = companies.id)
+	FROM companies WHERE id = $1 AND fk_user_id = $2`, companyId, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+	if err != nil {
+		return CompanyDB{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, alias FROM company_aliases WHERE fk_company_id = $1 ORDER BY id`, companyId)
+	if err != nil {
+		return CompanyDB{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias CompanyAliasDB
+		if err := rows.Scan(&alias.ID, &alias.Alias); err != nil {
+			return CompanyDB{}, err
+		}
+		company.Aliases = append(company.Aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return CompanyDB{}, err
+	}
+	return company, nil
+}
+
+func (s *SQLiteStore) GetCompany(ctx context.Context, userId, companyId int) (CompanyDetailDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	company, err := s.getCompany(ctx, userId, companyId)
+	if err != nil {
+		return CompanyDetailDB{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, note_id, company_name, "position", salary, application_status, applied_on, fk_user_id, updated_at, description
+	FROM notes WHERE fk_company_id = $1 AND fk_user_id = $2 ORDER BY applied_on DESC, id`, companyId, userId)
+	if err != nil {
+		return CompanyDetailDB{}, err
+	}
+	defer rows.Close()
+
+	notes := []NoteDB{}
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return CompanyDetailDB{}, err
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return CompanyDetailDB{}, err
+	}
+	rows.Close()
+
+	var interviews int
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM interviews JOIN notes ON notes.id = interviews.fk_note_id
+	WHERE notes.fk_company_id = $1 AND notes.fk_user_id = $2`, companyId, userId).Scan(&interviews)
+	if err != nil {
+		return CompanyDetailDB{}, err
+	}
+
+	return CompanyDetailDB{CompanyDB: company, Stats: companyStats(notes, interviews), Applications: notes}, nil
+}
+
+func (s *SQLiteStore) UpdateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	normalized := normalizeCompanyName(company.Name)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return CompanyDB{}, err
+	}
+	defer tx.Rollback()
+
+	var oldName, oldNormalized string
+	err = tx.QueryRowContext(ctx, `SELECT name, normalized_name FROM companies WHERE id = $1 AND fk_user_id = $2`,
+		company.ID, userId).Scan(&oldName, &oldNormalized)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+	if err != nil {
+		return CompanyDB{}, err
+	}
+
+	now := sqliteTime(time.Now())
+	if normalized != oldNormalized {
+		if id, err := sqliteFindCompany(ctx, tx, userId, normalized); err != nil {
+			return CompanyDB{}, err
+		} else if id != 0 && id != company.ID {
+			return CompanyDB{}, ErrCompanyExists
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM company_aliases WHERE fk_company_id = $1 AND normalized_alias = $2`, company.ID, normalized); err != nil {
+			return CompanyDB{}, err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO company_aliases (fk_company_id, fk_user_id, alias, normalized_alias, created_at)
+		VALUES ($1, $2, $3, $4, $5)`, company.ID, userId, oldName, oldNormalized, now); err != nil {
+			return CompanyDB{}, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE companies SET name = $3, normalized_name = $4, website = $5, industry = $6, size = $7, location = $8,
+	updated_at = $9 WHERE id = $1 AND fk_user_id = $2`,
+		company.ID, userId, company.Name, normalized, company.Website, company.Industry, company.Size, company.Location, now)
+	if err != nil {
+		log.Printf("Unable to update company %d: %s", company.ID, err)
+		return CompanyDB{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return CompanyDB{}, err
+	}
+	return s.getCompany(ctx, userId, company.ID)
+}
+
+func (s *SQLiteStore) DeleteCompany(ctx context.Context, userId, companyId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists, hasNotes bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM companies WHERE id = $1 AND fk_user_id = $2),
+	EXISTS (SELECT 1 FROM notes WHERE fk_company_id = $1)`, companyId, userId).Scan(&exists, &hasNotes)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrCompanyNotFound
+	}
+	if hasNotes {
+		return ErrCompanyHasNotes
+	}
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM companies WHERE id = $1 AND fk_user_id = $2
+	AND NOT EXISTS (SELECT 1 FROM notes WHERE fk_company_id = $1)`, companyId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrCompanyHasNotes
+	}
+	return nil
+}
+
+func (s *SQLiteStore) AddCompanyAlias(ctx context.Context, userId, companyId int, alias string) (CompanyDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	normalized := normalizeCompanyName(alias)
+	if normalized == "" {
+		return CompanyDB{}, ErrInvalidCompanyName
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return CompanyDB{}, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM companies WHERE id = $1 AND fk_user_id = $2)`, companyId, userId).Scan(&exists); err != nil {
+		return CompanyDB{}, err
+	}
+	if !exists {
+		return CompanyDB{}, ErrCompanyNotFound
+	}
+
+	var otherId int
+	var otherIsAlias bool
+	err = tx.QueryRowContext(ctx, `SELECT id, FALSE FROM companies WHERE fk_user_id = $1 AND normalized_name = $2
+	UNION ALL SELECT fk_company_id, TRUE FROM company_aliases WHERE fk_user_id = $1 AND normalized_alias = $2 LIMIT 1`,
+		userId, normalized).Scan(&otherId, &otherIsAlias)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return CompanyDB{}, err
+	case otherId == companyId:
+		tx.Rollback() // the company already goes by that name, the connection is needed for reading it
+		return s.getCompany(ctx, userId, companyId)
+	case otherIsAlias:
+		return CompanyDB{}, ErrCompanyExists
+	default:
+		if err := sqliteMergeCompany(ctx, tx, otherId, companyId); err != nil {
+			return CompanyDB{}, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO company_aliases (fk_company_id, fk_user_id, alias, normalized_alias, created_at)
+	VALUES ($1, $2, $3, $4, $5)`, companyId, userId, alias, normalized, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to store alias %q of company %d: %s", alias, companyId, err)
+		return CompanyDB{}, err
+	}
+	if err := sqliteAssignCompanies(ctx, tx, userId); err != nil {
+		return CompanyDB{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return CompanyDB{}, err
+	}
+	return s.getCompany(ctx, userId, companyId)
+}
+
+func sqliteMergeCompany(ctx context.Context, tx *sql.Tx, fromId, intoId int) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE notes SET fk_company_id = $2 WHERE fk_company_id = $1`, fromId, intoId); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE company_aliases SET fk_company_id = $2 WHERE fk_company_id = $1`, fromId, intoId); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM companies WHERE id = $1`, fromId)
+	return err
+}
+
+func (s *SQLiteStore) DeleteCompanyAlias(ctx context.Context, userId, companyId, aliasId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM company_aliases WHERE id = $1 AND fk_company_id = $2 AND fk_user_id = $3`, aliasId, companyId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrCompanyAliasNotFound
+	}
+	return nil
+}
+
+// tags
+
+func sqliteScanTag(row interface{ Scan(...any) error }) (TagDB, error) {
+	var tag TagDB
+	var createdAt, updatedAt sqliteTimestamp
+	err := row.Scan(&tag.ID, &tag.Name, &tag.Color, &createdAt, &updatedAt, &tag.NoteCount)
+	tag.CreatedAt, tag.UpdatedAt = createdAt.Time, updatedAt.Time
+	return tag, err
+}
+
+const sqliteTagQuery = `SELECT ` + tagColumns + `, (SELECT COUNT(*) FROM note_tags WHERE note_tags.fk_tag_id = tags.id) FROM tags`
+
+func (s *SQLiteStore) CreateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := sqliteTime(time.Now())
+	var id int
+	err := s.db.QueryRowContext(ctx, `INSERT INTO tags (fk_user_id, name, color, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $4) ON CONFLICT DO NOTHING RETURNING id`, userId, tag.Name, tag.Color, now).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TagDB{}, ErrTagExists
+	}
+	if err != nil {
+		log.Printf("Unable to store the tag: %s", err)
+		return TagDB{}, err
+	}
+	return sqliteScanTag(s.db.QueryRowContext(ctx, sqliteTagQuery+` WHERE id = $1`, id))
+}
+
+func (s *SQLiteStore) GetTags(ctx context.Context, userId int) ([]TagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, sqliteTagQuery+` WHERE fk_user_id = $1 ORDER BY LOWER(name), id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []TagDB
+	for rows.Next() {
+		tag, err := sqliteScanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *SQLiteStore) UpdateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var taken bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM tags WHERE fk_user_id = $1 AND LOWER(name) = LOWER($2) AND id <> $3)`,
+		userId, tag.Name, tag.ID).Scan(&taken)
+	if err != nil {
+		return TagDB{}, err
+	}
+	if taken {
+		return TagDB{}, ErrTagExists
+	}
+
+	result, err := s.db.ExecContext(ctx, `UPDATE tags SET name = $3, color = $4, updated_at = $5 WHERE id = $1 AND fk_user_id = $2`,
+		tag.ID, userId, tag.Name, tag.Color, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to update tag %d: %s", tag.ID, err)
+		return TagDB{}, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return TagDB{}, ErrTagNotFound
+	}
+	return sqliteScanTag(s.db.QueryRowContext(ctx, sqliteTagQuery+` WHERE id = $1`, tag.ID))
+}
+
+func (s *SQLiteStore) DeleteTag(ctx context.Context, userId, tagId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM tags WHERE id = $1 AND fk_user_id = $2`, tagId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) TagNote(ctx context.Context, userId, noteId, tagId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var noteOwned, tagOwned bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $3),
+	EXISTS (SELECT 1 FROM tags WHERE id = $2 AND fk_user_id = $3)`, noteId, tagId, userId).Scan(&noteOwned, &tagOwned)
+	if err != nil {
+		return err
+	}
+	if !noteOwned {
+		return ErrNoteNotFound
+	}
+	if !tagOwned {
+		return ErrTagNotFound
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO note_tags (fk_note_id, fk_tag_id, created_at) VALUES ($1, $2, $3)
+	ON CONFLICT (fk_note_id, fk_tag_id) DO NOTHING`, noteId, tagId, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to tag note %d with tag %d: %s", noteId, tagId, err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UntagNote(ctx context.Context, userId, noteId, tagId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM note_tags
+	WHERE fk_note_id = $1 AND fk_tag_id = $2 AND fk_tag_id IN (SELECT id FROM tags WHERE fk_user_id = $3)`, noteId, tagId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetNoteTags(ctx context.Context, userId, noteId int) ([]NoteTagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	owned, err := sqliteOwnsNote(ctx, s.db, userId, noteId)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT tags.id, tags.name, tags.color FROM tags
+	JOIN note_tags ON note_tags.fk_tag_id = tags.id
+	WHERE note_tags.fk_note_id = $1 AND tags.fk_user_id = $2 ORDER BY LOWER(tags.name), tags.id`, noteId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []NoteTagDB
+	for rows.Next() {
+		var tag NoteTagDB
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
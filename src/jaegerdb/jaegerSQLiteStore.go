@@ -0,0 +1,608 @@
+package jaegerdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerpassword"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore implements UserStore and NoteStore on a single database file, for running Jaeger without Postgres
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+var (
+	_ UserStore = (*SQLiteStore)(nil)
+	_ NoteStore = (*SQLiteStore)(nil)
+	_ Migrator  = (*SQLiteStore)(nil)
+)
+
+// timestamps are written as fixed width UTC text so comparing them in queries compares the times
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000"
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func sqliteNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+// sqliteTimestamp scans timestamp columns, the driver returns them as time.Time or as text depending on the query
+type sqliteTimestamp struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *sqliteTimestamp) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*t = sqliteTimestamp{}
+	case time.Time:
+		*t = sqliteTimestamp{Time: v.UTC(), Valid: true}
+	case string:
+		parsed, err := time.Parse("2006-01-02 15:04:05.999999999", v)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q: %w", v, err)
+		}
+		*t = sqliteTimestamp{Time: parsed, Valid: true}
+	case []byte:
+		return t.Scan(string(v))
+	default:
+		return fmt.Errorf("can't scan %T into a timestamp", value)
+	}
+	return nil
+}
+
+func (t sqliteTimestamp) ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// ConnectSQLite opens the database file SQLITE_PATH points to, ../jaeger.db by default
+func ConnectSQLite() (*SQLiteStore, error) {
+	path := os.Getenv("SQLITE_PATH")
+	if path == "" {
+		path = "../jaeger.db"
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has a single writer anyway, one connection keeps transactions from failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Printf("Opened the SQLite database %s", path)
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Close() {
+	s.db.Close()
+}
+
+// migrations
+
+type sqliteMigrationTarget struct {
+	conn *sql.Conn
+}
+
+func (t sqliteMigrationTarget) dialect() string {
+	return dialectSQLite
+}
+
+func (t sqliteMigrationTarget) exec(ctx context.Context, query string) error {
+	_, err := t.conn.ExecContext(ctx, query)
+	return err
+}
+
+func (t sqliteMigrationTarget) appliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := t.conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt sqliteTimestamp
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt.Time
+	}
+	return applied, rows.Err()
+}
+
+// apply runs in a savepoint of the transaction withMigrationLock holds, a failing migration is undone on its own
+func (t sqliteMigrationTarget) apply(ctx context.Context, query, record string, args ...any) error {
+	if _, err := t.conn.ExecContext(ctx, `SAVEPOINT migration`); err != nil {
+		return err
+	}
+
+	_, err := t.conn.ExecContext(ctx, query)
+	if err == nil {
+		_, err = t.conn.ExecContext(ctx, record, args...)
+	}
+	if err != nil {
+		t.conn.ExecContext(context.Background(), `ROLLBACK TO migration`)
+		t.conn.ExecContext(context.Background(), `RELEASE migration`)
+		return err
+	}
+	_, err = t.conn.ExecContext(ctx, `RELEASE migration`)
+	return err
+}
+
+// withMigrationLock runs fn in a transaction holding the write lock of the database file. Like the advisory lock on Postgres
+// it keeps other processes using the same file from checking and applying migrations at the same time, they wait for busy_timeout
+func (s *SQLiteStore) withMigrationLock(ctx context.Context, fn func(target migrationTarget) error) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return err
+	}
+	// the migrations applied before a failing one are kept, the same as on Postgres where each has its own transaction
+	fnErr := fn(sqliteMigrationTarget{conn: conn})
+	if _, err := conn.ExecContext(context.Background(), `COMMIT`); err != nil {
+		conn.ExecContext(context.Background(), `ROLLBACK`)
+		if fnErr == nil {
+			fnErr = err
+		}
+	}
+	return fnErr
+}
+
+func (s *SQLiteStore) MigrateUp(ctx context.Context) (done []Migration, err error) {
+	err = s.withMigrationLock(ctx, func(target migrationTarget) error {
+		done, err = migrateUp(ctx, target)
+		return err
+	})
+	return done, err
+}
+
+func (s *SQLiteStore) MigrateDown(ctx context.Context, steps int) (done []Migration, err error) {
+	err = s.withMigrationLock(ctx, func(target migrationTarget) error {
+		done, err = migrateDown(ctx, target, steps)
+		return err
+	})
+	return done, err
+}
+
+func (s *SQLiteStore) MigrationStatus(ctx context.Context) (status []MigrationStatusDB, err error) {
+	err = s.withMigrationLock(ctx, func(target migrationTarget) error {
+		status, err = migrationStatus(ctx, target)
+		return err
+	})
+	return status, err
+}
+
+// users
+
+func (s *SQLiteStore) CreateUserJaeger(ctx context.Context, fullName, email, password string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	passwordHash, err := jaegerpassword.Hash(password)
+	if err != nil {
+		log.Printf("Unable to hash the password: %s", err)
+		return err
+	}
+
+	now := sqliteTime(time.Now())
+	result, err := s.db.ExecContext(ctx, `INSERT INTO users (full_name, email, password, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $4)
+	ON CONFLICT (email) DO NOTHING`, fullName, email, passwordHash, now)
+	if err != nil {
+		log.Printf("Unable to insert the data into DB: %s", err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		log.Printf("No rows inserted. A user with email %s already exists.", email)
+		return ErrUserExists
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetUserByEmail(ctx context.Context, email string) (*RetrievedUser, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var user RetrievedUser
+	err := s.db.QueryRowContext(ctx, `SELECT id, full_name, email, verified_at IS NOT NULL, role, disabled_at IS NOT NULL, password_reset_required, deleted_at IS NOT NULL
+	FROM users WHERE email = $1`, email).Scan(&user.ID, &user.FullName, &user.Email, &user.Verified, &user.Role, &user.Disabled, &user.PasswordResetRequired, &user.DeletionScheduled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &RetrievedUser{}, ErrUserNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve user %s", email)
+		return &RetrievedUser{}, err
+	}
+	return &user, nil
+}
+
+func (s *SQLiteStore) CheckCredentialsOnLogin(ctx context.Context, email, password string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var hash string
+	err := s.db.QueryRowContext(ctx, `SELECT password FROM users WHERE email = $1`, email).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Failed to find user with %s email address", email)
+		jaegerpassword.VerifyDummy(password)
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve the password for %s: %s", email, err)
+		return err
+	}
+
+	ok, needsRehash, err := jaegerpassword.Verify(hash, password)
+	if err != nil || !ok {
+		log.Printf("Hash doesn't match the password: %v", err)
+		return ErrInvalidCredentials
+	}
+
+	// hashes made with older settings are replaced while the plain password is at hand
+	if needsRehash {
+		newHash, err := jaegerpassword.Hash(password)
+		if err == nil {
+			_, err = s.db.ExecContext(ctx, `UPDATE users SET password = $1 WHERE email = $2 AND password = $3`, newHash, email, hash)
+		}
+		if err != nil {
+			log.Printf("Failed rehashing the password of %s: %s", email, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UpdateUser(ctx context.Context, id int, fullName, email, password string) (UpdatedUserDataDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if password != "" {
+		passwordHash, err := jaegerpassword.Hash(password)
+		if err != nil {
+			log.Printf("Unable to hash the password: %s", err)
+			return UpdatedUserDataDB{}, err
+		}
+		password = passwordHash
+	}
+
+	// changing the email means it has to be verified again
+	var updatedUser UpdatedUserDataDB
+	err := s.db.QueryRowContext(ctx, `UPDATE users SET full_name = COALESCE($1, full_name), email = COALESCE($2, email), password = COALESCE($3, password),
+	verified_at = CASE WHEN $2 IS NOT NULL AND $2 <> email THEN NULL ELSE verified_at END
+	WHERE id = $4 RETURNING id, full_name, email, password`, stringToNil(&fullName), stringToNil(&email), stringToNil(&password), id).
+		Scan(&updatedUser.id, &updatedUser.fullName, &updatedUser.email, &updatedUser.password)
+	if err != nil {
+		log.Printf("User couldn't be updated: %s", err)
+		return UpdatedUserDataDB{}, err
+	}
+	return updatedUser, nil
+}
+
+func (s *SQLiteStore) GetProfile(ctx context.Context, userId int) (ProfileDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var profile ProfileDB
+	var createdAt, updatedAt, verifiedAt, deletedAt sqliteTimestamp
+	err := s.db.QueryRowContext(ctx, `SELECT id, full_name, email, role, created_at, updated_at, verified_at, deleted_at
+	FROM users WHERE id = $1`, userId).Scan(&profile.ID, &profile.FullName, &profile.Email, &profile.Role,
+		&createdAt, &updatedAt, &verifiedAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ProfileDB{}, ErrUserNotFound
+	}
+	if err != nil {
+		return ProfileDB{}, err
+	}
+	profile.CreatedAt = createdAt.Time
+	profile.UpdatedAt = updatedAt.Time
+	profile.VerifiedAt = verifiedAt.ptr()
+	profile.DeletedAt = deletedAt.ptr()
+	return profile, nil
+}
+
+// notes
+
+func (s *SQLiteStore) CreateNote(ctx context.Context, uuid, companyName, position, salary, applicationStatus, appliedOn, description string, userId int) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	status, err := newNoteStatus(applicationStatus)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := parseAppliedOn(appliedOn)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	companyId, err := sqliteResolveCompany(ctx, tx, userId, companyName)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx, `INSERT INTO notes(
+	note_id, company_name, "position", salary, application_status, applied_on, description, updated_at, fk_user_id, fk_company_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		uuid, companyName, position, salary, status, sqliteTime(applied), description, sqliteTime(time.Now()), userId, companyId).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := sqliteRecordStatusChange(ctx, tx, id, "", status); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// scanNote reads the columns in the order of the notes table and formats the dates like the Postgres queries do
+func scanNote(row interface{ Scan(...any) error }) (NoteDB, error) {
+	var note NoteDB
+	var appliedOn, updatedAt sqliteTimestamp
+	if err := row.Scan(&note.Id, &note.Uuid, &note.CompanyName, &note.Position, &note.Salary, &note.ApplicationStatus, &appliedOn, &note.UserId, &updatedAt, &note.Description); err != nil {
+		return NoteDB{}, err
+	}
+	note.AppliedOn = appliedOn.Time.Format("2006-01-02 15:04:05")
+	note.UpdatedAt = updatedAt.Time.Format("2006-01-02 15:04:05")
+	return note, nil
+}
+
+func (s *SQLiteStore) GetAllUserNotes(ctx context.Context, id int, filter NoteFilter) ([]NoteDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query, args := userNotesQuery(id, filter)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []NoteDB
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tagRows, err := s.db.QueryContext(ctx, noteTagsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	links := map[int][]NoteTagDB{}
+	for tagRows.Next() {
+		var noteId int
+		var tag NoteTagDB
+		if err := tagRows.Scan(&noteId, &tag.ID, &tag.Name, &tag.Color); err != nil {
+			return nil, err
+		}
+		links[noteId] = append(links[noteId], tag)
+	}
+	if err := tagRows.Err(); err != nil {
+		return nil, err
+	}
+	withTags(notes, links)
+	return notes, nil
+}
+
+// UpdateNote only writes the fields that changed, like the Postgres implementation
+func (s *SQLiteStore) UpdateNote(ctx context.Context, userId, id int, company, pos, sal, appStat, appOn, desc string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing CheckNoteForUpdate
+	var existingAppliedOn sqliteTimestamp
+	err = tx.QueryRowContext(ctx, `SELECT company_name, "position", salary, application_status, applied_on, description
+	FROM notes WHERE id = $1 AND fk_user_id = $2`, id, userId).Scan(
+		&existing.companyName, &existing.position, &existing.salary, &existing.status, &existingAppliedOn, &existing.description)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoteNotFound
+	}
+	if err != nil {
+		log.Printf("Error getting the note for updating")
+		return err
+	}
+	existing.appliedOn = existingAppliedOn.Time
+	status, err := nextNoteStatus(existing.status, appStat)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE notes SET"
+	args := []any{}
+	counter := 1
+	set := func(column string, value any) {
+		query += fmt.Sprintf(" %s = $%d,", column, counter)
+		args = append(args, value)
+		counter++
+	}
+	if existing.companyName != company {
+		companyId, err := sqliteResolveCompany(ctx, tx, userId, company)
+		if err != nil {
+			return err
+		}
+		set("company_name", company)
+		set("fk_company_id", companyId)
+	}
+	if existing.position != pos {
+		set(`"position"`, pos)
+	}
+	if existing.salary != sal {
+		set("salary", sal)
+	}
+	if existing.status != status {
+		set("application_status", status)
+		if err := sqliteRecordStatusChange(ctx, tx, id, existing.status, status); err != nil {
+			return err
+		}
+	}
+
+	appliedOnDate, err := time.Parse("2006-01-02", appOn)
+	if err != nil {
+		log.Printf("There is an error with parsing the date: %s", err)
+	}
+	now := time.Now()
+	fullDateTime := time.Date(appliedOnDate.Year(), appliedOnDate.Month(), appliedOnDate.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+	if !existing.appliedOn.Equal(fullDateTime) {
+		set("applied_on", sqliteTime(fullDateTime))
+	}
+	if existing.description != desc {
+		set("description", desc)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	set("updated_at", sqliteTime(now))
+
+	query = strings.TrimSuffix(query, ",")
+	query += fmt.Sprintf(" WHERE id = $%d AND fk_user_id = $%d", counter, counter+1)
+	args = append(args, id, userId)
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetUpdatedNote(ctx context.Context, userId, id int) (NoteDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	note, err := scanNote(s.db.QueryRowContext(ctx, `SELECT id, note_id, company_name, "position", salary, application_status, applied_on, fk_user_id, updated_at, description
+	FROM notes WHERE id = $1 AND fk_user_id = $2`, id, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return NoteDB{}, ErrNoteNotFound
+	}
+	if err != nil {
+		log.Printf("Couldn't get the note after updating: %s", err)
+		return NoteDB{}, err
+	}
+	return note, nil
+}
+
+func (s *SQLiteStore) DeleteNote(ctx context.Context, userId, id int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM notes WHERE id = $1 AND fk_user_id = $2`, id, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+// status timeline
+
+func sqliteRecordStatusChange(ctx context.Context, tx *sql.Tx, noteId int, from, to string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO note_status_history (fk_note_id, from_status, to_status, changed_at)
+	VALUES ($1, $2, $3, $4)`, noteId, stringToNil(&from), to, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Unable to record the status change of note %d: %s", noteId, err)
+	}
+	return err
+}
+
+func (s *SQLiteStore) GetNoteStatusHistory(ctx context.Context, userId, noteId int) ([]NoteStatusChangeDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $2)`, noteId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, fk_note_id, from_status, to_status, changed_at FROM note_status_history
+	WHERE fk_note_id = $1 ORDER BY changed_at, id`, noteId)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteScanStatusChanges(rows)
+}
+
+func (s *SQLiteStore) GetStatusHistory(ctx context.Context, userId int) ([]NoteStatusChangeDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT h.id, h.fk_note_id, h.from_status, h.to_status, h.changed_at
+	FROM note_status_history h JOIN notes ON notes.id = h.fk_note_id
+	WHERE notes.fk_user_id = $1 ORDER BY h.fk_note_id, h.changed_at, h.id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteScanStatusChanges(rows)
+}
+
+func sqliteScanStatusChanges(rows *sql.Rows) ([]NoteStatusChangeDB, error) {
+	defer rows.Close()
+
+	var changes []NoteStatusChangeDB
+	for rows.Next() {
+		var change NoteStatusChangeDB
+		var changedAt sqliteTimestamp
+		if err := rows.Scan(&change.ID, &change.NoteID, &change.FromStatus, &change.ToStatus, &changedAt); err != nil {
+			return nil, err
+		}
+		change.ChangedAt = changedAt.Time
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
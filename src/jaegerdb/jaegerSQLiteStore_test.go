@@ -0,0 +1,292 @@
+package jaegerdb
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+func TestSQLiteUsers(t *testing.T) {
+	ctx := t.Context()
+	store := newSQLiteStore(t)
+	id := createSQLiteUser(t, store, "ada@example.com")
+
+	if err := store.CreateUserJaeger(ctx, "Someone Else", "ada@example.com", "another passphrase"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("second signup with the email = %v, want ErrUserExists", err)
+	}
+	if _, err := store.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown email = %v, want ErrUserNotFound", err)
+	}
+	user, err := store.GetUserByEmail(ctx, "ada@example.com")
+	if err != nil || user.FullName != "Ada Lovelace" || user.Verified || user.Role != RoleUser || user.Disabled || user.DeletionScheduled {
+		t.Fatalf("new user = %+v, %v", user, err)
+	}
+
+	if err := store.CheckCredentialsOnLogin(ctx, "ada@example.com", "correct horse battery staple"); err != nil {
+		t.Errorf("login with the password: %s", err)
+	}
+	for _, tt := range []struct{ email, password string }{{"ada@example.com", "wrong"}, {"nobody@example.com", "correct horse battery staple"}} {
+		if err := store.CheckCredentialsOnLogin(ctx, tt.email, tt.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("login as %s with %q = %v, want ErrInvalidCredentials", tt.email, tt.password, err)
+		}
+	}
+
+	// empty fields are left alone, a new email has to be verified again
+	if _, err := store.db.ExecContext(ctx, `UPDATE users SET verified_at = $1 WHERE id = $2`, sqliteTime(time.Now()), id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpdateUser(ctx, id, "Ada King", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := store.GetUserByEmail(ctx, "ada@example.com"); user.FullName != "Ada King" || !user.Verified {
+		t.Errorf("after changing the name = %+v", user)
+	}
+	if err := store.CheckCredentialsOnLogin(ctx, "ada@example.com", "correct horse battery staple"); err != nil {
+		t.Errorf("password changed by an update without one: %s", err)
+	}
+	if _, err := store.UpdateUser(ctx, id, "", "ada@example.org", "a brand new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	user, err = store.GetUserByEmail(ctx, "ada@example.org")
+	if err != nil || user.ID != id || user.FullName != "Ada King" || user.Verified {
+		t.Errorf("after changing the email = %+v, %v, want the same unverified user", user, err)
+	}
+	if err := store.CheckCredentialsOnLogin(ctx, "ada@example.org", "a brand new passphrase"); err != nil {
+		t.Errorf("login with the new password: %s", err)
+	}
+
+	profile, err := store.GetProfile(ctx, id)
+	if err != nil || profile.Email != "ada@example.org" || profile.VerifiedAt != nil || profile.CreatedAt.IsZero() || profile.UpdatedAt.Before(profile.CreatedAt) {
+		t.Errorf("profile = %+v, %v", profile, err)
+	}
+	if _, err := store.GetProfile(ctx, id+1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("profile of an unknown user = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestSQLitePasswordResetRevokesAPITokens(t *testing.T) {
+	ctx := t.Context()
+	store := newSQLiteStore(t)
+	id := createSQLiteUser(t, store, "ada@example.com")
+
+	token := "jgr_" + strings.Repeat("a", 40)
+	if _, err := store.CreateAPIToken(ctx, id, "ci", token[:10], jaegerjwt.HashToken(token), []string{"notes:read"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := store.AuthenticateAPIToken(ctx, jaegerjwt.HashToken(token)); err != nil {
+		t.Fatalf("new token rejected: %s", err)
+	}
+
+	if err := store.CreatePasswordResetToken(ctx, id, jaegerjwt.HashToken("reset"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if userId, err := store.ResetPasswordWithToken(ctx, jaegerjwt.HashToken("reset"), "a brand new passphrase"); err != nil || userId != id {
+		t.Fatalf("reset = %d, %v", userId, err)
+	}
+	if _, _, _, err := store.AuthenticateAPIToken(ctx, jaegerjwt.HashToken(token)); err == nil {
+		t.Errorf("API token still works after the password reset")
+	}
+	if tokens, _ := store.GetAPITokens(ctx, id); len(tokens) != 0 {
+		t.Errorf("revoked tokens still listed: %+v", tokens)
+	}
+}
+
+func TestSQLiteNotes(t *testing.T) {
+	ctx := t.Context()
+	store := newSQLiteStore(t)
+	ada := createSQLiteUser(t, store, "ada@example.com")
+	bob := createSQLiteUser(t, store, "bob@example.com")
+
+	id, err := store.CreateNote(ctx, "uuid-1", "Acme", "Engineer", "100k", "", "2026-09-01", "first", ada)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateNote(ctx, "uuid-2", "Acme", "Engineer", "", "hired", "2026-09-01", "", ada); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("note with an unknown status = %v, want ErrInvalidStatus", err)
+	}
+	if _, err := store.CreateNote(ctx, "uuid-2", "Acme", "Engineer", "", "", "first of september", "", ada); err == nil {
+		t.Errorf("note with an unreadable date created")
+	}
+
+	note, err := store.GetUpdatedNote(ctx, ada, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NoteDB{Id: id, Uuid: "uuid-1", CompanyName: "Acme", Position: "Engineer", Salary: "100k", ApplicationStatus: StatusApplied,
+		AppliedOn: "2026-09-01 00:00:00", UserId: strconv.Itoa(ada), UpdatedAt: note.UpdatedAt, Description: "first"}
+	if !reflect.DeepEqual(note, want) {
+		t.Errorf("created note = %+v, want %+v", note, want)
+	}
+	if _, err := store.GetUpdatedNote(ctx, bob, id); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("bob reading ada's note = %v, want ErrNoteNotFound", err)
+	}
+
+	if err := store.DeleteNote(ctx, bob, id); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("bob deleting ada's note = %v, want ErrNoteNotFound", err)
+	}
+	if err := store.DeleteNote(ctx, ada, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteNote(ctx, ada, id); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("deleting the note twice = %v, want ErrNoteNotFound", err)
+	}
+	if notes, _ := store.GetAllUserNotes(ctx, ada, NoteFilter{}); len(notes) != 0 {
+		t.Errorf("deleted note still listed: %+v", notes)
+	}
+}
+
+func TestSQLiteUpdateNote(t *testing.T) {
+	ctx := t.Context()
+	store := newSQLiteStore(t)
+	ada := createSQLiteUser(t, store, "ada@example.com")
+	bob := createSQLiteUser(t, store, "bob@example.com")
+	id, err := store.CreateNote(ctx, "uuid-1", "Acme", "Engineer", "100k", "", "2026-09-01", "first", ada)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an old updated_at shows whether the update wrote the row
+	if _, err := store.db.ExecContext(ctx, `UPDATE notes SET updated_at = '2026-01-01 00:00:00' WHERE id = $1`, id); err != nil {
+		t.Fatal(err)
+	}
+	created, _ := store.GetUpdatedNote(ctx, ada, id)
+
+	// the frontend sends the whole note, only the columns that differ are written
+	if err := store.UpdateNote(ctx, ada, id, "Acme", "Staff Engineer", "100k", StatusApplied, "2026-09-01", "first"); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := store.GetUpdatedNote(ctx, ada, id)
+	want := created
+	want.Position = "Staff Engineer"
+	want.AppliedOn, want.UpdatedAt = updated.AppliedOn, updated.UpdatedAt
+	if !reflect.DeepEqual(updated, want) || !strings.HasPrefix(updated.AppliedOn, "2026-09-01") || updated.UpdatedAt == created.UpdatedAt {
+		t.Errorf("after changing the position = %+v, want %+v with a new updated at", updated, want)
+	}
+
+	// a changed status is written and goes into the history, an impossible one changes nothing
+	if err := store.UpdateNote(ctx, ada, id, "Globex", "Staff Engineer", "120k", StatusInterview, "2026-09-02", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateNote(ctx, ada, id, "Initech", "Staff Engineer", "120k", StatusApplied, "2026-09-02", "second"); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("moving back to applied = %v, want ErrInvalidStatusTransition", err)
+	}
+	updated, _ = store.GetUpdatedNote(ctx, ada, id)
+	if updated.CompanyName != "Globex" || updated.Salary != "120k" || updated.ApplicationStatus != StatusInterview || updated.Description != "second" || !strings.HasPrefix(updated.AppliedOn, "2026-09-02") {
+		t.Errorf("after the second update = %+v", updated)
+	}
+	history, err := store.GetNoteStatusHistory(ctx, ada, id)
+	if err != nil || len(history) != 2 || history[1].ToStatus != StatusInterview || history[1].FromStatus == nil || *history[1].FromStatus != StatusApplied {
+		t.Errorf("status history = %+v, %v", history, err)
+	}
+
+	// the new company name is linked to a company of its own
+	var companyName string
+	if err := store.db.QueryRowContext(ctx, `SELECT companies.name FROM notes JOIN companies ON companies.id = notes.fk_company_id WHERE notes.id = $1`, id).Scan(&companyName); err != nil || companyName != "Globex" {
+		t.Errorf("note's company = %q, %v, want Globex", companyName, err)
+	}
+
+	if err := store.UpdateNote(ctx, bob, id, "Hacked", "", "", "", "2026-09-02", ""); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("bob updating ada's note = %v, want ErrNoteNotFound", err)
+	}
+}
+
+func TestSQLiteGetAllUserNotes(t *testing.T) {
+	ctx := t.Context()
+	store := newSQLiteStore(t)
+	ada := createSQLiteUser(t, store, "ada@example.com")
+	bob := createSQLiteUser(t, store, "bob@example.com")
+
+	// whatever the frontend sends, the date comes back in UTC in the layout Postgres returns
+	dates := []struct{ appliedOn, want string }{
+		{"2026-09-01", "2026-09-01 00:00:00"},
+		{"2026-09-02T09:30", "2026-09-02 09:30:00"},
+		{"2026-09-03T09:30:00+02:00", "2026-09-03 07:30:00"},
+		{"2026-09-04 23:15:00", "2026-09-04 23:15:00"},
+	}
+	ids := map[string]int{}
+	for i, d := range dates {
+		id, err := store.CreateNote(ctx, "uuid-"+strconv.Itoa(i), "Company "+strconv.Itoa(i), "Engineer", "", "", d.appliedOn, "", ada)
+		if err != nil {
+			t.Fatalf("note applied on %s: %s", d.appliedOn, err)
+		}
+		ids[d.appliedOn] = id
+	}
+	if _, err := store.CreateNote(ctx, "uuid-bob", "Globex", "Engineer", "", "", "2026-09-01", "", bob); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := store.GetAllUserNotes(ctx, ada, NoteFilter{})
+	if err != nil || len(notes) != len(dates) {
+		t.Fatalf("ada's notes = %+v, %v, want %d", notes, err, len(dates))
+	}
+	for _, d := range dates {
+		i := slices.IndexFunc(notes, func(n NoteDB) bool { return n.Id == ids[d.appliedOn] })
+		if notes[i].AppliedOn != d.want {
+			t.Errorf("applied on %s reads back as %s, want %s", d.appliedOn, notes[i].AppliedOn, d.want)
+		}
+		if _, err := time.Parse("2006-01-02 15:04:05", notes[i].UpdatedAt); err != nil {
+			t.Errorf("updated at %q: %s", notes[i].UpdatedAt, err)
+		}
+	}
+
+	remote, err := store.CreateTag(ctx, ada, TagDB{Name: "remote", Color: "#00aa00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	referral, err := store.CreateTag(ctx, ada, TagDB{Name: "referral", Color: "#0000aa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := func(appliedOn string, tagId int) {
+		if err := store.TagNote(ctx, ada, ids[appliedOn], tagId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tag("2026-09-01", remote.ID)
+	tag("2026-09-02T09:30", remote.ID)
+	tag("2026-09-02T09:30", referral.ID)
+	tag("2026-09-03T09:30:00+02:00", referral.ID)
+
+	filters := []struct {
+		name   string
+		groups [][]int
+		want   []string
+	}{
+		{"remote", [][]int{{remote.ID}}, []string{"2026-09-01", "2026-09-02T09:30"}},
+		{"remote or referral", [][]int{{remote.ID, referral.ID}}, []string{"2026-09-01", "2026-09-02T09:30", "2026-09-03T09:30:00+02:00"}},
+		{"remote and referral", [][]int{{remote.ID}, {referral.ID}}, []string{"2026-09-02T09:30"}},
+		{"unknown tag", [][]int{{referral.ID + 100}}, nil},
+	}
+	for _, f := range filters {
+		t.Run(f.name, func(t *testing.T) {
+			notes, err := store.GetAllUserNotes(ctx, ada, NoteFilter{TagGroups: f.groups})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want []int
+			for _, n := range notes {
+				got = append(got, n.Id)
+			}
+			for _, appliedOn := range f.want {
+				want = append(want, ids[appliedOn])
+			}
+			slices.Sort(got)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("notes = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// the listing carries every tag of a note, not only the ones filtered on
+	notes, _ = store.GetAllUserNotes(ctx, ada, NoteFilter{TagGroups: [][]int{{referral.ID}}})
+	i := slices.IndexFunc(notes, func(n NoteDB) bool { return n.Id == ids["2026-09-02T09:30"] })
+	if i < 0 || len(notes[i].Tags) != 2 || notes[i].Tags[0].Name != "referral" || notes[i].Tags[1].Name != "remote" {
+		t.Errorf("tags of the note on both = %+v", notes)
+	}
+}
@@ -0,0 +1,124 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func CreateSession(ctx context.Context, pool *pgxpool.Pool, jti string, userId int, userAgent, ip string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := pool.Exec(ctx, `INSERT INTO sessions (jti, fk_user_id, user_agent, ip_address, created_at, last_seen_at, expires_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $5)`, jti, userId, userAgent, ip, expiresAt)
+	if err != nil {
+		log.Printf("Unable to store the session: %s", err)
+		return err
+	}
+	return nil
+}
+
+// IsSessionActive is consulted on every token validation
+func IsSessionActive(ctx context.Context, pool *pgxpool.Pool, jti string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var active bool
+	err := pool.QueryRow(ctx, `SELECT revoked_at IS NULL AND expires_at > $2 FROM sessions WHERE jti = $1`, jti, time.Now()).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+func GetActiveSessions(ctx context.Context, pool *pgxpool.Pool, userId int) ([]SessionDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT jti, user_agent, ip_address, created_at, last_seen_at, expires_at
+	FROM sessions WHERE fk_user_id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY last_seen_at DESC`, userId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []SessionDB
+	for rows.Next() {
+		var session SessionDB
+		if err := rows.Scan(&session.ID, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes a single session of the user along with its refresh tokens
+func RevokeSession(ctx context.Context, pool *pgxpool.Pool, userId int, jti string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	cmdTag, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+	WHERE jti = $1 AND fk_user_id = $2 AND revoked_at IS NULL`, jti, userId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+	WHERE family_id = $1 AND revoked_at IS NULL`, jti); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RevokeAllSessions logs the user out of all devices
+func RevokeAllSessions(ctx context.Context, pool *pgxpool.Pool, userId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := revokeAllSessions(ctx, tx, userId); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func revokeAllSessions(ctx context.Context, tx pgx.Tx, userId int) error {
+	if _, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+	WHERE fk_user_id = $1 AND revoked_at IS NULL`, userId); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+	WHERE fk_user_id = $1 AND revoked_at IS NULL`, userId)
+	return err
+}
@@ -0,0 +1,165 @@
+package jaegerdb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// UserStore is everything the API needs to know about users and their accounts
+type UserStore interface {
+	// users
+	CreateUserJaeger(ctx context.Context, fullName, email, password string) error
+	GetUserByEmail(ctx context.Context, email string) (*RetrievedUser, error)
+	CheckCredentialsOnLogin(ctx context.Context, email, password string) error
+	UpdateUser(ctx context.Context, id int, fullName, email, password string) (UpdatedUserDataDB, error)
+	GetProfile(ctx context.Context, userId int) (ProfileDB, error)
+
+	// sessions and tokens
+	CreateSession(ctx context.Context, jti string, userId int, userAgent, ip string, expiresAt time.Time) error
+	IsSessionActive(ctx context.Context, jti string) (bool, error)
+	GetActiveSessions(ctx context.Context, userId int) ([]SessionDB, error)
+	RevokeSession(ctx context.Context, userId int, jti string) error
+	RevokeAllSessions(ctx context.Context, userId int) error
+	CreateRefreshToken(ctx context.Context, userId int, familyId, tokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (string, string, error)
+	RevokeRefreshTokenFamily(ctx context.Context, tokenHash string) (int, string, error)
+
+	// password reset and email verification
+	CreatePasswordResetToken(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error
+	GetPasswordResetUser(ctx context.Context, tokenHash string) (int, error)
+	ResetPasswordWithToken(ctx context.Context, tokenHash, password string) (int, error)
+	CreateEmailVerificationToken(ctx context.Context, userId int, email, tokenHash string, expiresAt time.Time) error
+	VerifyEmailWithToken(ctx context.Context, tokenHash string) (int, error)
+
+	// two factor authentication
+	GetTOTPState(ctx context.Context, userId int) (TOTPStateDB, error)
+	SetPendingTOTPSecret(ctx context.Context, userId int, secret string) error
+	EnableTOTP(ctx context.Context, userId int, counter int64, recoveryCodeHashes []string) error
+	RegenerateRecoveryCodes(ctx context.Context, userId int, recoveryCodeHashes []string) error
+	UseTOTPCounter(ctx context.Context, userId int, counter int64) error
+	UseRecoveryCode(ctx context.Context, userId int, codeHash string) error
+	DisableTOTP(ctx context.Context, userId int) error
+
+	// external identities
+	GetUserByIdentity(ctx context.Context, provider, subject string) (string, error)
+	LinkIdentity(ctx context.Context, userId int, provider, subject, email string) error
+	CreateExternalUser(ctx context.Context, fullName, email string, verified bool, provider, subject string) error
+	GetUserIdentities(ctx context.Context, userId int) ([]IdentityDB, error)
+	UnlinkIdentity(ctx context.Context, userId, identityId int) error
+
+	// personal access tokens
+	CreateAPIToken(ctx context.Context, userId int, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (APITokenDB, error)
+	GetAPITokens(ctx context.Context, userId int) ([]APITokenDB, error)
+	RevokeAPIToken(ctx context.Context, userId, tokenId int) error
+	AuthenticateAPIToken(ctx context.Context, tokenHash string) (string, int, []string, error)
+
+	// login throttling
+	RecordLoginAttempt(ctx context.Context, email, ip, userAgent string, succeeded bool) error
+	GetLoginFailures(ctx context.Context, email, ip string, since time.Time) (LoginFailuresDB, error)
+	GetRecentLoginFailures(ctx context.Context, userId, limit int) ([]LoginAttemptDB, error)
+	GetLoginHistory(ctx context.Context, userId int) ([]LoginAttemptDB, error)
+
+	// administration and account deletion
+	GetUsersPage(ctx context.Context, limit, offset int) ([]AdminUserDB, int, error)
+	SetUserDisabled(ctx context.Context, userId int, disabled bool) error
+	RequirePasswordReset(ctx context.Context, userId int) (string, error)
+	ScheduleUserDeletion(ctx context.Context, userId int) (time.Time, error)
+	CancelUserDeletion(ctx context.Context, userId int) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+
+	// audit log
+	RecordAuditEvent(ctx context.Context, userId, actorId int, action, target, details, ip, userAgent string) error
+	GetAuditEvents(ctx context.Context, userId, limit, offset int) ([]AuditEventDB, int, error)
+}
+
+// NoteStore keeps the job application notes, every note belongs to a user
+type NoteStore interface {
+	CreateNote(ctx context.Context, uuid, companyName, position, salary, applicationStatus, appliedOn, description string, userId int) (int, error)
+	GetAllUserNotes(ctx context.Context, id int, filter NoteFilter) ([]NoteDB, error)
+	UpdateNote(ctx context.Context, userId, id int, company, pos, sal, appStat, appOn, desc string) error
+	GetUpdatedNote(ctx context.Context, userId, id int) (NoteDB, error)
+	DeleteNote(ctx context.Context, userId, id int) error
+
+	// status timeline
+	GetNoteStatusHistory(ctx context.Context, userId, noteId int) ([]NoteStatusChangeDB, error)
+	GetStatusHistory(ctx context.Context, userId int) ([]NoteStatusChangeDB, error)
+
+	// interviews
+	CreateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error)
+	GetNoteInterviews(ctx context.Context, userId, noteId int) ([]InterviewDB, error)
+	GetInterview(ctx context.Context, userId, noteId, interviewId int) (InterviewDB, error)
+	UpdateInterview(ctx context.Context, userId, noteId int, interview InterviewDB) (InterviewDB, error)
+	DeleteInterview(ctx context.Context, userId, noteId, interviewId int) error
+	GetUpcomingInterviews(ctx context.Context, userId int, from, until time.Time) ([]UpcomingInterviewDB, error)
+	GetUserInterviews(ctx context.Context, userId int) ([]InterviewDB, error)
+
+	// contacts
+	CreateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error)
+	GetContacts(ctx context.Context, userId int) ([]ContactDB, error)
+	GetContact(ctx context.Context, userId, contactId int) (ContactDB, error)
+	UpdateContact(ctx context.Context, userId int, contact ContactDB) (ContactDB, error)
+	DeleteContact(ctx context.Context, userId, contactId int) error
+	LinkContact(ctx context.Context, userId, noteId, contactId int) error
+	UnlinkContact(ctx context.Context, userId, noteId, contactId int) error
+	GetNoteContacts(ctx context.Context, userId, noteId int) ([]ContactDB, error)
+	GetContactNotes(ctx context.Context, userId, contactId int) ([]NoteDB, error)
+
+	// companies, notes are linked to them by company name when they're created or updated
+	CreateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error)
+	GetCompanies(ctx context.Context, userId int) ([]CompanyDB, error)
+	GetCompany(ctx context.Context, userId, companyId int) (CompanyDetailDB, error)
+	UpdateCompany(ctx context.Context, userId int, company CompanyDB) (CompanyDB, error)
+	DeleteCompany(ctx context.Context, userId, companyId int) error
+	AddCompanyAlias(ctx context.Context, userId, companyId int, alias string) (CompanyDB, error)
+	DeleteCompanyAlias(ctx context.Context, userId, companyId, aliasId int) error
+
+	// tags
+	CreateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error)
+	GetTags(ctx context.Context, userId int) ([]TagDB, error)
+	UpdateTag(ctx context.Context, userId int, tag TagDB) (TagDB, error)
+	DeleteTag(ctx context.Context, userId, tagId int) error
+	TagNote(ctx context.Context, userId, noteId, tagId int) error
+	UntagNote(ctx context.Context, userId, noteId, tagId int) error
+	GetNoteTags(ctx context.Context, userId, noteId int) ([]NoteTagDB, error)
+}
+
+// Store is the whole persistence layer, users and notes live in the same place so deleting a user can take the notes along
+type Store interface {
+	UserStore
+	NoteStore
+	Close()
+}
+
+// OpenStore connects the store DB_STORE selects, postgres unless it's set to sqlite or memory
+func OpenStore() (Store, error) {
+	loadEnv()
+
+	switch backend := os.Getenv("DB_STORE"); backend {
+	case "", "postgres":
+		return NewPostgresStore(ConnectJaegerDB()), nil
+	case "sqlite":
+		store, err := ConnectSQLite()
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "memory":
+		log.Printf("Using the in-memory store, nothing is kept after a restart")
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown DB_STORE %q", backend)
+	}
+}
+
+// parseAppliedOn reads the applied on date the frontend sends, Postgres does this itself when casting to TIMESTAMP
+func parseAppliedOn(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid applied on date %q", value)
+}
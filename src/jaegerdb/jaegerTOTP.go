@@ -0,0 +1,165 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTOTPAlreadyEnabled  = errors.New("two factor authentication is already enabled")
+	ErrTOTPCodeReused      = errors.New("two factor code was already used")
+	ErrRecoveryCodeInvalid = errors.New("recovery code is invalid or already used")
+	ErrTOTPNotSetUp        = errors.New("two factor authentication hasn't been set up")
+)
+
+func GetTOTPState(ctx context.Context, pool *pgxpool.Pool, userId int) (TOTPStateDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var state TOTPStateDB
+	var secret *string
+	var lastCounter *int64
+	err := pool.QueryRow(ctx, `SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_counter,
+	(SELECT COUNT(*) FROM totp_recovery_codes WHERE fk_user_id = users.id AND used_at IS NULL)
+	FROM users WHERE id = $1`, userId).Scan(&secret, &state.Enabled, &lastCounter, &state.RecoveryCodesLeft)
+	if err != nil {
+		return TOTPStateDB{}, err
+	}
+	if secret != nil {
+		state.Secret = *secret
+	}
+	if lastCounter != nil {
+		state.LastCounter = *lastCounter
+	}
+	return state, nil
+}
+
+// SetPendingTOTPSecret stores the secret during setup, it's only used for login after EnableTOTP
+func SetPendingTOTPSecret(ctx context.Context, pool *pgxpool.Pool, userId int, secret string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cmdTag, err := pool.Exec(ctx, `UPDATE users SET totp_secret = $1, totp_last_counter = NULL
+	WHERE id = $2 AND totp_enabled_at IS NULL`, secret, userId)
+	if err != nil {
+		log.Printf("Unable to store the TOTP secret: %s", err)
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTOTP turns on two factor authentication and replaces the recovery codes
+func EnableTOTP(ctx context.Context, pool *pgxpool.Pool, userId int, counter int64, recoveryCodeHashes []string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	cmdTag, err := tx.Exec(ctx, `UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_counter = $1
+	WHERE id = $2 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`, counter, userId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func RegenerateRecoveryCodes(ctx context.Context, pool *pgxpool.Pool, userId int, recoveryCodeHashes []string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userId int, recoveryCodeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM totp_recovery_codes WHERE fk_user_id = $1`, userId); err != nil {
+		return err
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(ctx, `INSERT INTO totp_recovery_codes (fk_user_id, code_hash, created_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)`, userId, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseTOTPCounter records the time step of an accepted code so the same code can't be replayed
+func UseTOTPCounter(ctx context.Context, pool *pgxpool.Pool, userId int, counter int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cmdTag, err := pool.Exec(ctx, `UPDATE users SET totp_last_counter = $1
+	WHERE id = $2 AND (totp_last_counter IS NULL OR totp_last_counter < $1)`, counter, userId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrTOTPCodeReused
+	}
+	return nil
+}
+
+func UseRecoveryCode(ctx context.Context, pool *pgxpool.Pool, userId int, codeHash string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cmdTag, err := pool.Exec(ctx, `UPDATE totp_recovery_codes SET used_at = CURRENT_TIMESTAMP
+	WHERE fk_user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userId, codeHash)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+	return nil
+}
+
+func DisableTOTP(ctx context.Context, pool *pgxpool.Pool, userId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Unable to start DB transaction: %s", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL
+	WHERE id = $1`, userId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM totp_recovery_codes WHERE fk_user_id = $1`, userId); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
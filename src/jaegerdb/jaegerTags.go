@@ -0,0 +1,250 @@
+package jaegerdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found") // also returned for tags of other users
+	ErrTagExists   = errors.New("a tag with this name already exists")
+)
+
+const DefaultTagColor = "#9e9e9e"
+
+// NoteFilter narrows down the notes listing, a note has to match every group and matches a group when it has any of its tags
+// so {{1, 2}, {3}} is (1 OR 2) AND 3, the zero value lists all notes
+type NoteFilter struct {
+	TagGroups [][]int
+}
+
+// where returns the conditions for the filter on the notes table, the placeholders continue after args
+// the EXISTS subqueries use the note_tags primary key, the fk_tag_id index serves the planner going from the tags instead
+func (f NoteFilter) where(args []any) (string, []any) {
+	var sb strings.Builder
+	for _, group := range f.TagGroups {
+		if len(group) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(group))
+		for i, tagId := range group {
+			args = append(args, tagId)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		sb.WriteString(` AND EXISTS (SELECT 1 FROM note_tags WHERE note_tags.fk_note_id = notes.id AND note_tags.fk_tag_id IN (` + strings.Join(placeholders, ", ") + `))`)
+	}
+	return sb.String(), args
+}
+
+// matches is the filter for notes that aren't in a database, tags are the ids of the note's tags
+func (f NoteFilter) matches(tags []int) bool {
+	for _, group := range f.TagGroups {
+		if len(group) > 0 && !slices.ContainsFunc(group, func(tagId int) bool { return slices.Contains(tags, tagId) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// userNotesQuery lists the user's notes matching the filter
+func userNotesQuery(userId int, filter NoteFilter) (string, []any) {
+	where, args := filter.where([]any{userId})
+	return `SELECT id, note_id, company_name, "position", salary, application_status, applied_on, fk_user_id, updated_at, description
+	FROM notes WHERE fk_user_id = $1` + where, args
+}
+
+// noteTagsQuery returns the tags of all of the user's notes as note id, tag id, name and color
+const noteTagsQuery = `SELECT note_tags.fk_note_id, tags.id, tags.name, tags.color FROM note_tags
+	JOIN tags ON tags.id = note_tags.fk_tag_id WHERE tags.fk_user_id = $1 ORDER BY LOWER(tags.name), tags.id`
+
+// withTags fills in the tags of the notes, links maps note ids to their tags
+func withTags(notes []NoteDB, links map[int][]NoteTagDB) {
+	for i := range notes {
+		notes[i].Tags = links[notes[i].Id]
+	}
+}
+
+const tagColumns = `tags.id, tags.name, tags.color, tags.created_at, tags.updated_at`
+
+func scanTag(row pgx.Row, extra ...any) (TagDB, error) {
+	var tag TagDB
+	err := row.Scan(append([]any{&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt}, extra...)...)
+	return tag, err
+}
+
+func CreateTag(ctx context.Context, pool *pgxpool.Pool, userId int, tag TagDB) (TagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	created, err := scanTag(pool.QueryRow(ctx, `INSERT INTO tags (fk_user_id, name, color, created_at, updated_at)
+	VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	ON CONFLICT DO NOTHING RETURNING `+tagColumns, userId, tag.Name, tag.Color))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return TagDB{}, ErrTagExists
+	}
+	if err != nil {
+		log.Printf("Unable to store the tag: %s", err)
+		return TagDB{}, err
+	}
+	return created, nil
+}
+
+// GetTags lists the user's tags by name with the number of notes they're on
+func GetTags(ctx context.Context, pool *pgxpool.Pool, userId int) ([]TagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `SELECT `+tagColumns+`, (SELECT COUNT(*) FROM note_tags WHERE note_tags.fk_tag_id = tags.id)
+	FROM tags WHERE fk_user_id = $1 ORDER BY LOWER(name), id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []TagDB
+	for rows.Next() {
+		var noteCount int
+		tag, err := scanTag(rows, &noteCount)
+		if err != nil {
+			return nil, err
+		}
+		tag.NoteCount = noteCount
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// UpdateTag renames or recolors the tag, names are unique per user regardless of case
+func UpdateTag(ctx context.Context, pool *pgxpool.Pool, userId int, tag TagDB) (TagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var taken bool
+	err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM tags WHERE fk_user_id = $1 AND LOWER(name) = LOWER($2) AND id <> $3)`,
+		userId, tag.Name, tag.ID).Scan(&taken)
+	if err != nil {
+		return TagDB{}, err
+	}
+	if taken {
+		return TagDB{}, ErrTagExists
+	}
+
+	var noteCount int
+	updated, err := scanTag(pool.QueryRow(ctx, `UPDATE tags SET name = $3, color = $4, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND fk_user_id = $2
+	RETURNING `+tagColumns+`, (SELECT COUNT(*) FROM note_tags WHERE note_tags.fk_tag_id = tags.id)`, tag.ID, userId, tag.Name, tag.Color), &noteCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return TagDB{}, ErrTagNotFound
+	}
+	if err != nil {
+		log.Printf("Unable to update tag %d: %s", tag.ID, err)
+		return TagDB{}, err
+	}
+	updated.NoteCount = noteCount
+	return updated, nil
+}
+
+// DeleteTag removes the tag from the user's notes and deletes it
+func DeleteTag(ctx context.Context, pool *pgxpool.Pool, userId, tagId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, `DELETE FROM tags WHERE id = $1 AND fk_user_id = $2`, tagId, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// TagNote puts one of the user's tags on one of their notes, tagging a note twice changes nothing
+func TagNote(ctx context.Context, pool *pgxpool.Pool, userId, noteId, tagId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var noteOwned, tagOwned bool
+	err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $3),
+	EXISTS (SELECT 1 FROM tags WHERE id = $2 AND fk_user_id = $3)`, noteId, tagId, userId).Scan(&noteOwned, &tagOwned)
+	if err != nil {
+		return err
+	}
+	if !noteOwned {
+		return ErrNoteNotFound
+	}
+	if !tagOwned {
+		return ErrTagNotFound
+	}
+
+	_, err = pool.Exec(ctx, `INSERT INTO note_tags (fk_note_id, fk_tag_id, created_at) VALUES ($1, $2, CURRENT_TIMESTAMP)
+	ON CONFLICT (fk_note_id, fk_tag_id) DO NOTHING`, noteId, tagId)
+	if err != nil {
+		log.Printf("Unable to tag note %d with tag %d: %s", noteId, tagId, err)
+		return err
+	}
+	return nil
+}
+
+func UntagNote(ctx context.Context, pool *pgxpool.Pool, userId, noteId, tagId int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := pool.Exec(ctx, `DELETE FROM note_tags USING tags
+	WHERE note_tags.fk_note_id = $1 AND note_tags.fk_tag_id = $2
+	AND tags.id = note_tags.fk_tag_id AND tags.fk_user_id = $3`, noteId, tagId, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// GetNoteTags lists the tags on the note by name
+func GetNoteTags(ctx context.Context, pool *pgxpool.Pool, userId, noteId int) ([]NoteTagDB, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND fk_user_id = $2)`, noteId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := pool.Query(ctx, `SELECT tags.id, tags.name, tags.color FROM tags
+	JOIN note_tags ON note_tags.fk_tag_id = tags.id
+	WHERE note_tags.fk_note_id = $1 AND tags.fk_user_id = $2 ORDER BY LOWER(tags.name), tags.id`, noteId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []NoteTagDB
+	for rows.Next() {
+		var tag NoteTagDB
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
@@ -0,0 +1,75 @@
+package jaegerdb
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNoteFilterMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups [][]int
+		tags   []int
+		want   bool
+	}{
+		{"no filter", nil, nil, true},
+		{"no filter with tags", nil, []int{1}, true},
+		{"one tag", [][]int{{1}}, []int{1, 2}, true},
+		{"one tag missing", [][]int{{1}}, []int{2}, false},
+		{"untagged note", [][]int{{1}}, nil, false},
+		{"or matches any", [][]int{{1, 2}}, []int{2}, true},
+		{"or matches none", [][]int{{1, 2}}, []int{3}, false},
+		{"and needs all", [][]int{{1}, {2}}, []int{1}, false},
+		{"and has all", [][]int{{1}, {2}}, []int{2, 1}, true},
+		{"(1 or 2) and 3", [][]int{{1, 2}, {3}}, []int{2, 3}, true},
+		{"(1 or 2) and 3 without 3", [][]int{{1, 2}, {3}}, []int{1, 2}, false},
+		{"empty groups are skipped", [][]int{{}, {1}, {}}, []int{1}, true},
+		{"only empty groups", [][]int{{}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (NoteFilter{TagGroups: tt.groups}).matches(tt.tags); got != tt.want {
+				t.Errorf("%v matches %v = %t, want %t", tt.groups, tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoteFilterWhere(t *testing.T) {
+	const exists = ` AND EXISTS (SELECT 1 FROM note_tags WHERE note_tags.fk_note_id = notes.id AND note_tags.fk_tag_id IN (`
+
+	tests := []struct {
+		name      string
+		groups    [][]int
+		wantWhere string
+		wantArgs  []any
+	}{
+		{"no filter", nil, "", []any{7}},
+		{"one group", [][]int{{3, 4}}, exists + "$2, $3))", []any{7, 3, 4}},
+		{"two groups", [][]int{{3, 4}, {5}}, exists + "$2, $3))" + exists + "$4))", []any{7, 3, 4, 5}},
+		{"empty groups are skipped", [][]int{{}, {5}}, exists + "$2))", []any{7, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the placeholders continue after the arguments already in the query
+			where, args := NoteFilter{TagGroups: tt.groups}.where([]any{7})
+			if where != tt.wantWhere {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+
+	query, args := userNotesQuery(7, NoteFilter{TagGroups: [][]int{{3}}})
+	if want := "WHERE fk_user_id = $1" + exists + "$2))"; !strings.HasSuffix(query, want) {
+		t.Errorf("userNotesQuery = %q, want it to end in %q", query, want)
+	}
+	if !slices.Equal(args, []any{7, 3}) {
+		t.Errorf("userNotesQuery args = %v, want [7 3]", args)
+	}
+}
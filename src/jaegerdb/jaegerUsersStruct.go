@@ -11,10 +11,21 @@ type UsersDB struct {
 	updatedAt time.Time
 }
 
+// roles a user can have
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type RetrievedUser struct {
-	ID       int
-	FullName string
-	Email    string
+	ID                    int
+	FullName              string
+	Email                 string
+	Verified              bool
+	Role                  string
+	Disabled              bool
+	PasswordResetRequired bool
+	DeletionScheduled     bool // the account is purged once the grace period is over unless the user logs in again
 }
 
 type LoginData struct {
@@ -31,16 +42,17 @@ type UpdatedUserDataDB struct {
 
 // structs for notes
 type NoteDB struct {
-	Id                int    `json:"id"`
-	Uuid              string `json:"uuid"`
-	CompanyName       string `json:"companyName"`
-	Position          string `json:"position"`
-	Salary            string `json:"salary"`
-	ApplicationStatus string `json:"applicationStatus"`
-	AppliedOn         string `json:"appliedOn"`
-	UserId            string `json:"userId"`
-	UpdatedAt         string `json:"updatedAt"`
-	Description       string `json:"description"`
+	Id                int         `json:"id"`
+	Uuid              string      `json:"uuid"`
+	CompanyName       string      `json:"companyName"`
+	Position          string      `json:"position"`
+	Salary            string      `json:"salary"`
+	ApplicationStatus string      `json:"applicationStatus"`
+	AppliedOn         string      `json:"appliedOn"`
+	UserId            string      `json:"userId"`
+	UpdatedAt         string      `json:"updatedAt"`
+	Description       string      `json:"description"`
+	Tags              []NoteTagDB `json:"tags,omitempty"` // only filled in by the notes listing
 }
 
 type CheckNoteForUpdate struct {
@@ -51,3 +63,197 @@ type CheckNoteForUpdate struct {
 	appliedOn   time.Time
 	description string
 }
+
+// NoteStatusChangeDB is one entry of a note's timeline
+type NoteStatusChangeDB struct {
+	ID         int       `json:"id"`
+	NoteID     int       `json:"noteId"`
+	FromStatus *string   `json:"fromStatus"` // nil for the status the note was created with
+	ToStatus   string    `json:"toStatus"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
+
+// structs for interviews
+type InterviewDB struct {
+	ID           int        `json:"id"`
+	NoteID       int        `json:"noteId"`
+	Round        string     `json:"round"`
+	Type         string     `json:"type"`
+	StartsAt     time.Time  `json:"startsAt"`
+	EndsAt       *time.Time `json:"endsAt"`
+	TimeZone     string     `json:"timeZone"` // IANA name, the times are shown in it
+	Location     string     `json:"location"`
+	VideoLink    string     `json:"videoLink"`
+	Interviewers []string   `json:"interviewers"`
+	Outcome      string     `json:"outcome"`
+	PrepNotes    string     `json:"prepNotes"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// UpcomingInterviewDB is an interview together with the application it belongs to
+type UpcomingInterviewDB struct {
+	InterviewDB
+	CompanyName       string `json:"companyName"`
+	Position          string `json:"position"`
+	ApplicationStatus string `json:"applicationStatus"`
+}
+
+// structs for contacts
+type ContactDB struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Role        string    `json:"role"` // recruiter, hiring manager, referral...
+	Email       string    `json:"email"`
+	Phone       string    `json:"phone"`
+	LinkedInURL string    `json:"linkedinUrl"`
+	Notes       string    `json:"notes"`
+	NoteIDs     []int     `json:"noteIds,omitempty"` // the applications the contact is linked to
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// structs for companies
+type CompanyDB struct {
+	ID               int              `json:"id"`
+	Name             string           `json:"name"`
+	Website          string           `json:"website"`
+	Industry         string           `json:"industry"`
+	Size             string           `json:"size"` // one of CompanySizes or empty
+	Location         string           `json:"location"`
+	Aliases          []CompanyAliasDB `json:"aliases"` // other names notes can use for the company
+	ApplicationCount int              `json:"applicationCount"`
+	CreatedAt        time.Time        `json:"createdAt"`
+	UpdatedAt        time.Time        `json:"updatedAt"`
+}
+
+type CompanyAliasDB struct {
+	ID    int    `json:"id"`
+	Alias string `json:"alias"`
+}
+
+// CompanyDetailDB is a company with everything the user applied for there
+type CompanyDetailDB struct {
+	CompanyDB
+	Stats        CompanyStatsDB `json:"stats"`
+	Applications []NoteDB       `json:"applications"`
+}
+
+type CompanyStatsDB struct {
+	Total          int            `json:"total"`
+	Active         int            `json:"active"` // statuses that can still change
+	ByStatus       map[string]int `json:"byStatus"`
+	Offers         int            `json:"offers"` // applications that got to an offer, accepted or not
+	Interviews     int            `json:"interviews"`
+	FirstAppliedOn string         `json:"firstAppliedOn,omitempty"`
+	LastAppliedOn  string         `json:"lastAppliedOn,omitempty"`
+}
+
+// structs for tags
+type TagDB struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"` // #rrggbb
+	NoteCount int       `json:"noteCount"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NoteTagDB is a tag as it's shown on a note
+type NoteTagDB struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// structs for sessions
+type SessionDB struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// structs for two factor authentication
+type TOTPStateDB struct {
+	Secret            string
+	Enabled           bool
+	LastCounter       int64
+	RecoveryCodesLeft int
+}
+
+// structs for external identities
+type IdentityDB struct {
+	ID          int       `json:"id"`
+	Provider    string    `json:"provider"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastLoginAt time.Time `json:"lastLoginAt"`
+}
+
+// structs for personal access tokens
+type APITokenDB struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+// structs for login throttling
+type LoginAttemptDB struct {
+	ID          int       `json:"id"`
+	IPAddress   string    `json:"ipAddress"`
+	UserAgent   string    `json:"userAgent"`
+	Succeeded   bool      `json:"succeeded"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// LoginFailuresDB is what the throttling is decided on
+type LoginFailuresDB struct {
+	AccountFailures    int // failures for the email since its last successful login
+	AccountLastFailure *time.Time
+	IPFailures         int // failures from the ip regardless of the email
+	IPLastFailure      *time.Time
+}
+
+// structs for the admin api
+type AdminUserDB struct {
+	ID                    int        `json:"id"`
+	FullName              string     `json:"fullName"`
+	Email                 string     `json:"email"`
+	Role                  string     `json:"role"`
+	Verified              bool       `json:"verified"`
+	DisabledAt            *time.Time `json:"disabledAt"`
+	PasswordResetRequired bool       `json:"passwordResetRequired"`
+	CreatedAt             time.Time  `json:"createdAt"`
+}
+
+// structs for the data export
+type ProfileDB struct {
+	ID         int        `json:"id"`
+	FullName   string     `json:"fullName"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	VerifiedAt *time.Time `json:"verifiedAt"`
+	DeletedAt  *time.Time `json:"deletedAt"`
+}
+
+// structs for the audit log
+type AuditEventDB struct {
+	ID        int       `json:"id"`
+	ActorID   *int      `json:"actorId"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Details   string    `json:"details"`
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,179 @@
+package jaegerdb
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrations are named NNNN_name.up.sql and NNNN_name.down.sql and applied in version order,
+// every dialect has its own directory with the same versions
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const (
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+type MigrationStatusDB struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil for pending migrations
+}
+
+// Migrator is implemented by the stores that keep their schema in migrations
+type Migrator interface {
+	MigrateUp(ctx context.Context) ([]Migration, error)
+	MigrateDown(ctx context.Context, steps int) ([]Migration, error)
+	MigrationStatus(ctx context.Context) ([]MigrationStatusDB, error)
+}
+
+// migrationTarget is the database a dialect runs its migrations against
+type migrationTarget interface {
+	dialect() string
+	exec(ctx context.Context, sql string) error
+	appliedVersions(ctx context.Context) (map[int]time.Time, error)
+	// apply executes the migration sql and records the change to schema_migrations in one transaction
+	apply(ctx context.Context, sql, record string, args ...any) error
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := "migrations/" + dialect
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s/%s", dialect, entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := migrationFiles.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// prepareMigrations loads the dialect's migrations and makes sure schema_migrations exists
+func prepareMigrations(ctx context.Context, target migrationTarget) ([]Migration, map[int]time.Time, error) {
+	migrations, err := loadMigrations(target.dialect())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := target.exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := target.appliedVersions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrations, applied, nil
+}
+
+func migrateUp(ctx context.Context, target migrationTarget) ([]Migration, error) {
+	migrations, applied, err := prepareMigrations(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := target.apply(ctx, m.up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func migrateDown(ctx context.Context, target migrationTarget, steps int) ([]Migration, error) {
+	migrations, applied, err := prepareMigrations(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.down == "" {
+			return done, fmt.Errorf("migration %d_%s can't be reverted, it has no down file", m.Version, m.Name)
+		}
+		if err := target.apply(ctx, m.down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+			return done, fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func migrationStatus(ctx context.Context, target migrationTarget) ([]MigrationStatusDB, error) {
+	migrations, applied, err := prepareMigrations(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var status []MigrationStatusDB
+	for _, m := range migrations {
+		s := MigrationStatusDB{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
@@ -0,0 +1,212 @@
+package jaegerdb
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// openSQLite opens the SQLite database file at path without running the migrations
+func openSQLite(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	t.Setenv("SQLITE_PATH", path)
+	store, err := ConnectSQLite()
+	if err != nil {
+		t.Fatalf("opening %s: %s", path, err)
+	}
+	t.Cleanup(store.Close)
+	return store
+}
+
+// newSQLiteStore returns a store on a new database file with every migration applied
+func newSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store := openSQLite(t, filepath.Join(t.TempDir(), "jaeger.db"))
+	if _, err := store.MigrateUp(t.Context()); err != nil {
+		t.Fatalf("migrating: %s", err)
+	}
+	return store
+}
+
+// sqliteTables lists the tables the migrations created
+func sqliteTables(t *testing.T, store *SQLiteStore) []string {
+	t.Helper()
+	rows, err := store.db.QueryContext(t.Context(), `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence') ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+func versions(migrations []Migration) []int {
+	var v []int
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestLoadMigrations(t *testing.T) {
+	postgres, err := loadMigrations(dialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := loadMigrations(dialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both dialects have to end up with the same schema, so they have the same migrations and all of them can be reverted
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d is %04d_%s on postgres and %04d_%s on sqlite", i, postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+		if postgres[i].Version != i+1 {
+			t.Errorf("migration %04d_%s, want version %d", postgres[i].Version, postgres[i].Name, i+1)
+		}
+		if postgres[i].down == "" || sqlite[i].down == "" {
+			t.Errorf("migration %04d_%s has no down file", postgres[i].Version, postgres[i].Name)
+		}
+	}
+}
+
+func TestSQLiteMigrateRoundTrip(t *testing.T) {
+	ctx := t.Context()
+	store := openSQLite(t, filepath.Join(t.TempDir(), "jaeger.db"))
+	all, err := loadMigrations(dialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := store.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(versions(applied), versions(all)) {
+		t.Fatalf("applied %v, want %v", versions(applied), versions(all))
+	}
+	tables := sqliteTables(t, store)
+	if again, err := store.MigrateUp(ctx); err != nil || len(again) != 0 {
+		t.Fatalf("second up applied %v, %v, want nothing", versions(again), err)
+	}
+
+	// the last two are reverted newest first and show up as pending
+	reverted, err := store.MigrateDown(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{len(all), len(all) - 1}; !slices.Equal(versions(reverted), want) {
+		t.Fatalf("down 2 reverted %v, want %v", versions(reverted), want)
+	}
+	status, err := store.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if pending := s.AppliedAt == nil; pending != (s.Version > len(all)-2) {
+			t.Errorf("status of %04d_%s: applied at %v", s.Version, s.Name, s.AppliedAt)
+		}
+	}
+
+	// every down file undoes its up file, nothing is left after reverting all of them and applying them again gives the same schema
+	if reverted, err := store.MigrateDown(ctx, len(all)); err != nil || len(reverted) != len(all)-2 {
+		t.Fatalf("reverting the rest reverted %v, %v", versions(reverted), err)
+	}
+	if left := sqliteTables(t, store); len(left) != 0 {
+		t.Errorf("tables left after reverting everything: %v", left)
+	}
+	if applied, err := store.MigrateUp(ctx); err != nil || len(applied) != len(all) {
+		t.Fatalf("up after down applied %v, %v", versions(applied), err)
+	}
+	if got := sqliteTables(t, store); !slices.Equal(got, tables) {
+		t.Errorf("tables after the round trip = %v, want %v", got, tables)
+	}
+}
+
+func TestSQLiteMigrateConcurrently(t *testing.T) {
+	// two processes starting at the same time on one database file, e.g. two instances behind a load balancer
+	path := filepath.Join(t.TempDir(), "jaeger.db")
+	first, second := openSQLite(t, path), openSQLite(t, path)
+
+	type result struct {
+		applied []Migration
+		err     error
+	}
+	secondDone := make(chan result, 1)
+	var applied []Migration
+	err := first.withMigrationLock(t.Context(), func(target migrationTarget) error {
+		go func() {
+			applied, err := second.MigrateUp(context.Background())
+			secondDone <- result{applied, err}
+		}()
+
+		var err error
+		applied, err = migrateUp(t.Context(), target)
+		// the second one waits for the lock instead of reading the versions halfway through the run
+		select {
+		case r := <-secondDone:
+			t.Errorf("second run finished while the first held the lock: %v, %v", versions(r.applied), r.err)
+		default:
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, _ := loadMigrations(dialectSQLite)
+	if !slices.Equal(versions(applied), versions(all)) {
+		t.Errorf("first run applied %v, want %v", versions(applied), versions(all))
+	}
+	if r := <-secondDone; r.err != nil || len(r.applied) != 0 {
+		t.Errorf("second run applied %v, %v, want nothing", versions(r.applied), r.err)
+	}
+}
+
+func TestSQLiteFailingMigration(t *testing.T) {
+	ctx := t.Context()
+	store := newSQLiteStore(t)
+
+	// a failing migration leaves neither its changes nor a record behind, the ones before it stay applied
+	err := store.withMigrationLock(ctx, func(target migrationTarget) error {
+		if err := target.apply(ctx, `CREATE TABLE kept (id INTEGER)`, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, 1000, "kept"); err != nil {
+			t.Fatal(err)
+		}
+		return target.apply(ctx, `CREATE TABLE half_done (id INTEGER); SELECT missing FROM nowhere`, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, 1001, "broken")
+	})
+	if err == nil {
+		t.Fatalf("broken migration applied")
+	}
+
+	tables := sqliteTables(t, store)
+	if !slices.Contains(tables, "kept") || slices.Contains(tables, "half_done") {
+		t.Errorf("tables after the failed migration = %v", tables)
+	}
+	var recorded []int
+	rows, err := store.db.QueryContext(ctx, `SELECT version FROM schema_migrations WHERE version >= 1000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v int
+		rows.Scan(&v)
+		recorded = append(recorded, v)
+	}
+	if !slices.Equal(recorded, []int{1000}) {
+		t.Errorf("recorded versions %v, want only 1000", recorded)
+	}
+}
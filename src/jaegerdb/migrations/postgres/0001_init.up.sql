@@ -0,0 +1,25 @@
+-- the tables the application started with, the column order of notes is the one the notes queries were written against
+-- IF NOT EXISTS so databases created before migrations existed can be brought under version control
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	full_name TEXT NOT NULL,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notes (
+	id SERIAL PRIMARY KEY,
+	note_id TEXT NOT NULL,
+	company_name TEXT NOT NULL DEFAULT '',
+	"position" TEXT NOT NULL DEFAULT '',
+	salary TEXT NOT NULL DEFAULT '',
+	application_status TEXT NOT NULL DEFAULT '',
+	applied_on TIMESTAMP NOT NULL,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id),
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS notes_user_idx ON notes (fk_user_id);
@@ -0,0 +1,21 @@
+-- accounts created before verification existed are treated as verified,
+-- only when the column is new so running this against a database that already had it doesn't verify anybody
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'verified_at') THEN
+		ALTER TABLE users ADD COLUMN verified_at TIMESTAMPTZ;
+		UPDATE users SET verified_at = created_at;
+	END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+	id SERIAL PRIMARY KEY,
+	token_hash TEXT NOT NULL UNIQUE,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	email TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS email_verification_tokens_user_idx ON email_verification_tokens (fk_user_id);
@@ -0,0 +1,15 @@
+-- every password login attempt, used for throttling brute force attempts per account and per client IP
+-- fk_user_id is empty when the email doesn't belong to a user
+CREATE TABLE IF NOT EXISTS login_attempts (
+	id SERIAL PRIMARY KEY,
+	email TEXT NOT NULL,
+	fk_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	ip_address TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	succeeded BOOLEAN NOT NULL,
+	attempted_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_email_idx ON login_attempts (email, attempted_at);
+CREATE INDEX IF NOT EXISTS login_attempts_ip_idx ON login_attempts (ip_address, attempted_at);
+CREATE INDEX IF NOT EXISTS login_attempts_user_idx ON login_attempts (fk_user_id, attempted_at);
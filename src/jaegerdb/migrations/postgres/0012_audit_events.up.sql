@@ -0,0 +1,26 @@
+-- security relevant events, rows are only ever inserted
+-- fk_user_id is the account the event belongs to, actor_id who caused it (an admin acting on the account for example)
+CREATE TABLE IF NOT EXISTS audit_events (
+	id SERIAL PRIMARY KEY,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id INTEGER,
+	action TEXT NOT NULL,
+	target TEXT NOT NULL DEFAULT '',
+	details TEXT NOT NULL DEFAULT '',
+	ip_address TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_user_idx ON audit_events (fk_user_id, created_at);
+
+-- events can't be changed after the fact, they only go away with the account when it's purged
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,2 @@
+-- the normalized statuses stay normalized
+DROP TABLE IF EXISTS note_status_history;
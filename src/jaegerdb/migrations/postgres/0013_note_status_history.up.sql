@@ -0,0 +1,22 @@
+-- every status a note has been in, the first row of a note is the status it was created with
+CREATE TABLE IF NOT EXISTS note_status_history (
+	id SERIAL PRIMARY KEY,
+	fk_note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	from_status TEXT,
+	to_status TEXT NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS note_status_history_note_idx ON note_status_history (fk_note_id, changed_at);
+
+-- the statuses used to be free text, the ones that only differ in case or spacing from a defined status become that status
+-- anything else is kept as it is and can move to any status on the next update
+UPDATE notes SET application_status = LOWER(TRIM(application_status))
+WHERE LOWER(TRIM(application_status)) IN ('applied', 'screening', 'interview', 'offer', 'accepted', 'declined', 'rejected', 'withdrawn');
+
+UPDATE notes SET application_status = 'applied' WHERE TRIM(application_status) = '';
+
+-- the changes before this migration weren't kept, existing notes start their timeline with the current status
+INSERT INTO note_status_history (fk_note_id, from_status, to_status, changed_at)
+SELECT id, NULL, application_status, updated_at FROM notes
+WHERE NOT EXISTS (SELECT 1 FROM note_status_history WHERE fk_note_id = notes.id);
@@ -0,0 +1,23 @@
+-- interviews of an application, the times are stored in UTC and shown in time_zone (an IANA name)
+-- interviewers are stored one per line
+CREATE TABLE IF NOT EXISTS interviews (
+	id SERIAL PRIMARY KEY,
+	fk_note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	round TEXT NOT NULL,
+	interview_type TEXT NOT NULL CHECK (interview_type IN ('phone', 'video', 'onsite', 'technical', 'other')),
+	starts_at TIMESTAMPTZ NOT NULL,
+	ends_at TIMESTAMPTZ,
+	time_zone TEXT NOT NULL DEFAULT 'UTC',
+	location TEXT NOT NULL DEFAULT '',
+	video_link TEXT NOT NULL DEFAULT '',
+	interviewers TEXT NOT NULL DEFAULT '',
+	outcome TEXT NOT NULL DEFAULT 'pending' CHECK (outcome IN ('pending', 'passed', 'failed', 'cancelled')),
+	prep_notes TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS interviews_note_idx ON interviews (fk_note_id, starts_at);
+-- the upcoming interviews are looked up by time across all notes
+CREATE INDEX IF NOT EXISTS interviews_starts_at_idx ON interviews (starts_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS note_contacts;
+DROP TABLE IF EXISTS contacts;
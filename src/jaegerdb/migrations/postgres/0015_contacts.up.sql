@@ -0,0 +1,25 @@
+-- people met during the job search, a contact can be linked to any number of the user's notes
+CREATE TABLE IF NOT EXISTS contacts (
+	id SERIAL PRIMARY KEY,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	linkedin_url TEXT NOT NULL DEFAULT '',
+	notes TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS contacts_user_idx ON contacts (fk_user_id);
+
+CREATE TABLE IF NOT EXISTS note_contacts (
+	fk_note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	fk_contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (fk_note_id, fk_contact_id)
+);
+
+-- the primary key covers the lookups by note, the notes of a contact need their own index
+CREATE INDEX IF NOT EXISTS note_contacts_contact_idx ON note_contacts (fk_contact_id);
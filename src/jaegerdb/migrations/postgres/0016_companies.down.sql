@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS notes_company_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS fk_company_id;
+DROP TABLE IF EXISTS company_aliases;
+DROP TABLE IF EXISTS companies;
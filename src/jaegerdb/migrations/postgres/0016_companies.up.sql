@@ -0,0 +1,31 @@
+-- companies the user applied to, notes are matched to them by normalized_name (lower case, no punctuation or legal form)
+-- or by one of the aliases, a normalized name belongs to one company of the user only
+CREATE TABLE IF NOT EXISTS companies (
+	id SERIAL PRIMARY KEY,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	normalized_name TEXT NOT NULL,
+	website TEXT NOT NULL DEFAULT '',
+	industry TEXT NOT NULL DEFAULT '',
+	size TEXT NOT NULL DEFAULT '' CHECK (size IN ('', '1-10', '11-50', '51-200', '201-1000', '1001-5000', '5000+')),
+	location TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (fk_user_id, normalized_name)
+);
+
+CREATE TABLE IF NOT EXISTS company_aliases (
+	id SERIAL PRIMARY KEY,
+	fk_company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	alias TEXT NOT NULL,
+	normalized_alias TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (fk_user_id, normalized_alias)
+);
+
+CREATE INDEX IF NOT EXISTS company_aliases_company_idx ON company_aliases (fk_company_id);
+
+-- existing notes get their company the first time the user lists the companies, matching needs the normalization done in Go
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS fk_company_id INTEGER REFERENCES companies(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS notes_company_idx ON notes (fk_company_id);
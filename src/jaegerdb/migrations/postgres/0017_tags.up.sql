@@ -0,0 +1,21 @@
+-- user-defined labels for grouping notes (remote, referral...), names are unique per user regardless of case
+CREATE TABLE IF NOT EXISTS tags (
+	id SERIAL PRIMARY KEY,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	color TEXT NOT NULL DEFAULT '#9e9e9e' CHECK (color ~ '^#[0-9a-f]{6}$'),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tags_user_name_idx ON tags (fk_user_id, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS note_tags (
+	fk_note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	fk_tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (fk_note_id, fk_tag_id)
+);
+
+-- the primary key answers "does this note have the tag" for the listing filters, this one goes from a tag to its notes
+CREATE INDEX IF NOT EXISTS note_tags_tag_idx ON note_tags (fk_tag_id, fk_note_id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS audit_events_no_delete ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_no_delete();
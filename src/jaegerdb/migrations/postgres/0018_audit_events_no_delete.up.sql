@@ -0,0 +1,14 @@
+-- 0012 only kept events from being changed, deleting them has to be refused as well.
+-- the cascade from purging the account still removes them, by then the user row is gone
+CREATE OR REPLACE FUNCTION audit_events_no_delete() RETURNS trigger AS $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM users WHERE id = OLD.fk_user_id) THEN
+		RAISE EXCEPTION 'audit_events is append-only';
+	END IF;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_delete ON audit_events;
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_no_delete();
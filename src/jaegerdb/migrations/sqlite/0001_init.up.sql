@@ -0,0 +1,25 @@
+-- SQLite version of the postgres migrations, the schema has to stay the same on both
+-- timestamps are stored as UTC text the store formats itself so they compare correctly
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	full_name TEXT NOT NULL,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE notes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	note_id TEXT NOT NULL,
+	company_name TEXT NOT NULL DEFAULT '',
+	"position" TEXT NOT NULL DEFAULT '',
+	salary TEXT NOT NULL DEFAULT '',
+	application_status TEXT NOT NULL DEFAULT '',
+	applied_on TIMESTAMP NOT NULL,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id),
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX notes_user_idx ON notes (fk_user_id);
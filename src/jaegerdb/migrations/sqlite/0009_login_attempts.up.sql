@@ -0,0 +1,13 @@
+CREATE TABLE login_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email TEXT NOT NULL,
+	fk_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	ip_address TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	succeeded BOOLEAN NOT NULL,
+	attempted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX login_attempts_email_idx ON login_attempts (email, attempted_at);
+CREATE INDEX login_attempts_ip_idx ON login_attempts (ip_address, attempted_at);
+CREATE INDEX login_attempts_user_idx ON login_attempts (fk_user_id, attempted_at);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN role;
+ALTER TABLE users DROP COLUMN disabled_at;
+ALTER TABLE users DROP COLUMN password_reset_required;
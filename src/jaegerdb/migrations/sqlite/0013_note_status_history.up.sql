@@ -0,0 +1,17 @@
+CREATE TABLE note_status_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	fk_note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	from_status TEXT,
+	to_status TEXT NOT NULL,
+	changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX note_status_history_note_idx ON note_status_history (fk_note_id, changed_at);
+
+UPDATE notes SET application_status = LOWER(TRIM(application_status))
+WHERE LOWER(TRIM(application_status)) IN ('applied', 'screening', 'interview', 'offer', 'accepted', 'declined', 'rejected', 'withdrawn');
+
+UPDATE notes SET application_status = 'applied' WHERE TRIM(application_status) = '';
+
+INSERT INTO note_status_history (fk_note_id, from_status, to_status, changed_at)
+SELECT id, NULL, application_status, updated_at FROM notes;
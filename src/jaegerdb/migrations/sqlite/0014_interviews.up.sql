@@ -0,0 +1,20 @@
+CREATE TABLE interviews (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	fk_note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+	round TEXT NOT NULL,
+	interview_type TEXT NOT NULL CHECK (interview_type IN ('phone', 'video', 'onsite', 'technical', 'other')),
+	starts_at TIMESTAMP NOT NULL,
+	ends_at TIMESTAMP,
+	time_zone TEXT NOT NULL DEFAULT 'UTC',
+	location TEXT NOT NULL DEFAULT '',
+	video_link TEXT NOT NULL DEFAULT '',
+	interviewers TEXT NOT NULL DEFAULT '',
+	outcome TEXT NOT NULL DEFAULT 'pending' CHECK (outcome IN ('pending', 'passed', 'failed', 'cancelled')),
+	prep_notes TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX interviews_note_idx ON interviews (fk_note_id, starts_at);
+CREATE INDEX interviews_starts_at_idx ON interviews (starts_at);
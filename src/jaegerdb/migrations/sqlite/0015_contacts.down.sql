@@ -0,0 +1,2 @@
+DROP TABLE note_contacts;
+DROP TABLE contacts;
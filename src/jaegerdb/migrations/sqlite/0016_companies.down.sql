@@ -0,0 +1,4 @@
+DROP INDEX notes_company_idx;
+ALTER TABLE notes DROP COLUMN fk_company_id;
+DROP TABLE company_aliases;
+DROP TABLE companies;
@@ -0,0 +1,28 @@
+CREATE TABLE companies (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	normalized_name TEXT NOT NULL,
+	website TEXT NOT NULL DEFAULT '',
+	industry TEXT NOT NULL DEFAULT '',
+	size TEXT NOT NULL DEFAULT '' CHECK (size IN ('', '1-10', '11-50', '51-200', '201-1000', '1001-5000', '5000+')),
+	location TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (fk_user_id, normalized_name)
+);
+
+CREATE TABLE company_aliases (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	fk_company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+	fk_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	alias TEXT NOT NULL,
+	normalized_alias TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (fk_user_id, normalized_alias)
+);
+
+CREATE INDEX company_aliases_company_idx ON company_aliases (fk_company_id);
+
+ALTER TABLE notes ADD COLUMN fk_company_id INTEGER REFERENCES companies(id) ON DELETE SET NULL;
+CREATE INDEX notes_company_idx ON notes (fk_company_id);
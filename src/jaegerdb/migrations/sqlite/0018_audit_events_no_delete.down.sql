@@ -0,0 +1 @@
+DROP TRIGGER audit_events_no_delete;
@@ -0,0 +1,5 @@
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+WHEN EXISTS (SELECT 1 FROM users WHERE id = OLD.fk_user_id)
+BEGIN
+	SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -1,39 +1,46 @@
 package jaegerjwt
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"time"
 
-	// "time"
-
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
 )
 
 // NOTE: check the server.log and make sure it's not uploaded anywhere prior to that
 
-func GenerateJWT(email string) (string, error) {
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Printf("Failed loading the environment file: %s", err)
-	}
+const (
+	AccessTokenDuration  = 15 * time.Minute    // lifetime of the authToken JWT
+	RefreshTokenDuration = 30 * 24 * time.Hour // lifetime of a single refresh token
+)
 
-	jwtKey := []byte(os.Getenv("JWT_KEY"))
+// sessionCheck is set by the server so ValidateToken can reject tokens of revoked sessions
+var sessionCheck func(ctx context.Context, jti string) (bool, error)
 
+func SetSessionCheck(check func(ctx context.Context, jti string) (bool, error)) {
+	sessionCheck = check
+}
+
+// jti is the id of the session the token belongs to, the role is informational,
+// access is always decided on the role stored for the user
+func GenerateJWT(email, jti, role string) (string, error) {
 	claims := jwt.MapClaims{
 		"email": email,
-		"exp":   time.Now().Add(time.Hour * 24).Unix(),
+		"jti":   jti,
+		"role":  role,
+		"exp":   time.Now().Add(AccessTokenDuration).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return sign(claims)
 }
 
 func SetTokenInCookies(w http.ResponseWriter, token string) {
-	// duration := time.Now().Add(time.Hour * 24)
-
 	cookie := &http.Cookie{
 		Name:     "authToken",
 		Value:    token,
@@ -41,7 +48,53 @@ func SetTokenInCookies(w http.ResponseWriter, token string) {
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteNoneMode,
-		// Expires:  duration,
+		Expires:  time.Now().Add(AccessTokenDuration),
+		MaxAge:   int(AccessTokenDuration.Seconds()),
+	}
+	http.SetCookie(w, cookie)
+}
+
+// GenerateRefreshToken returns a random opaque refresh token together with the hash that gets stored in the DB
+func GenerateRefreshToken() (string, string, error) {
+	return GenerateOpaqueToken()
+}
+
+// GenerateOpaqueToken returns a random url safe token and its hash, used for refresh, reset and similar tokens
+func GenerateOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes opaque tokens before they are stored, so a DB leak doesn't leak usable tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenID returns a random hex id used for token families
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// the refresh cookie is only sent to /api/users so it doesn't travel with every notes request
+func SetRefreshTokenInCookies(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     "refreshToken",
+		Value:    token,
+		Path:     "/api/users",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		Expires:  time.Now().Add(RefreshTokenDuration),
+		MaxAge:   int(RefreshTokenDuration.Seconds()),
 	}
 	http.SetCookie(w, cookie)
 }
@@ -58,24 +111,169 @@ func DeleteCookie(w http.ResponseWriter) {
 	http.SetCookie(w, cookie)
 }
 
-func ValidateToken(tokenStr string) (*jwt.Token, error) {
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Printf("Failed loading the environment file: %s", err)
+func DeleteRefreshCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     "refreshToken",
+		Path:     "/api/users",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		MaxAge:   -1,
+	}
+	http.SetCookie(w, cookie)
+}
+
+const MFATokenDuration = 5 * time.Minute // time the user has to enter the second factor
+
+// GenerateMFAToken is handed out after the password check when the user has 2FA enabled
+// it can only be exchanged for a session on the second login step, never used as an authToken
+func GenerateMFAToken(email string) (string, error) {
+	claims := jwt.MapClaims{
+		"email":   email,
+		"purpose": "mfa",
+		"exp":     time.Now().Add(MFATokenDuration).Unix(),
 	}
-	jwtKey := []byte(os.Getenv("JWT_KEY"))
-	// Parse the token with the secret key
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// Ensure that the signing method is correct (e.g., HS256)
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		// Return the secret key for verification
-		return jwtKey, nil
-	})
 
+	return sign(claims)
+}
+
+// ValidateMFAToken returns the email the MFA token was issued for
+func ValidateMFAToken(tokenStr string) (string, error) {
+	token, err := parseToken(tokenStr)
+	if err != nil {
+		return "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", fmt.Errorf("token is not valid")
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != "mfa" {
+		return "", fmt.Errorf("not an MFA token")
+	}
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return "", fmt.Errorf("email claim is missing")
+	}
+	return email, nil
+}
+
+const OIDCStateDuration = 10 * time.Minute // time the user has to log in with the identity provider
+
+// OIDCState is kept in a signed cookie between the redirect to the identity provider and the callback
+type OIDCState struct {
+	Provider     string
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+func GenerateOIDCStateToken(oidcState OIDCState) (string, error) {
+	claims := jwt.MapClaims{
+		"purpose":  "oidc",
+		"provider": oidcState.Provider,
+		"state":    oidcState.State,
+		"nonce":    oidcState.Nonce,
+		"verifier": oidcState.CodeVerifier,
+		"exp":      time.Now().Add(OIDCStateDuration).Unix(),
+	}
+
+	return sign(claims)
+}
+
+func ValidateOIDCStateToken(tokenStr string) (OIDCState, error) {
+	token, err := parseToken(tokenStr)
+	if err != nil {
+		return OIDCState{}, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return OIDCState{}, fmt.Errorf("token is not valid")
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != "oidc" {
+		return OIDCState{}, fmt.Errorf("not an OIDC state token")
+	}
+
+	var oidcState OIDCState
+	oidcState.Provider, _ = claims["provider"].(string)
+	oidcState.State, _ = claims["state"].(string)
+	oidcState.Nonce, _ = claims["nonce"].(string)
+	oidcState.CodeVerifier, _ = claims["verifier"].(string)
+	return oidcState, nil
+}
+
+// the state cookie has to survive the cross site redirect back from the provider, so it's Lax instead of None
+func SetOIDCStateCookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     "oidcState",
+		Value:    token,
+		Path:     "/api/users/oidc",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(OIDCStateDuration.Seconds()),
+	}
+	http.SetCookie(w, cookie)
+}
+
+func DeleteOIDCStateCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     "oidcState",
+		Path:     "/api/users/oidc",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	}
+	http.SetCookie(w, cookie)
+}
+
+func ValidateToken(ctx context.Context, tokenStr string) (*jwt.Token, error) {
+	token, err := parseToken(tokenStr)
 	if err != nil {
 		return nil, err
 	}
+
+	// checking the session the token belongs to hasn't been revoked
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("unexpected claims type")
+	}
+	if _, ok := claims["purpose"]; ok {
+		return nil, fmt.Errorf("token can't be used for authentication")
+	}
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, fmt.Errorf("token has no jti claim")
+	}
+	if sessionCheck != nil {
+		active, err := sessionCheck(ctx, jti)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, fmt.Errorf("session %s has been revoked", jti)
+		}
+	}
 	// Return the token if it's valid
 	return token, nil
 }
+
+func parseToken(tokenStr string) (*jwt.Token, error) {
+	r, err := keys()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if r.issuer != "" {
+		options = append(options, jwt.WithIssuer(r.issuer))
+	}
+	// Parse the token with the key it was signed with
+	token, err := jwt.Parse(tokenStr, keyFunc, options...)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
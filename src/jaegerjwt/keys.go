@@ -0,0 +1,426 @@
+package jaegerjwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/joho/godotenv"
+)
+
+// Signing keys are configured through the environment:
+//   JWT_ALG                HS256 (default, uses JWT_KEY), RS256 or EdDSA
+//   JWT_KEYS_DIR           where RS256/EdDSA private keys are kept so they survive restarts, required for them
+//   JWT_ROTATION_INTERVAL  how long a key signs tokens before a new one takes over (default 720h)
+//   JWT_ROTATION_GRACE     how long a replaced key is still accepted and published (default 24h)
+//   JWT_ISSUER             optional iss claim for the tokens
+
+const (
+	defaultRotationInterval = 30 * 24 * time.Hour
+	defaultRotationGrace    = 24 * time.Hour
+	rsaKeyBits              = 2048
+)
+
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   any // []byte for HMAC, crypto.Signer otherwise
+	createdAt time.Time
+	retiredAt time.Time // zero while the key is the active one
+}
+
+func (k *signingKey) public() any {
+	if signer, ok := k.private.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return k.private
+}
+
+// keyRing holds the active signing key and the retired keys still in their grace period
+type keyRing struct {
+	mu       sync.RWMutex
+	alg      string
+	dir      string
+	interval time.Duration
+	grace    time.Duration
+	issuer   string
+	keys     []*signingKey // the active key is always the last one
+}
+
+var (
+	ring     *keyRing
+	ringOnce sync.Once
+	ringErr  error
+)
+
+// Init loads the signing keys, it's called lazily on first use but the server calls it on startup to fail early
+func Init() error {
+	ringOnce.Do(func() {
+		ring, ringErr = loadKeyRing()
+	})
+	return ringErr
+}
+
+func keys() (*keyRing, error) {
+	if err := Init(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+func loadKeyRing() (*keyRing, error) {
+	if err := godotenv.Load("../.env"); err != nil {
+		log.Printf("Failed loading the environment file: %s", err)
+	}
+
+	r := &keyRing{
+		alg:      strings.ToUpper(os.Getenv("JWT_ALG")),
+		dir:      os.Getenv("JWT_KEYS_DIR"),
+		interval: durationFromEnv("JWT_ROTATION_INTERVAL", defaultRotationInterval),
+		grace:    durationFromEnv("JWT_ROTATION_GRACE", defaultRotationGrace),
+		issuer:   os.Getenv("JWT_ISSUER"),
+	}
+	if r.alg == "" {
+		r.alg = "HS256"
+	}
+	if r.alg == "EDDSA" {
+		r.alg = "EdDSA"
+	}
+
+	switch r.alg {
+	case "HS256":
+		secret := os.Getenv("JWT_KEY")
+		if secret == "" {
+			return nil, errors.New("JWT_KEY is required for HS256")
+		}
+		// a shared secret can't be rotated or published, so there is a single key without a kid
+		r.keys = []*signingKey{{method: jwt.SigningMethodHS256, private: []byte(secret), createdAt: time.Now()}}
+		return r, nil
+	case "RS256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALG %s", r.alg)
+	}
+
+	// keys only kept in memory would be replaced on every restart, logging everyone out
+	// and breaking every service that cached the published keys
+	if r.dir == "" {
+		return nil, fmt.Errorf("JWT_KEYS_DIR is required for %s", r.alg)
+	}
+	if err := r.loadFromDir(); err != nil {
+		return nil, err
+	}
+	if err := r.rotateIfDue(time.Now()); err != nil {
+		return nil, err
+	}
+	log.Printf("JWT signing with %s, %d key(s) loaded", r.alg, len(r.keys))
+	return r, nil
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// active returns the key new tokens are signed with
+func (r *keyRing) active() *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys[len(r.keys)-1]
+}
+
+// lookup finds the key a token was signed with, retired keys are only found during their grace period
+func (r *keyRing) lookup(kid string) (*signingKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.kid == kid && (key.retiredAt.IsZero() || time.Since(key.retiredAt) < r.grace) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// rotateIfDue replaces the active key once it's older than the rotation interval and drops keys past their grace period
+func (r *keyRing) rotateIfDue(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.alg == "HS256" {
+		return nil
+	}
+
+	// dropping keys whose grace period is over
+	kept := r.keys[:0]
+	for _, key := range r.keys {
+		if !key.retiredAt.IsZero() && now.Sub(key.retiredAt) >= r.grace {
+			log.Printf("JWT key %s removed after its grace period", key.kid)
+			if err := os.Remove(filepath.Join(r.dir, key.kid+".pem")); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Failed removing JWT key file %s: %s", key.kid, err)
+			}
+			continue
+		}
+		kept = append(kept, key)
+	}
+	r.keys = kept
+
+	if len(r.keys) > 0 {
+		current := r.keys[len(r.keys)-1]
+		if current.retiredAt.IsZero() && now.Sub(current.createdAt) < r.interval {
+			return nil
+		}
+	}
+
+	key, err := r.generate(now)
+	if err != nil {
+		return err
+	}
+	if len(r.keys) > 0 {
+		previous := r.keys[len(r.keys)-1]
+		if previous.retiredAt.IsZero() {
+			previous.retiredAt = now
+			if err := r.save(previous); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.save(key); err != nil {
+		return err
+	}
+	r.keys = append(r.keys, key)
+	log.Printf("JWT signing key rotated, new kid %s", key.kid)
+	return nil
+}
+
+func (r *keyRing) generate(now time.Time) (*signingKey, error) {
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return nil, err
+	}
+	key := &signingKey{kid: hex.EncodeToString(kidBytes), createdAt: now}
+
+	switch r.alg {
+	case "RS256":
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		key.method, key.private = jwt.SigningMethodRS256, private
+	case "EdDSA":
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.method, key.private = jwt.SigningMethodEdDSA, private
+	}
+	return key, nil
+}
+
+// keys are stored as PKCS#8 PEM files with the metadata in the PEM headers
+func (r *keyRing) save(key *signingKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"Kid":     key.kid,
+		"Created": key.createdAt.UTC().Format(time.RFC3339),
+	}
+	if !key.retiredAt.IsZero() {
+		headers["Retired"] = key.retiredAt.UTC().Format(time.RFC3339)
+	}
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Headers: headers, Bytes: der})
+	return os.WriteFile(filepath.Join(r.dir, key.kid+".pem"), data, 0600)
+}
+
+func (r *keyRing) loadFromDir() error {
+	files, err := filepath.Glob(filepath.Join(r.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != "PRIVATE KEY" {
+			log.Printf("Skipping %s, not a PEM private key", file)
+			continue
+		}
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", file, err)
+		}
+
+		key := &signingKey{kid: block.Headers["Kid"], private: private}
+		switch private.(type) {
+		case *rsa.PrivateKey:
+			key.method = jwt.SigningMethodRS256
+		case ed25519.PrivateKey:
+			key.method = jwt.SigningMethodEdDSA
+		default:
+			log.Printf("Skipping %s, unsupported key type", file)
+			continue
+		}
+		if key.method.Alg() != r.alg {
+			log.Printf("Skipping %s, it's a %s key and JWT_ALG is %s", file, key.method.Alg(), r.alg)
+			continue
+		}
+		if key.kid == "" {
+			key.kid = strings.TrimSuffix(filepath.Base(file), ".pem")
+		}
+		if key.createdAt, err = time.Parse(time.RFC3339, block.Headers["Created"]); err != nil {
+			key.createdAt = time.Now()
+		}
+		if retired := block.Headers["Retired"]; retired != "" {
+			key.retiredAt, _ = time.Parse(time.RFC3339, retired)
+		}
+		r.keys = append(r.keys, key)
+	}
+
+	// oldest first so the newest active key ends up last, extra active keys are retired
+	sort.Slice(r.keys, func(i, j int) bool { return r.keys[i].createdAt.Before(r.keys[j].createdAt) })
+	for i := 0; i < len(r.keys)-1; i++ {
+		if r.keys[i].retiredAt.IsZero() {
+			r.keys[i].retiredAt = r.keys[i+1].createdAt
+		}
+	}
+	return nil
+}
+
+// RunKeyRotation checks on a schedule if the signing key is due for rotation, it blocks until stop is closed
+func RunKeyRotation(stop <-chan struct{}) {
+	r, err := keys()
+	if err != nil || r.alg == "HS256" {
+		return
+	}
+
+	check := r.interval / 10
+	if check > time.Hour {
+		check = time.Hour
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if err := r.rotateIfDue(now); err != nil {
+				log.Printf("JWT key rotation failed: %s", err)
+			}
+		}
+	}
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys other services can verify Jaeger tokens with, empty for HS256
+func JWKS() (JSONWebKeySet, error) {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	r, err := keys()
+	if err != nil {
+		return set, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if !key.retiredAt.IsZero() && time.Since(key.retiredAt) >= r.grace {
+			continue
+		}
+		jwk := JSONWebKey{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue // shared secrets are never published
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// sign signs the claims with the active key
+func sign(claims jwt.MapClaims) (string, error) {
+	r, err := keys()
+	if err != nil {
+		return "", err
+	}
+	if r.issuer != "" {
+		claims["iss"] = r.issuer
+	}
+
+	key := r.active()
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.kid != "" {
+		token.Header["kid"] = key.kid
+	}
+	return token.SignedString(key.private)
+}
+
+// keyFunc picks the verification key by kid and makes sure the token uses that key's algorithm
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	r, err := keys()
+	if err != nil {
+		return nil, err
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := r.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public(), nil
+}
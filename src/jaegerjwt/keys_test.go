@@ -0,0 +1,264 @@
+package jaegerjwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard) // every rotation is logged
+	os.Exit(m.Run())
+}
+
+// useRing makes r the key ring sign, parseToken and JWKS use for the rest of the test
+func useRing(t *testing.T, r *keyRing) {
+	t.Helper()
+	ringOnce.Do(func() {}) // the environment is never read in these tests
+	ring, ringErr = r, nil
+	t.Cleanup(func() { ring = nil })
+}
+
+func newTestRing(t *testing.T, alg string) *keyRing {
+	return &keyRing{alg: alg, dir: t.TempDir(), interval: time.Hour, grace: 10 * time.Minute}
+}
+
+func signTest(t *testing.T) string {
+	t.Helper()
+	token, err := sign(jwt.MapClaims{"email": "ada@example.com", "exp": time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("signing: %s", err)
+	}
+	return token
+}
+
+func TestLoadKeyRingNeedsKeysDir(t *testing.T) {
+	for _, alg := range []string{"RS256", "EdDSA"} {
+		t.Setenv("JWT_ALG", alg)
+		t.Setenv("JWT_KEYS_DIR", "")
+		if _, err := loadKeyRing(); err == nil {
+			t.Errorf("%s without JWT_KEYS_DIR loaded, its keys would change on every restart", alg)
+		}
+	}
+}
+
+func TestLoadKeyRingKeepsKeys(t *testing.T) {
+	t.Setenv("JWT_ALG", "EdDSA")
+	t.Setenv("JWT_KEYS_DIR", t.TempDir())
+
+	first, err := loadKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := loadKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.keys) != 1 || restarted.active().kid != first.active().kid {
+		t.Errorf("restart signs with %s, want the key from before %s", restarted.active().kid, first.active().kid)
+	}
+}
+
+func TestRotateIfDue(t *testing.T) {
+	r := newTestRing(t, "EdDSA")
+	useRing(t, r)
+
+	start := time.Now().Add(-90 * time.Minute)
+	if err := r.rotateIfDue(start); err != nil {
+		t.Fatal(err)
+	}
+	old := r.active()
+	oldToken := signTest(t)
+
+	// not due yet
+	if err := r.rotateIfDue(start.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if r.active() != old {
+		t.Fatalf("key rotated before the interval passed")
+	}
+
+	rotatedAt := time.Now().Add(-5 * time.Minute)
+	if err := r.rotateIfDue(rotatedAt); err != nil {
+		t.Fatal(err)
+	}
+	current := r.active()
+	if current == old || len(r.keys) != 2 || !old.retiredAt.Equal(rotatedAt) {
+		t.Fatalf("after rotation active %s, old %s retired at %v", current.kid, old.kid, old.retiredAt)
+	}
+
+	// new tokens carry the new kid, tokens of the old key still verify in the grace period
+	newToken := signTest(t)
+	for name, tokenStr := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := parseToken(tokenStr); err != nil {
+			t.Errorf("%s token rejected in the grace period: %s", name, err)
+		}
+	}
+	if token, _ := parseToken(newToken); token.Header["kid"] != current.kid {
+		t.Errorf("new token kid = %v, want %s", token.Header["kid"], current.kid)
+	}
+
+	// once the grace period is over the old key is dropped, from the ring and the directory
+	if err := r.rotateIfDue(rotatedAt.Add(r.grace)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseToken(oldToken); err == nil {
+		t.Errorf("old token accepted after the grace period")
+	}
+	if _, err := parseToken(newToken); err != nil {
+		t.Errorf("new token rejected: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, old.kid+".pem")); !os.IsNotExist(err) {
+		t.Errorf("key file of the dropped key still there: %v", err)
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	r := newTestRing(t, "RS256")
+	useRing(t, r)
+	if err := r.rotateIfDue(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	tokenStr := signTest(t)
+
+	parse := func(header map[string]any, method jwt.SigningMethod, key any) error {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()})
+		for name, value := range header {
+			token.Header[name] = value
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parseToken(signed)
+		return err
+	}
+
+	if _, err := parseToken(tokenStr); err != nil {
+		t.Fatalf("token of the active key rejected: %s", err)
+	}
+
+	active := r.active()
+	tests := []struct {
+		name   string
+		header map[string]any
+		method jwt.SigningMethod
+		key    any
+	}{
+		{"unknown kid", map[string]any{"kid": "0123456789abcdef"}, jwt.SigningMethodRS256, active.private},
+		{"no kid", nil, jwt.SigningMethodRS256, active.private},
+		{"kid of the key with another algorithm", map[string]any{"kid": active.kid}, jwt.SigningMethodHS256, []byte("guessed secret")},
+	}
+	for _, tt := range tests {
+		if err := parse(tt.header, tt.method, tt.key); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+}
+
+func TestKeyDirRoundTrip(t *testing.T) {
+	for _, alg := range []string{"RS256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			r := newTestRing(t, alg)
+			now := time.Now().Truncate(time.Second)
+			if err := r.rotateIfDue(now.Add(-2 * time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.rotateIfDue(now.Add(-time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+
+			// a key of the other algorithm in the same directory is skipped
+			other := &keyRing{alg: map[string]string{"RS256": "EdDSA", "EdDSA": "RS256"}[alg], dir: r.dir}
+			otherKey, err := other.generate(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := other.save(otherKey); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded := &keyRing{alg: alg, dir: r.dir, interval: r.interval, grace: r.grace}
+			if err := loaded.loadFromDir(); err != nil {
+				t.Fatal(err)
+			}
+			if len(loaded.keys) != len(r.keys) {
+				t.Fatalf("loaded %d keys, want %d", len(loaded.keys), len(r.keys))
+			}
+			for i, want := range r.keys {
+				got := loaded.keys[i]
+				if got.kid != want.kid || got.method != want.method || !got.createdAt.Equal(want.createdAt.Truncate(time.Second)) || !got.retiredAt.Equal(want.retiredAt.Truncate(time.Second)) {
+					t.Errorf("key %d = %s %s created %v retired %v, want %s %s created %v retired %v", i,
+						got.kid, got.method.Alg(), got.createdAt, got.retiredAt, want.kid, want.method.Alg(), want.createdAt, want.retiredAt)
+				}
+				if !got.private.(interface{ Equal(crypto.PrivateKey) bool }).Equal(want.private) {
+					t.Errorf("key %s doesn't match after loading", want.kid)
+				}
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	for _, alg := range []string{"RS256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			r := newTestRing(t, alg)
+			useRing(t, r)
+			if err := r.rotateIfDue(time.Now().Add(-2 * time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.rotateIfDue(time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			// the retired key is still published in its grace period
+			set, err := JWKS()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(set.Keys) != 2 {
+				t.Fatalf("JWKS has %d keys, want 2", len(set.Keys))
+			}
+			for i, jwk := range set.Keys {
+				key := r.keys[i]
+				if jwk.Kid != key.kid || jwk.Use != "sig" || jwk.Alg != alg {
+					t.Errorf("jwk = %+v, want kid %s", jwk, key.kid)
+				}
+				switch public := key.public().(type) {
+				case *rsa.PublicKey:
+					n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+					e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+					if jwk.Kty != "RSA" || new(big.Int).SetBytes(n).Cmp(public.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(public.E) {
+						t.Errorf("RSA jwk = %+v doesn't match the key", jwk)
+					}
+				case ed25519.PublicKey:
+					x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+					if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || !public.Equal(ed25519.PublicKey(x)) {
+						t.Errorf("Ed25519 jwk = %+v doesn't match the key", jwk)
+					}
+				}
+			}
+
+			r.keys[0].retiredAt = time.Now().Add(-r.grace)
+			if set, _ := JWKS(); len(set.Keys) != 1 || set.Keys[0].Kid != r.active().kid {
+				t.Errorf("JWKS after the grace period = %+v, want only the active key", set.Keys)
+			}
+		})
+	}
+
+	// a shared secret is never published
+	useRing(t, &keyRing{alg: "HS256", keys: []*signingKey{{method: jwt.SigningMethodHS256, private: []byte("secret")}}})
+	if set, err := JWKS(); err != nil || len(set.Keys) != 0 {
+		t.Errorf("HS256 JWKS = %+v, %v, want no keys", set, err)
+	}
+}
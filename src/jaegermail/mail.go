@@ -0,0 +1,108 @@
+package jaegermail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is implemented by everything that can deliver emails to users
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewMailer picks the mailer based on the environment
+// SMTP is used when MAIL_SMTP_ADDR is set, otherwise messages end up in the outbox directory
+func NewMailer() Mailer {
+	if err := godotenv.Load("../.env"); err != nil {
+		log.Printf("Failed loading the environment file: %s", err)
+	}
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "jaeger@localhost"
+	}
+
+	if addr := os.Getenv("MAIL_SMTP_ADDR"); addr != "" {
+		host := strings.Split(addr, ":")[0]
+		var auth smtp.Auth
+		if user := os.Getenv("MAIL_SMTP_USER"); user != "" {
+			auth = smtp.PlainAuth("", user, os.Getenv("MAIL_SMTP_PASSWORD"), host)
+		}
+		log.Printf("Sending mail through SMTP server %s", addr)
+		return &SMTPMailer{Addr: addr, Auth: auth, From: from}
+	}
+
+	dir := os.Getenv("MAIL_OUTBOX_DIR")
+	if dir == "" {
+		dir = "../outbox"
+	}
+	log.Printf("Writing mail to the outbox directory %s", dir)
+	return &OutboxMailer{Dir: dir, From: from}
+}
+
+// OutboxMailer writes every message as an .eml file into Dir, so mail works offline and in tests
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *OutboxMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	if err := os.WriteFile(filepath.Join(m.Dir, name), formatMessage(m.From, msg), 0600); err != nil {
+		return err
+	}
+	log.Printf("Mail to %s written to outbox as %s", msg.To, name)
+	return nil
+}
+
+type SMTPMailer struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{msg.To}, formatMessage(m.From, msg))
+}
+
+func formatMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks so values can't inject extra headers
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
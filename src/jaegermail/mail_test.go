@@ -0,0 +1,56 @@
+package jaegermail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutboxMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox") // created on the first message
+	mailer := &OutboxMailer{Dir: dir, From: "jaeger@test.local"}
+
+	for _, to := range []string{"ada@example.com", "bob@example.com"} {
+		if err := mailer.Send(Message{To: to, Subject: "Hello", Body: "line one\nline two\n"}); err != nil {
+			t.Fatalf("Send: %s", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("outbox has %d files, want 2", len(entries))
+	}
+	if !strings.HasSuffix(entries[0].Name(), ".eml") {
+		t.Errorf("file name %q doesn't end in .eml", entries[0].Name())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body, found := strings.Cut(string(data), "\r\n\r\n")
+	if !found {
+		t.Fatalf("no blank line between header and body in %q", data)
+	}
+	for _, want := range []string{"From: jaeger@test.local\r\n", "To: ada@example.com\r\n", "Subject: Hello\r\n", "Content-Type: text/plain; charset=utf-8"} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header %q is missing %q", header, want)
+		}
+	}
+	if body != "line one\r\nline two\r\n" {
+		t.Errorf("body = %q, want CRLF line endings", body)
+	}
+}
+
+func TestFormatMessageStripsHeaderInjection(t *testing.T) {
+	msg := string(formatMessage("jaeger@test.local", Message{To: "ada@example.com\r\nBcc: eve@example.com", Subject: "Hi\nX-Evil: 1"}))
+	header, _, _ := strings.Cut(msg, "\r\n\r\n")
+
+	if strings.Contains(header, "\r\nBcc:") || strings.Contains(header, "\nX-Evil:") {
+		t.Errorf("line breaks in values added headers: %q", header)
+	}
+}
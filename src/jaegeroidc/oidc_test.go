@@ -0,0 +1,150 @@
+package jaegeroidc_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/url"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegeroidc"
+	"github.com/MGavranovic/jaeger-backend/src/jaegeroidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "http://jaeger.test/api/users/oidc/mock/callback"
+
+func TestDiscover(t *testing.T) {
+	idp := oidctest.New(t)
+
+	if err := idp.Provider("mock", redirectURL).Discover(t.Context()); err != nil {
+		t.Fatalf("discovery failed: %s", err)
+	}
+
+	// the document has to be about the issuer it was fetched from
+	wrong := idp.Provider("mock", redirectURL)
+	wrong.Issuer = idp.Issuer() + "/other"
+	if err := wrong.Discover(t.Context()); err == nil {
+		t.Errorf("discovery accepted a document for another issuer")
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := oidctest.New(t)
+	provider := idp.Provider("mock", redirectURL)
+
+	verifier, challenge, err := jaegeroidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(t.Context(), "the-state", "the-nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "the-state" {
+		t.Errorf("state = %q, want the-state", state)
+	}
+
+	// the provider only hands out tokens for the verifier of the challenge
+	if _, err := provider.Exchange(t.Context(), code, "not-the-verifier"); err == nil {
+		t.Fatalf("exchange succeeded with the wrong code verifier")
+	}
+	tokens, err := provider.Exchange(t.Context(), code, verifier)
+	if err != nil {
+		t.Fatalf("exchange failed: %s", err)
+	}
+	if _, err := provider.Exchange(t.Context(), code, verifier); err == nil {
+		t.Errorf("the code was exchanged twice")
+	}
+
+	claims, err := provider.VerifyIDToken(t.Context(), tokens.IDToken, "the-nonce")
+	if err != nil {
+		t.Fatalf("verifying the id token failed: %s", err)
+	}
+	want := jaegeroidc.IDTokenClaims{Subject: idp.Subject, Email: idp.Email, EmailVerified: true, Name: idp.Name}
+	if *claims != want {
+		t.Errorf("claims = %+v, want %+v", *claims, want)
+	}
+
+	params, _ := url.Parse(authURL)
+	if method := params.Query().Get("code_challenge_method"); method != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", method)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := oidctest.New(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    *rsa.PrivateKey
+		nonce  string
+		tamper func(jwt.MapClaims)
+		valid  bool
+	}{
+		{name: "valid", valid: true},
+		{name: "email_verified as a string", tamper: func(c jwt.MapClaims) { c["email_verified"] = "true" }, valid: true},
+		{name: "signed with another key", key: otherKey},
+		{name: "other nonce", nonce: "someone elses nonce"},
+		{name: "no nonce", tamper: func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{name: "other audience", tamper: func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{name: "several audiences without azp", tamper: func(c jwt.MapClaims) { c["aud"] = []string{idp.ClientID, "another-client"} }},
+		{name: "other issuer", tamper: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", tamper: func(c jwt.MapClaims) { c["exp"] = c["iat"].(int64) - 3600 }},
+		{name: "no expiry", tamper: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "no subject", tamper: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := idp.Provider("mock", redirectURL)
+			claims := idp.Claims("the-nonce")
+			if tt.tamper != nil {
+				tt.tamper(claims)
+			}
+			key := idp.Key
+			if tt.key != nil {
+				key = tt.key
+			}
+			nonce := "the-nonce"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			raw, err := idp.Sign(claims, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = provider.VerifyIDToken(t.Context(), raw, nonce)
+			if tt.valid && err != nil {
+				t.Errorf("valid token rejected: %s", err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("invalid token accepted")
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsHMAC(t *testing.T) {
+	idp := oidctest.New(t)
+	provider := idp.Provider("mock", redirectURL)
+
+	// an HS256 token "signed" with the public modulus mustn't pass as RS256
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.Claims("the-nonce"))
+	token.Header["kid"] = oidctest.KeyID
+	raw, err := token.SignedString(idp.Key.PublicKey.N.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.VerifyIDToken(t.Context(), raw, "the-nonce"); err == nil {
+		t.Errorf("HS256 token accepted")
+	}
+}
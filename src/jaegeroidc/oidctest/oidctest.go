@@ -0,0 +1,257 @@
+package oidctest
+
+// Mock OpenID Connect provider for tests: discovery, an authorization endpoint that logs
+// the user in right away, a token endpoint that checks the PKCE verifier and a JWKS.
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegeroidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const KeyID = "mock-key"
+
+type IdP struct {
+	Server       *httptest.Server
+	Key          *rsa.PrivateKey
+	ClientID     string
+	ClientSecret string
+
+	// the user that logs in at the authorization endpoint
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+
+	// Tamper is called with the claims of every ID token before it's signed
+	Tamper func(claims jwt.MapClaims)
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+type authorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// New starts a provider that's shut down with the test
+func New(t *testing.T) *IdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating the IdP key: %s", err)
+	}
+
+	idp := &IdP{
+		Key:           key,
+		ClientID:      "jaeger",
+		ClientSecret:  "jaeger secret",
+		Subject:       "mock-subject",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		Name:          "Ada Lovelace",
+		codes:         map[string]authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("/authorize", idp.handleAuthorize)
+	mux.HandleFunc("/token", idp.handleToken)
+	mux.HandleFunc("/jwks", idp.handleJWKS)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Server.Close)
+	return idp
+}
+
+func (idp *IdP) Issuer() string {
+	return idp.Server.URL
+}
+
+// Provider returns a relying party configured for the IdP
+func (idp *IdP) Provider(name, redirectURL string) *jaegeroidc.Provider {
+	return &jaegeroidc.Provider{
+		Name:         name,
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		HTTPClient:   idp.Server.Client(),
+	}
+}
+
+// Authorize follows the authorization URL like a browser would and returns the code and state sent back to the redirect URL
+func (idp *IdP) Authorize(authURL string) (string, string, error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("authorization endpoint returned %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+// Claims returns the claims of a valid ID token for the user
+func (idp *IdP) Claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.Issuer(),
+		"aud":            idp.ClientID,
+		"sub":            idp.Subject,
+		"email":          idp.Email,
+		"email_verified": idp.EmailVerified,
+		"name":           idp.Name,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+// Sign signs the claims with key under the IdP's key id, pass idp.Key for a valid signature
+func (idp *IdP) Sign(claims jwt.MapClaims, key *rsa.PrivateKey) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = KeyID
+	return token.SignedString(key)
+}
+
+func (idp *IdP) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 idp.Issuer(),
+		"authorization_endpoint": idp.Issuer() + "/authorize",
+		"token_endpoint":         idp.Issuer() + "/token",
+		"jwks_uri":               idp.Issuer() + "/jwks",
+	})
+}
+
+func (idp *IdP) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != idp.ClientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE is required", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+	idp.mu.Lock()
+	idp.codes[code] = authorization{
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	idp.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *IdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := idp.checkTokenRequest(r); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": err.Error()})
+		return
+	}
+
+	// codes can only be used once
+	idp.mu.Lock()
+	auth := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	claims := idp.Claims(auth.nonce)
+	if idp.Tamper != nil {
+		idp.Tamper(claims)
+	}
+	idToken, err := idp.Sign(claims, idp.Key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, jaegeroidc.TokenResponse{AccessToken: rand.Text(), TokenType: "Bearer", IDToken: idToken, ExpiresIn: 3600})
+}
+
+func (idp *IdP) checkTokenRequest(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		return errors.New("client authentication is required")
+	}
+	clientID, _ = url.QueryUnescape(clientID)
+	secret, _ = url.QueryUnescape(secret)
+	if clientID != idp.ClientID || secret != idp.ClientSecret {
+		return errors.New("invalid client credentials")
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		return errors.New("unsupported grant type")
+	}
+
+	idp.mu.Lock()
+	auth, ok := idp.codes[r.PostForm.Get("code")]
+	idp.mu.Unlock()
+	if !ok {
+		return errors.New("unknown or used code")
+	}
+	if r.PostForm.Get("redirect_uri") != auth.redirectURI {
+		return errors.New("redirect_uri doesn't match the authorization request")
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		return errors.New("code_verifier doesn't match the code_challenge")
+	}
+	return nil
+}
+
+func (idp *IdP) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := idp.Key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,62 @@
+package jaegerpassword
+
+// breached passwords are looked up in a local copy of a list like Have I Been Pwned's,
+// so passwords never leave the server. BREACHED_PASSWORDS_PATH points either to
+//   - a directory of range files named after the first 5 hex characters of the SHA-1 hash,
+//     each containing the remaining 35 characters per line ("SUFFIX:COUNT"), which is the k-anonymity layout
+//     the range API serves, so only one small file is read per check
+//   - a single file with one full SHA-1 hash per line ("HASH:COUNT"), scanned line by line
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const prefixLength = 5
+
+func isBreached(path, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return containsHash(path, hash)
+	}
+
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		found, err := containsHash(filepath.Join(path, name), suffix)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return found, err
+	}
+	return false, nil // no range file means no breached password with that prefix
+}
+
+// containsHash scans the file for a line starting with the hash, counts after a colon are ignored
+func containsHash(path, hash string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, hash) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,181 @@
+package jaegerpassword
+
+// passwords are hashed on the server with bcrypt by default or argon2id if PASSWORD_HASH=argon2id,
+// hashes made with other settings still verify and get replaced on the next login
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// argon2id parameters, the OWASP recommendation for 64 MiB of memory
+const (
+	argonMemory  = 64 * 1024 // KiB
+	argonTime    = 3
+	argonThreads = 2
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+type hashConfig struct {
+	alg        string
+	bcryptCost int
+}
+
+var (
+	configOnce sync.Once
+	config     hashConfig
+)
+
+func loadConfig() hashConfig {
+	configOnce.Do(func() {
+		if err := godotenv.Load("../.env"); err != nil {
+			log.Printf("Failed loading the environment file: %s", err)
+		}
+
+		config = hashConfig{alg: Bcrypt, bcryptCost: bcrypt.DefaultCost}
+		if os.Getenv("PASSWORD_HASH") == Argon2id {
+			config.alg = Argon2id
+		}
+		if value := os.Getenv("BCRYPT_COST"); value != "" {
+			cost, err := strconv.Atoi(value)
+			if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+				log.Printf("Invalid BCRYPT_COST %q, using %d", value, bcrypt.DefaultCost)
+			} else {
+				config.bcryptCost = cost
+			}
+		}
+	})
+	return config
+}
+
+// Hash hashes the password with the configured algorithm
+func Hash(password string) (string, error) {
+	cfg := loadConfig()
+	if cfg.alg == Argon2id {
+		return hashArgon2id(password)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cfg.bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify checks the password against the stored hash, needsRehash is set when the hash
+// was made with a different algorithm or weaker parameters than the configured ones
+func Verify(hash, password string) (ok bool, needsRehash bool, err error) {
+	cfg := loadConfig()
+
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, false, err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false, nil
+		}
+		stale := cfg.alg != Argon2id || params.memory < argonMemory || params.time < argonTime
+		return true, stale, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, err
+	}
+	return true, cfg.alg != Bcrypt || cost < cfg.bcryptCost, nil
+}
+
+var (
+	dummyOnce sync.Once
+	dummy     string
+)
+
+// dummyHash is compared against when there is no user, so unknown emails take as long as wrong passwords,
+// it's made by Hash so it has the algorithm and parameters of the real hashes
+func dummyHash() string {
+	dummyOnce.Do(func() {
+		hash, err := Hash("jaeger timing equalization")
+		if err != nil {
+			log.Printf("Failed hashing the dummy password: %s", err)
+			return
+		}
+		dummy = hash
+	})
+	return dummy
+}
+
+// VerifyDummy burns the time of a real password check
+func VerifyDummy(password string) {
+	Verify(dummyHash(), password)
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// hashArgon2id returns the hash in the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func hashArgon2id(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,59 @@
+package jaegerpassword
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash: %s", err)
+	}
+
+	ok, needsRehash, err := Verify(hash, "correct horse battery staple")
+	if err != nil || !ok || needsRehash {
+		t.Errorf("Verify(right password) = %t, %t, %v, want true, false, nil", ok, needsRehash, err)
+	}
+	ok, _, err = Verify(hash, "correct horse battery stable")
+	if err != nil || ok {
+		t.Errorf("Verify(wrong password) = %t, %v, want false, nil", ok, err)
+	}
+}
+
+func TestDummyHashMatchesConfig(t *testing.T) {
+	cfg := loadConfig()
+	hash := dummyHash()
+
+	if cfg.alg == Argon2id {
+		if !strings.HasPrefix(hash, "$argon2id$") {
+			t.Fatalf("dummy hash %q isn't argon2id", hash)
+		}
+		return
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		t.Fatalf("dummy hash %q isn't bcrypt: %s", hash, err)
+	}
+	if cost != cfg.bcryptCost {
+		t.Errorf("dummy hash cost = %d, want the configured %d", cost, cfg.bcryptCost)
+	}
+}
+
+func TestDummyHashWithArgon2id(t *testing.T) {
+	loadConfig()
+	saved := config
+	t.Cleanup(func() {
+		config = saved
+		dummyOnce, dummy = sync.Once{}, ""
+	})
+	config = hashConfig{alg: Argon2id, bcryptCost: bcrypt.DefaultCost}
+	dummyOnce, dummy = sync.Once{}, ""
+
+	if hash := dummyHash(); !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("dummy hash %q isn't argon2id with PASSWORD_HASH=argon2id", hash)
+	}
+}
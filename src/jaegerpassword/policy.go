@@ -0,0 +1,136 @@
+package jaegerpassword
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMinLength = 8
+	bcryptMaxBytes   = 72  // bcrypt ignores everything after that
+	argonMaxLength   = 256 // long enough for any passphrase without making hashing a DoS vector
+)
+
+// PolicyError lists every rule the password broke so they can be shown to the user at once
+type PolicyError struct {
+	Problems []string
+}
+
+func (e *PolicyError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+type policy struct {
+	minLength    int
+	minClasses   int    // how many of lowercase, uppercase, digits and symbols have to be used
+	breachedPath string // file or directory with SHA-1 hashes of breached passwords
+}
+
+var (
+	policyOnce    sync.Once
+	currentPolicy policy
+)
+
+func loadPolicy() policy {
+	policyOnce.Do(func() {
+		loadConfig() // loads the environment file
+
+		currentPolicy = policy{
+			minLength:    intFromEnv("PASSWORD_MIN_LENGTH", defaultMinLength),
+			minClasses:   intFromEnv("PASSWORD_MIN_CLASSES", 0),
+			breachedPath: os.Getenv("BREACHED_PASSWORDS_PATH"),
+		}
+	})
+	return currentPolicy
+}
+
+func intFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// Validate checks the password against the policy, personal is the user's email, name etc. which can't be used as password
+func Validate(password string, personal ...string) error {
+	p := loadPolicy()
+	var problems []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		problems = append(problems, fmt.Sprintf("password must be at least %d characters long", p.minLength))
+	}
+	if loadConfig().alg == Bcrypt && len(password) > bcryptMaxBytes {
+		problems = append(problems, fmt.Sprintf("password can't be longer than %d bytes", bcryptMaxBytes))
+	} else if length > argonMaxLength {
+		problems = append(problems, fmt.Sprintf("password can't be longer than %d characters", argonMaxLength))
+	}
+
+	if classes := characterClasses(password); classes < p.minClasses {
+		problems = append(problems, fmt.Sprintf("password must use at least %d of lowercase letters, uppercase letters, digits and symbols", p.minClasses))
+	}
+
+	lower := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, ok := strings.Cut(value, "@"); ok && len(local) >= 3 && strings.Contains(lower, local) {
+			problems = append(problems, "password can't contain your email address")
+			break
+		}
+		if value != "" && lower == value {
+			problems = append(problems, "password can't be your name or email address")
+			break
+		}
+	}
+
+	if p.breachedPath != "" {
+		breached, err := isBreached(p.breachedPath, password)
+		if err != nil {
+			// an unreadable list shouldn't stop users from signing up
+			log.Printf("Failed checking the breached passwords list: %s", err)
+		} else if breached {
+			problems = append(problems, "password has appeared in a data breach, please choose a different one")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &PolicyError{Problems: problems}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			classes++
+		}
+	}
+	return classes
+}
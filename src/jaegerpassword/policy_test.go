@@ -0,0 +1,85 @@
+package jaegerpassword
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withPolicy runs the test with the given policy and hash algorithm instead of the ones from the environment
+func withPolicy(t *testing.T, p policy, alg string) {
+	t.Helper()
+	loadPolicy()
+	savedPolicy, savedConfig := currentPolicy, config
+	t.Cleanup(func() { currentPolicy, config = savedPolicy, savedConfig })
+	currentPolicy = p
+	config.alg = alg
+}
+
+func TestValidate(t *testing.T) {
+	sum := sha1.Sum([]byte("Password1!"))
+	breached := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(breached, []byte(strings.ToUpper(hex.EncodeToString(sum[:]))+":42\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	withPolicy(t, policy{minLength: 8, minClasses: 3, breachedPath: breached}, Bcrypt)
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		problem  string // part of the expected error, empty when the password is fine
+	}{
+		{"valid", "Tr0ub4dor&3", nil, ""},
+		{"too short", "Ab1!", nil, "at least 8 characters"},
+		{"too few classes", "alllowercase", nil, "at least 3 of"},
+		{"too long for bcrypt", "Aa1!" + strings.Repeat("x", 70), nil, "longer than 72 bytes"},
+		{"contains the email", "Xjane.doe99!", []string{"jane.doe@example.com", "Jane Doe"}, "can't contain your email"},
+		{"is the name", "Jane Doe 1", []string{"", "jane doe 1"}, "can't be your name"},
+		{"empty personal values are skipped", "Tr0ub4dor&3", []string{"", " "}, ""},
+		{"short email local part is ignored", "Tr0ub4dor&3jo", []string{"jo@example.com"}, ""},
+		{"breached", "Password1!", nil, "data breach"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.password, tt.personal...)
+			if tt.problem == "" {
+				if err != nil {
+					t.Fatalf("Validate(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("Validate(%q) = %v, want an error containing %q", tt.password, err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestValidateArgon2idLength(t *testing.T) {
+	withPolicy(t, policy{minLength: 8}, Argon2id)
+
+	if err := Validate(strings.Repeat("long passphrase ", 10)); err != nil {
+		t.Errorf("160 characters with argon2id = %v, want nil", err)
+	}
+	if err := Validate(strings.Repeat("x", argonMaxLength+1)); err == nil {
+		t.Errorf("%d characters with argon2id passed, want an error", argonMaxLength+1)
+	}
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	withPolicy(t, policy{minLength: 8, minClasses: 3}, Bcrypt)
+
+	err := Validate("abc")
+	policyErr, ok := err.(*PolicyError)
+	if !ok {
+		t.Fatalf("Validate = %v, want a *PolicyError", err)
+	}
+	if len(policyErr.Problems) != 2 {
+		t.Errorf("problems = %q, want the length and the classes", policyErr.Problems)
+	}
+}
@@ -0,0 +1,118 @@
+package jaegertotp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the SHA1 test vectors from RFC 6238 Appendix B, the codes there have 8 digits and ours are their last 6
+func TestCodeRFC6238(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(secret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %s", tt.unix, err)
+		}
+		if want := tt.want[len(tt.want)-Digits:]; code != want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, code, want)
+		}
+	}
+
+	// secrets are read case insensitively, some apps show them in lower case
+	if code, _ := Code(strings.ToLower(secret), Counter(time.Unix(59, 0))); code != "287082" {
+		t.Errorf("Code with a lower case secret = %s, want 287082", code)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Errorf("Code accepted a secret that isn't base32")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	current := Counter(now)
+	code := func(step int64) string {
+		c, err := Code(secret, current+step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+		step int64
+	}{
+		{"current step", code(0), true, 0},
+		{"previous step", code(-1), true, -1},
+		{"next step", code(1), true, 1},
+		{"two steps ago", code(-2), false, 0},
+		{"two steps ahead", code(2), false, 0},
+		{"spaces", code(0)[:3] + " " + code(0)[3:], true, 0},
+		{"too short", code(0)[:Digits-1], false, 0},
+		{"empty", "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := Validate(secret, tt.code, now)
+			if ok != tt.ok {
+				t.Fatalf("Validate(%q) = %t, want %t", tt.code, ok, tt.ok)
+			}
+			if ok && counter != current+tt.step {
+				t.Errorf("matched step %d, want %d", counter-current, tt.step)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("Jaeger", "ada@example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Jaeger:ada@example.com" {
+		t.Errorf("URI = %s", uri)
+	}
+	query := uri.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "Jaeger" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("URI parameters = %v", query)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Errorf("recovery code %q is malformed or repeated", code)
+		}
+		seen[code] = true
+		if NormalizeRecoveryCode(" "+strings.ToUpper(code)+" ") != strings.ReplaceAll(code, "-", "") {
+			t.Errorf("NormalizeRecoveryCode doesn't match %q typed in upper case", code)
+		}
+	}
+}
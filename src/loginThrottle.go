@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// brute force protection for password logins, failures are tracked per account and per client ip
+const (
+	loginFailureWindow     = 24 * time.Hour   // older failures are forgotten
+	accountFreeAttempts    = 5                // failures before the backoff kicks in
+	accountLockoutAttempts = 10               // failures after which the account is locked
+	accountLockoutDuration = 15 * time.Minute // lock duration, counted from the last failure
+	ipFreeAttempts         = 20               // an ip trying many accounts is slowed down as well
+	maxLoginBackoff        = 15 * time.Minute
+	recentFailuresLimit    = 20 // failures shown to the user
+)
+
+// loginBlock is returned when a login has to wait, Status is 423 for locked accounts and 429 for throttled ones
+type loginBlock struct {
+	Status     int
+	RetryAfter time.Duration
+}
+
+// backoff doubles the wait with every failure past the free ones
+func backoff(failures, free int) time.Duration {
+	if failures < free {
+		return 0
+	}
+	wait := time.Second * time.Duration(math.Pow(2, float64(failures-free)))
+	if wait <= 0 || wait > maxLoginBackoff {
+		return maxLoginBackoff
+	}
+	return wait
+}
+
+// loginLocks serializes the logins per account and per client ip, otherwise parallel attempts would all pass
+// the throttle before the first of their failures is recorded. It only covers this process, which is what runs the API
+type loginLocks struct {
+	mu    sync.Mutex
+	locks map[string]*loginLock
+}
+
+type loginLock struct {
+	mu    sync.Mutex
+	users int // requests holding or waiting for the lock, it's dropped when none are left
+}
+
+func (l *loginLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*loginLock{}
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &loginLock{}
+		l.locks[key] = lock
+	}
+	lock.users++
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		l.mu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// lockLogin has to be held from checking the throttle until the attempt is recorded, the account is always locked before the ip
+func (s *Server) lockLogin(r *http.Request, email string) (unlock func()) {
+	unlockAccount := s.loginLocks.lock("account:" + strings.ToLower(email))
+	unlockIP := s.loginLocks.lock("ip:" + clientIP(r))
+	return func() {
+		unlockIP()
+		unlockAccount()
+	}
+}
+
+// checkLoginThrottle returns a block if the account or the ip failed too often recently, nil if the login can be tried
+func (s *Server) checkLoginThrottle(ctx context.Context, email, ip string) (*loginBlock, error) {
+	now := time.Now()
+	failures, err := s.users.GetLoginFailures(ctx, strings.ToLower(email), ip, now.Add(-loginFailureWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	if failures.AccountLastFailure != nil {
+		if failures.AccountFailures >= accountLockoutAttempts {
+			if wait := failures.AccountLastFailure.Add(accountLockoutDuration).Sub(now); wait > 0 {
+				return &loginBlock{Status: http.StatusLocked, RetryAfter: wait}, nil
+			}
+		} else if wait := failures.AccountLastFailure.Add(backoff(failures.AccountFailures, accountFreeAttempts)).Sub(now); wait > 0 {
+			return &loginBlock{Status: http.StatusTooManyRequests, RetryAfter: wait}, nil
+		}
+	}
+
+	if failures.IPLastFailure != nil {
+		if wait := failures.IPLastFailure.Add(backoff(failures.IPFailures, ipFreeAttempts)).Sub(now); wait > 0 {
+			return &loginBlock{Status: http.StatusTooManyRequests, RetryAfter: wait}, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordLoginAttempt keeps track of the outcome, failing to store it only gets logged
+func (s *Server) recordLoginAttempt(r *http.Request, email string, succeeded bool) {
+	if err := s.users.RecordLoginAttempt(r.Context(), strings.ToLower(email), clientIP(r), r.UserAgent(), succeeded); err != nil {
+		log.Printf("Failed recording login attempt for %s: %s", email, err)
+	}
+}
+
+// rejectThrottledLogin answers the request through writeError if the login is blocked and reports if it did
+func (s *Server) rejectThrottledLogin(w http.ResponseWriter, r *http.Request, email string, writeError func(w http.ResponseWriter, message string, status int)) bool {
+	block, err := s.checkLoginThrottle(r.Context(), email, clientIP(r))
+	if err != nil {
+		log.Printf("Failed checking login attempts for %s: %s", email, err)
+		writeError(w, "Failed to compare credentials", http.StatusInternalServerError)
+		return true
+	}
+	if block == nil {
+		return false
+	}
+
+	seconds := int(math.Ceil(block.RetryAfter.Seconds()))
+	log.Printf("Login for %s from %s blocked for %ds", email, clientIP(r), seconds)
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+	if block.Status == http.StatusLocked {
+		writeError(w, "Account temporarily locked after too many failed login attempts", http.StatusLocked)
+	} else {
+		writeError(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+	}
+	return true
+}
+
+// handleLoginFailures shows the user the recent failed logins to their account
+func (s *Server) handleLoginFailures(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized login attempts request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	attempts, err := s.users.GetRecentLoginFailures(r.Context(), caller.User.ID, recentFailuresLimit)
+	if err != nil {
+		log.Printf("Failed retrieving login attempts for user %d: %s", caller.User.ID, err)
+		http.Error(w, "Failed to retrieve login attempts", http.StatusInternalServerError)
+		return
+	}
+	if attempts == nil {
+		attempts = []jaegerdb.LoginAttemptDB{}
+	}
+	writeJSON(w, http.StatusOK, attempts)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// countingStore counts the password checks that get past the throttle
+type countingStore struct {
+	jaegerdb.UserStore
+	checks atomic.Int32
+}
+
+func (s *countingStore) CheckCredentialsOnLogin(ctx context.Context, email, password string) error {
+	s.checks.Add(1)
+	return s.UserStore.CheckCredentialsOnLogin(ctx, email, password)
+}
+
+func TestParallelLoginsAreThrottled(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup(t, "ada@example.com")
+	store := &countingStore{UserStore: ts.store}
+	ts.users = store
+
+	const attempts = 4 * accountFreeAttempts
+	codes := make([]int, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = ts.client(t).do(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "ada@example.com", Password: "wrong password"}).Code
+		}()
+	}
+	wg.Wait()
+
+	if checks := store.checks.Load(); checks > accountFreeAttempts {
+		t.Errorf("%d of %d parallel logins checked the password, want at most %d", checks, attempts, accountFreeAttempts)
+	}
+	counts := map[int]int{}
+	for _, code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusUnauthorized] > accountFreeAttempts || counts[http.StatusTooManyRequests] < attempts-accountFreeAttempts {
+		t.Errorf("responses = %v, want at most %d 401 and the rest 429", counts, accountFreeAttempts)
+	}
+
+	// the right password has to wait as well
+	ts.client(t).expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "ada@example.com", Password: testPassword}, http.StatusTooManyRequests)
+}
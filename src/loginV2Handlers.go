@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// writeJSONError has the signature of http.Error so the shared login code can answer in either format
+func writeJSONError(w http.ResponseWriter, message string, status int) {
+	writeJSON(w, status, APIError{Error: message})
+}
+
+// handleLoginV2 takes the credentials from the body so the email doesn't end up in access logs
+func (s *Server) handleLoginV2(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var loginData LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&loginData); err != nil {
+		log.Printf("Error decoding JSON to login data: %s", err)
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	loginData.Email = strings.TrimSpace(loginData.Email)
+	if loginData.Email == "" || loginData.Password == "" {
+		writeJSONError(w, "Email and password are required", http.StatusBadRequest)
+		return
+	}
+
+	s.loginWithPassword(w, r, loginData.Email, loginData.Password, writeJSONError)
+}
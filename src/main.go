@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"strconv"
 	"strings"
 
@@ -13,15 +15,29 @@ import (
 
 	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
 	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/jackc/pgx/v5"
+	"github.com/MGavranovic/jaeger-backend/src/jaegermail"
+	"github.com/MGavranovic/jaeger-backend/src/jaegeroidc"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerpassword"
 )
 
 type Server struct {
-	dbConn *pgx.Conn
+	users                jaegerdb.UserStore
+	notes                jaegerdb.NoteStore
+	mailer               jaegermail.Mailer
+	requireVerifiedEmail bool // notes can only be created once the email is verified
+	oidcProviders        map[string]*jaegeroidc.Provider
+	loginLocks           loginLocks // one password or 2FA check at a time per account and ip
+	resetEmailLimits     requestLimiter
+	resetIPLimits        requestLimiter
 }
 
 func main() {
+	autoMigrate := flag.Bool("auto-migrate", false, "apply pending migrations on startup, AUTO_MIGRATE=true does the same")
+	flag.Parse()
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrateCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	}
+
 	// TODO: create internal server logging
 	// Creating a log file
 	file, err := os.OpenFile("../logs/server.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -34,36 +50,53 @@ func main() {
 	log.Printf("Start logging") // Test printing to the same logfile
 
 	// Connecting to DB
-	dbConn := jaegerdb.ConnectJaegerDB()
-	defer dbConn.Close(context.Background())
+	store, err := jaegerdb.OpenStore()
+	if err != nil {
+		log.Fatalf("Failed opening the store: %s", err)
+	}
+	defer store.Close()
+
+	if *autoMigrate || os.Getenv("AUTO_MIGRATE") == "true" {
+		if m, ok := store.(jaegerdb.Migrator); ok {
+			applied, err := m.MigrateUp(context.Background())
+			if err != nil {
+				log.Fatalf("Failed migrating the DB: %s", err)
+			}
+			log.Printf("Applied %d migrations", len(applied))
+		}
+	}
+
+	// Loading the JWT signing keys and rotating them on schedule
+	if err := jaegerjwt.Init(); err != nil {
+		log.Fatalf("Failed loading the JWT signing keys: %s", err)
+	}
+	stop := make(chan struct{}) // stops the background jobs
+	defer close(stop)
+	go jaegerjwt.RunKeyRotation(stop)
 
 	apiServer := &Server{
-		dbConn: dbConn,
+		users:                store,
+		notes:                store,
+		mailer:               jaegermail.NewMailer(),
+		requireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		oidcProviders:        jaegeroidc.ProvidersFromEnv(publicURL()),
 	}
 
+	// accounts deleted by their users are purged after the grace period
+	go apiServer.runAccountPurge(stop)
+
+	// tokens of revoked sessions are rejected on validation
+	jaegerjwt.SetSessionCheck(func(ctx context.Context, jti string) (bool, error) {
+		return apiServer.users.IsSessionActive(ctx, jti)
+	})
+
 	// TODO: create internal server package
 	// Server setup
-	mux := http.NewServeMux()  // creating servemux
 	httpServer := http.Server{ // server config
 		Addr:    ":8080",
-		Handler: mux,
+		Handler: apiServer.routes(),
 	}
 
-	// API endpoints
-	mux.HandleFunc("/api/users", apiServer.handleGetUsers)
-	mux.HandleFunc("/api/users/signup", apiServer.handleSignupUser)
-	mux.HandleFunc("/api/users/login/", apiServer.handleLoginUser)
-	mux.HandleFunc("/api/users/login/auth", apiServer.checkAuth)
-	mux.HandleFunc("/api/users/logout", apiServer.handleLogoutUser)
-	mux.HandleFunc("/api/users/current/", apiServer.handleGetLoggedinUser)
-	mux.HandleFunc("/api/users/current", apiServer.handleGetCurrentUser) // this is needed for user data on frontend to be up to date
-	mux.HandleFunc("/api/users/current/update", apiServer.handleUpdateUserData)
-	mux.HandleFunc("/api/notes/create", apiServer.handleCreateNote)
-	mux.HandleFunc("/api/notes/", apiServer.handleGetUserNotes)
-	mux.HandleFunc("/api/notes/update", apiServer.handleUpdateNote)
-	mux.HandleFunc("/api/notes/current/", apiServer.handleGetCurrentNote)
-	mux.HandleFunc("/api/notes/delete/", apiServer.handleDeleteNote)
-
 	// Server starting
 	log.Print("Server starting on port 8080")
 	if err := httpServer.ListenAndServe(); err != nil { // ListenAndServe will block execution
@@ -71,26 +104,79 @@ func main() {
 	}
 }
 
-func enableCors(w *http.ResponseWriter) {
-	(*w).Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-	(*w).Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	(*w).Header().Set("Access-Control-Allow-Headers", "*")
-	(*w).Header().Set("Access-Control-Allow-Credentials", "true")
-}
+// routes registers the API endpoints, the tests serve the same handler
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux() // creating servemux
 
-func (s *Server) handleGetUsers(w http.ResponseWriter, r *http.Request) {
-	enableCors(&w)
-	users := jaegerdb.GetUsersJaeger(s.dbConn) // getting users from db
+	// API endpoints
+	mux.HandleFunc("/.well-known/jwks.json", s.handleJWKS)
+	mux.HandleFunc("/api/users/signup", s.handleSignupUser)
+	mux.HandleFunc("/api/users/login/", s.handleLoginUser)
+	mux.HandleFunc("/api/v2/users/login", s.handleLoginV2)
+	mux.HandleFunc("/api/users/login/auth", s.checkAuth)
+	mux.HandleFunc("/api/users/login/2fa", s.handleLoginSecondFactor)
+	mux.HandleFunc("/api/users/logout", s.handleLogoutUser)
+	mux.HandleFunc("/api/users/logout/all", s.handleLogoutAllDevices)
+	mux.HandleFunc("/api/users/token/refresh", s.handleRefreshToken)
+	mux.HandleFunc("/api/users/password/forgot", s.handleForgotPassword)
+	mux.HandleFunc("/api/users/password/reset", s.handleResetPassword)
+	mux.HandleFunc("/api/users/verify", s.handleVerifyEmail)
+	mux.HandleFunc("/api/users/verify/resend", s.handleResendVerification)
+	mux.HandleFunc("/api/users/oidc/providers", s.handleOIDCProviders)
+	mux.HandleFunc("/api/users/oidc/", s.handleOIDC)
+	mux.HandleFunc("/api/users/current/", s.handleGetLoggedinUser)
+	mux.HandleFunc("/api/users/current", s.handleGetCurrentUser) // this is needed for user data on frontend to be up to date
+	mux.HandleFunc("/api/users/current/update", s.handleUpdateUserData)
+	mux.HandleFunc("/api/users/current/export", s.handleExportAccount)
+	mux.HandleFunc("/api/users/current/activity", s.handleActivity)
+	mux.HandleFunc("/api/users/current/sessions", s.handleGetSessions)
+	mux.HandleFunc("/api/users/current/sessions/", s.handleRevokeSession)
+	mux.HandleFunc("/api/users/current/2fa", s.handleTOTPStatus)
+	mux.HandleFunc("/api/users/current/2fa/setup", s.handleTOTPSetup)
+	mux.HandleFunc("/api/users/current/2fa/confirm", s.handleTOTPConfirm)
+	mux.HandleFunc("/api/users/current/2fa/disable", s.handleTOTPDisable)
+	mux.HandleFunc("/api/users/current/2fa/recovery-codes", s.handleRegenerateRecoveryCodes)
+	mux.HandleFunc("/api/users/current/identities", s.handleGetIdentities)
+	mux.HandleFunc("/api/users/current/identities/", s.handleUnlinkIdentity)
+	mux.HandleFunc("/api/users/current/tokens", s.handleAPITokens)
+	mux.HandleFunc("/api/users/current/tokens/", s.handleRevokeAPIToken)
+	mux.HandleFunc("/api/users/current/login-attempts", s.handleLoginFailures)
+	mux.HandleFunc("/api/admin/users", s.requireAdmin(s.handleAdminUsers))
+	mux.HandleFunc("/api/admin/users/", s.requireAdmin(s.handleAdminUser))
+	mux.HandleFunc("/api/notes/create", s.requireAuth(scopeNotesWrite, s.handleCreateNote))
+	mux.HandleFunc("/api/notes/", s.handleNoteRoutes)
+	mux.HandleFunc("/api/notes/statuses", s.handleNoteStatuses)
+	mux.HandleFunc("/api/interviews/upcoming", s.requireAuth(scopeNotesRead, s.handleUpcomingInterviews))
+	mux.HandleFunc("/api/contacts", s.requireNotesAuth(s.handleContacts))
+	mux.HandleFunc("/api/contacts/{id}", s.requireNotesAuth(s.handleContact))
+	mux.HandleFunc("/api/contacts/{id}/notes", s.requireAuth(scopeNotesRead, s.handleContactNotes))
+	mux.HandleFunc("/api/companies", s.requireNotesAuth(s.handleCompanies))
+	mux.HandleFunc("/api/companies/{id}", s.requireNotesAuth(s.handleCompany))
+	mux.HandleFunc("/api/companies/{id}/aliases", s.requireAuth(scopeNotesWrite, s.handleCompanyAliases))
+	mux.HandleFunc("/api/companies/{id}/aliases/{aliasId}", s.requireAuth(scopeNotesWrite, s.handleCompanyAlias))
+	mux.HandleFunc("/api/tags", s.requireNotesAuth(s.handleTags))
+	mux.HandleFunc("/api/tags/{id}", s.requireAuth(scopeNotesWrite, s.handleTag))
+	mux.HandleFunc("/api/notes/update", s.requireAuth(scopeNotesWrite, s.handleUpdateNote))
+	mux.HandleFunc("/api/notes/current/", s.requireAuth(scopeNotesRead, s.handleGetCurrentNote))
+	mux.HandleFunc("/api/notes/delete/", s.requireAuth(scopeNotesWrite, s.handleDeleteNote))
+
+	return csrfProtect(mux)
+}
 
-	// Marshalling users to JSON
-	data, err := json.Marshal(users)
-	if err != nil {
-		http.Error(w, "Failed to encode users to JSON", http.StatusInternalServerError)
-		log.Printf("Error encoding users to JSON: %s", err)
-		return
+// frontendURL is where the frontend is served from, used for CORS and links in emails
+func frontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return url
 	}
-	w.Header().Set("Content-Type", "application/json") // setting response header to JSON
-	w.Write(data)                                      // sending data
+	return "http://localhost:5173"
+}
+
+func enableCors(w *http.ResponseWriter) {
+	(*w).Header().Set("Access-Control-Allow-Origin", frontendURL())
+	(*w).Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	(*w).Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+csrfHeaderName)
+	(*w).Header().Set("Access-Control-Expose-Headers", csrfHeaderName+", Retry-After")
+	(*w).Header().Set("Access-Control-Allow-Credentials", "true")
 }
 
 type UserFromFrontend struct {
@@ -99,6 +185,13 @@ type UserFromFrontend struct {
 	Password string `json:"password"`
 }
 
+func (req *UserFromFrontend) validate() error {
+	if !validEmail(req.Email) {
+		return errors.New("Invalid email address")
+	}
+	return jaegerpassword.Validate(req.Password, req.Email, req.FullName)
+}
+
 func (s *Server) handleSignupUser(w http.ResponseWriter, r *http.Request) {
 	enableCors(&w)
 
@@ -107,20 +200,24 @@ func (s *Server) handleSignupUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var newUser UserFromFrontend
 	// decoding new user coming from frontend
-	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
-		http.Error(w, "Failed to decode JSON to new user", http.StatusInternalServerError)
-		log.Printf("Error decoding JSON to new user: %s", err)
+	newUser, ok := decodeJSON[UserFromFrontend](w, r, "new user")
+	if !ok {
 		return
 	}
 
 	// adding the new user to the DB
-	if err := jaegerdb.CreateUserJaeger(w, s.dbConn, newUser.FullName, newUser.Email, newUser.Password); err != nil {
-		log.Printf("Error creating the new user and adding it to DB: %s", err)
-		http.Error(w, "Failed to add new user to DB", http.StatusInternalServerError)
+	err := s.users.CreateUserJaeger(r.Context(), newUser.FullName, newUser.Email, newUser.Password)
+	if writeStoreError(w, err, "Failed to add new user to DB", "Error creating the new user and adding it to DB") {
 		return
 	}
+
+	// sending the verification email, the account is created either way
+	if user, err := s.users.GetUserByEmail(r.Context(), newUser.Email); err == nil {
+		if err := s.sendEmailVerification(r.Context(), user); err != nil {
+			log.Printf("Failed sending verification email to %s: %s", newUser.Email, err)
+		}
+	}
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte("User created successfully"))
 
@@ -130,8 +227,18 @@ type LoginData struct {
 	Password string `json:"password"`
 }
 
+func (req *LoginData) validate() error {
+	if req.Password == "" {
+		return errors.New("Password is required")
+	}
+	return nil
+}
+
+// handleLoginUser is the v1 login with the email in the path, kept for older frontends, use handleLoginV2 instead
 func (s *Server) handleLoginUser(w http.ResponseWriter, r *http.Request) {
 	enableCors(&w)
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</api/v2/users/login>; rel="successor-version"`)
 
 	// checking the path
 	path := r.URL.Path
@@ -150,35 +257,79 @@ func (s *Server) handleLoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// TODO: checking the credentials
-	var loginData LoginData // getting the user data from login
-	if err := json.NewDecoder(r.Body).Decode(&loginData); err != nil {
-		http.Error(w, "Failed to decode JSON to login data", http.StatusInternalServerError)
-		log.Printf("Error decoding JSON to login data: %s", err)
+	loginData, ok := decodeJSON[LoginData](w, r, "login") // getting the user data from login
+	if !ok {
 		return
 	}
 
-	// check pw exists
-	if loginData.Password == "" {
-		log.Print("Password missing in request body")
-		http.Error(w, "Password is required", http.StatusBadRequest)
+	s.loginWithPassword(w, r, email, loginData.Password, http.Error)
+}
+
+// loginWithPassword checks the credentials and starts the session or the second login step,
+// writeError formats the errors for the api version, every credential error looks the same so accounts can't be enumerated
+func (s *Server) loginWithPassword(w http.ResponseWriter, r *http.Request, email, password string, writeError func(w http.ResponseWriter, message string, status int)) {
+	// accounts and clients with too many failed attempts have to wait
+	unlock := s.lockLogin(r, email)
+	defer unlock()
+	if s.rejectThrottledLogin(w, r, email, writeError) {
 		return
 	}
 
 	// checking the credentials
-	if err := jaegerdb.CheckCredentialsOnLogin(s.dbConn, email, loginData.Password); err != nil {
+	if err := s.users.CheckCredentialsOnLogin(r.Context(), email, password); err != nil {
 		log.Printf("Credentials don't match the db: %s", err)
-		http.Error(w, "Failed to compare credentials", http.StatusInternalServerError)
+		if errors.Is(err, jaegerdb.ErrInvalidCredentials) {
+			s.recordLoginAttempt(r, email, false)
+			writeError(w, "Invalid email or password", http.StatusUnauthorized)
+			return
+		}
+		writeError(w, "Failed to compare credentials", http.StatusInternalServerError)
 		return
 	}
 
-	token, err := jaegerjwt.GenerateJWT(email) // generating the token
+	user, err := s.users.GetUserByEmail(r.Context(), email)
 	if err != nil {
-		log.Printf("Failed to generate token: %s", err)
-		http.Error(w, "Failed to generate token", http.StatusUnauthorized)
+		log.Printf("Failed retrieving user %s after login: %s", email, err)
+		writeError(w, "Failed to compare credentials", http.StatusInternalServerError)
+		return
+	}
+	if user.Disabled {
+		log.Printf("Login to disabled account %s", email)
+		writeError(w, "Account has been disabled", http.StatusForbidden)
+		return
+	}
+	if user.PasswordResetRequired { // set by an admin, the reset link has been sent by email
+		log.Printf("Login to %s refused until the password is reset", email)
+		writeError(w, "Password reset required, check your email for the reset link", http.StatusForbidden)
 		return
 	}
 
-	jaegerjwt.SetTokenInCookies(w, token) // setting the cookies
+	// users with 2FA get a short lived MFA token instead, the session is started after the second step
+	mfaRequired, err := s.secondFactorRequired(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed checking 2FA for %s: %s", email, err)
+		writeError(w, "Failed to compare credentials", http.StatusInternalServerError)
+		return
+	}
+	if mfaRequired {
+		mfaToken, err := jaegerjwt.GenerateMFAToken(email)
+		if err != nil {
+			log.Printf("Failed to generate MFA token: %s", err)
+			writeError(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]any{"mfaRequired": true, "mfaToken": mfaToken})
+		log.Print("Password accepted, waiting for the second factor")
+		return
+	}
+
+	// generating the access and refresh tokens and setting the cookies
+	if err := s.startSession(w, r, email); err != nil {
+		log.Printf("Failed to generate token: %s", err)
+		writeError(w, "Failed to generate token", http.StatusUnauthorized)
+		return
+	}
+	s.recordLoginAttempt(r, email, true) // only a started session resets the failures, not a password awaiting 2FA
 
 	w.WriteHeader(http.StatusOK) // OK response
 	log.Print("Users successfully logged in!")
@@ -192,51 +343,73 @@ func (s *Server) checkAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// getting the cookie from the request body
-	cookie, err := r.Cookie("authToken")
+	// getting the caller from the cookie or the bearer token
+	caller, err := s.authenticate(r)
 	if err != nil {
 		log.Printf("Authorization unssuccessful :%s", err)
-		http.Error(w, "No cookie - Unauthorized", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	log.Printf("Cookie retrieved: %s = %s", cookie.Name, cookie.Value)
 
-	// validating the token from the cookie
-	token, err := jaegerjwt.ValidateToken(cookie.Value)
-	if err != nil {
-		log.Printf("Invalid token: %s", err)
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	// cookie sessions get their CSRF token here, e.g. after a page reload
+	if !caller.isAPIToken() {
+		if err := ensureCSRFToken(w, r); err != nil {
+			log.Printf("Failed to issue CSRF token: %s", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	// extracting claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := claims["email"].(string)
-		log.Printf("User %s is authenticated", userID)
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Authenticated"))
-	} else {
-		log.Print("User is not authenticated")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-	}
+	log.Printf("User %s is authenticated", caller.User.Email)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Authenticated"))
 }
 
-// TODO: not return OK with an error
 func (s *Server) handleLogoutUser(w http.ResponseWriter, r *http.Request) {
-	jaegerjwt.DeleteCookie(w)            // delete cookie
-	cookie, err := r.Cookie("authToken") // check for cookie to return OK status
-	if err != nil {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Logged out"))
 		return
 	}
 
-	log.Printf("Cookie value after logout = %s", cookie) // if cookie exists
+	// only POST goes through the CSRF check, a GET could be triggered by any page the user visits
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// revoke the session so neither the access token nor the refresh cookie can be used anymore
+	if cookie, err := r.Cookie("refreshToken"); err == nil {
+		userId, jti, err := s.users.RevokeRefreshTokenFamily(r.Context(), jaegerjwt.HashToken(cookie.Value))
+		if err != nil {
+			log.Printf("Failed revoking refresh token on logout: %s", err)
+		} else if userId != 0 {
+			s.audit(r, userId, userId, jaegerdb.AuditLogout, "session:"+jti, "")
+		}
+	} else if caller, err := s.authenticateSession(r); err == nil {
+		if err := s.users.RevokeSession(r.Context(), caller.User.ID, caller.SessionID); err != nil {
+			log.Printf("Failed revoking session on logout: %s", err)
+		} else {
+			s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditLogout, "session:"+caller.SessionID, "")
+		}
+	}
+
+	jaegerjwt.DeleteCookie(w) // delete cookies
+	jaegerjwt.DeleteRefreshCookie(w)
+	deleteCSRFCookie(w)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Logged out"))
 }
 
 func (s *Server) handleGetLoggedinUser(w http.ResponseWriter, r *http.Request) {
 	enableCors(&w)
 
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	// checking the path
 	path := r.URL.Path
 	basePath := "/api/users/current/"
@@ -253,9 +426,19 @@ func (s *Server) handleGetLoggedinUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: get user and send the json to frontend on login
-	user, err := jaegerdb.GetUserByEmail(s.dbConn, email)
-	log.Printf("GetUserByEmail(%s) = user -> %s", email, user) // checking if we have proper data
+	// only the logged in user can be looked up, any other email looks like it doesn't exist
+	caller, err := s.authenticate(r)
+	if err != nil {
+		log.Printf("Unauthorized user lookup: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !strings.EqualFold(email, caller.User.Email) {
+		log.Printf("User %d tried to look up another user", caller.User.ID)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	user := caller.User
 
 	jsonUser, err := json.Marshal(user) // marshalling user
 	if err != nil {
@@ -263,55 +446,34 @@ func (s *Server) handleGetLoggedinUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed marshaling user data to json", http.StatusInternalServerError)
 		return
 	}
-	log.Print(string(jsonUser))
-	w.Write(jsonUser) //sending user
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	w.Write(jsonUser) //sending user
 }
 
 func (s *Server) handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	enableCors(&w)
 
-	// get the cookie from the request
-	cookie, err := r.Cookie("authToken")
-	if err != nil {
-		log.Printf("Missing auth token: %s", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// validate cookie
-	token, err := jaegerjwt.ValidateToken(cookie.Value)
-	if err != nil {
-		log.Printf("Invalid token: %s", err)
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	// get claims from the token
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		log.Print("Token is not valid")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// get email from the claims
-	email, ok := claims["email"].(string)
-	if !ok {
-		log.Print("Email claim is missing or invalid")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if r.Method == http.MethodDelete {
+		s.handleDeleteAccount(w, r)
 		return
 	}
 
-	user, err := jaegerdb.GetUserByEmail(s.dbConn, email)
+	// get the caller from the cookie or the bearer token
+	caller, err := s.authenticate(r)
 	if err != nil {
-		log.Printf("Failed retrieving user: %s", err)
-		http.Error(w, "User not found", http.StatusNotFound)
+		log.Printf("Unauthorized current user request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	// marshal the data and send to frontend
-	jsonUser, err := json.Marshal(user)
+	jsonUser, err := json.Marshal(caller.User)
 	if err != nil {
 		log.Printf("Error marshaling user: %s", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -329,26 +491,81 @@ type UpdatedUserData struct {
 	Password string `json:"password"`
 }
 
+// validate checks what it can without the user, the password rules also look at the current name and email
+func (req *UpdatedUserData) validate() error {
+	if req.Email != "" && !validEmail(req.Email) {
+		return errors.New("Invalid email address")
+	}
+	return nil
+}
+
 func (s *Server) handleUpdateUserData(w http.ResponseWriter, r *http.Request) {
 	enableCors(&w)
 
-	var updatedUser UpdatedUserData // decode the updates comming from frontend
-	if err := json.NewDecoder(r.Body).Decode(&updatedUser); err != nil {
-		http.Error(w, "Failed to decode JSON to updated user", http.StatusInternalServerError)
-		log.Printf("Error decoding JSON to updated user: %s", err)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	// the audit log needs to know who made the change, so only the logged in user can update their own data
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized user update request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updatedUser, ok := decodeJSON[UpdatedUserData](w, r, "updated user") // decode the updates comming from frontend
+	if !ok {
+		return
+	}
+	if updatedUser.ID != 0 && updatedUser.ID != caller.User.ID {
+		log.Printf("User %d tried to update user %d", caller.User.ID, updatedUser.ID)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	updatedUser.ID = caller.User.ID
+
 	// TODO: update user here (UpdateUser)
-	log.Printf("func handleUpdateUserData -> updated user info from that came in:\nID: %d\nFullName: %s\nEmail: %s\nPassword: %s\n", updatedUser.ID, updatedUser.FullName, updatedUser.Email, updatedUser.Password)
+	log.Printf("func handleUpdateUserData -> updated user info from that came in:\nID: %d\nFullName: %s\nEmail: %s\nPassword changed: %t\n", updatedUser.ID, updatedUser.FullName, updatedUser.Email, updatedUser.Password != "")
 
-	updatedData, err := jaegerdb.UpdateUser(s.dbConn, updatedUser.ID, updatedUser.FullName, updatedUser.Email, updatedUser.Password)
+	if updatedUser.Password != "" {
+		// the current email and name count too, a request that only changes the password doesn't send them
+		if err := jaegerpassword.Validate(updatedUser.Password, updatedUser.Email, updatedUser.FullName, caller.User.Email, caller.User.FullName); err != nil {
+			log.Printf("Password rejected on update: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	updatedData, err := s.users.UpdateUser(r.Context(), updatedUser.ID, updatedUser.FullName, updatedUser.Email, updatedUser.Password)
 	if err != nil {
 		log.Printf("UpdateUser -> couldn't update: %s", err)
 		http.Error(w, "Failed updating user data", http.StatusInternalServerError)
 		return
 	}
 
+	var changed []string
+	if updatedUser.FullName != "" {
+		changed = append(changed, "fullName")
+	}
+	if updatedUser.Email != "" {
+		changed = append(changed, "email")
+	}
+	if updatedUser.Password != "" {
+		changed = append(changed, "password")
+	}
+	s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditUserUpdate, "user:"+strconv.Itoa(caller.User.ID), strings.Join(changed, ","))
+
+	// a changed email has to be verified again
+	if updatedUser.Email != "" {
+		if user, err := s.users.GetUserByEmail(r.Context(), updatedUser.Email); err == nil && !user.Verified {
+			if err := s.sendEmailVerification(r.Context(), user); err != nil {
+				log.Printf("Failed sending verification email to %s: %s", user.Email, err)
+			}
+		}
+	}
+
 	jsonUpdatedData, err := json.Marshal(updatedData)
 	if err != nil {
 		log.Printf("Failed marshaling updated user data to json: %s", err)
@@ -356,8 +573,9 @@ func (s *Server) handleUpdateUserData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Write(jsonUpdatedData)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK) // OK response
+	w.Write(jsonUpdatedData)
 	log.Print("Users information successfully updated!")
 }
 
@@ -373,23 +591,36 @@ type Note struct {
 	UserId            int    `json:"userId"`
 }
 
+func (req *Note) validate() error {
+	req.CompanyName = strings.TrimSpace(req.CompanyName)
+	if req.CompanyName == "" {
+		return errors.New("Company name is required")
+	}
+	return nil
+}
+
 func (s *Server) handleCreateNote(w http.ResponseWriter, r *http.Request) {
-	enableCors(&w)
+	caller := callerFromContext(r)
 
-	var noteData Note
-	if err := json.NewDecoder(r.Body).Decode(&noteData); err != nil {
-		log.Printf("Failed to decode note data: %s", err)
-		http.Error(w, "Failed to decode note data", http.StatusInternalServerError)
+	noteData, ok := decodeJSON[Note](w, r, "note")
+	if !ok {
 		return
 	}
+	noteData.UserId = caller.User.ID // notes are always created for the caller
 
 	log.Printf("\n*****INCOMMING NOTE*****\nfunc handleCreateNote -> note data that came in from the frontend:\nUUID: %s\nCompanyName: %s\nPosition: %s\nSalary: %s\nApplicationStatus: %s\nAppliedOn: %s\nDescription: %s\nUser ID: %d\n*****END Incomming NOTE*****", noteData.UUID, noteData.CompanyName, noteData.Position, noteData.Salary, noteData.ApplicationStatus, noteData.AppliedOn, noteData.Description, noteData.UserId)
 
-	if err := jaegerdb.CreateNote(s.dbConn, noteData.UUID, noteData.CompanyName, noteData.Position, noteData.Salary, noteData.ApplicationStatus, noteData.AppliedOn, noteData.Description, noteData.UserId); err != nil {
-		log.Printf("Failed creating Note: %s", err)
-		http.Error(w, "Failed creating Note", http.StatusInternalServerError)
+	if s.requireVerifiedEmail && !caller.User.Verified {
+		http.Error(w, "Verify your email address before creating notes", http.StatusForbidden)
+		return
 	}
 
+	noteId, err := s.notes.CreateNote(r.Context(), noteData.UUID, noteData.CompanyName, noteData.Position, noteData.Salary, noteData.ApplicationStatus, noteData.AppliedOn, noteData.Description, noteData.UserId)
+	if writeStoreError(w, err, "Failed creating Note", "Failed creating Note") {
+		return
+	}
+	s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditNoteCreate, "note:"+strconv.Itoa(noteId), noteData.CompanyName)
+
 	/*
 		newNote.uuid, newNote.companyName, newNote.position, newNote.salary, newNote.applicationStatus, newNote.appliedOn, newNote.description, newNote.userId
 	*/
@@ -397,9 +628,59 @@ func (s *Server) handleCreateNote(w http.ResponseWriter, r *http.Request) {
 	log.Print("Note created successfully!")
 }
 
+// handleNoteRoutes serves /api/notes/{id}/{resource}[/{resourceId}], anything without a resource is the notes listing
+// the mux can't take these as patterns, they'd overlap with /api/notes/current/ and /api/notes/delete/
+func (s *Server) handleNoteRoutes(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/notes/")
+	id, rest, found := strings.Cut(rest, "/")
+	if !found {
+		s.requireAuth(scopeNotesRead, s.handleGetUserNotes)(w, r)
+		return
+	}
+	r.SetPathValue("id", id)
+	resource, resourceId, _ := strings.Cut(rest, "/")
+
+	switch {
+	case resource == "timeline" && resourceId == "":
+		s.requireAuth(scopeNotesRead, s.handleNoteTimeline)(w, r)
+	case resource == "interviews" && resourceId == "":
+		s.requireAuth(noteScope(r), s.handleNoteInterviews)(w, r)
+	case resource == "interviews":
+		r.SetPathValue("interviewId", resourceId)
+		s.requireAuth(noteScope(r), s.handleNoteInterview)(w, r)
+	case resource == "contacts" && resourceId == "":
+		s.requireAuth(scopeNotesRead, s.handleNoteContacts)(w, r)
+	case resource == "contacts":
+		r.SetPathValue("contactId", resourceId)
+		s.requireAuth(noteScope(r), s.handleNoteContact)(w, r)
+	case resource == "tags" && resourceId == "":
+		s.requireAuth(scopeNotesRead, s.handleNoteTags)(w, r)
+	case resource == "tags":
+		r.SetPathValue("tagId", resourceId)
+		s.requireAuth(noteScope(r), s.handleNoteTag)(w, r)
+	default:
+		enableCors(&w)
+		http.NotFound(w, r)
+	}
+}
+
+// noteScope is the scope an API token needs for the request, reading or changing notes
+func noteScope(r *http.Request) string {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return scopeNotesRead
+	}
+	return scopeNotesWrite
+}
+
+// requireNotesAuth is requireAuth with the scope picked by noteScope, for routes that both read and change
+func (s *Server) requireNotesAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.requireAuth(noteScope(r), next)(w, r)
+	}
+}
+
 func (s *Server) handleGetUserNotes(w http.ResponseWriter, r *http.Request) {
-	// TODO: extract id from url and pass it to getAllUserNotes DB func
-	enableCors(&w)
+	caller := callerFromContext(r)
 
 	// checking the path
 	path := r.URL.Path
@@ -410,26 +691,34 @@ func (s *Server) handleGetUserNotes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the id in the path is optional, if it's there it has to be the caller's
 	id := strings.TrimPrefix(path, basePath) // extracing the id from the path
-	if id == "" {
-		log.Printf("ID missing in the url request: %s", id)
-		http.Error(w, "ID is required", http.StatusBadRequest)
-		return
-	}
-
-	intId, err := strconv.Atoi(id)
+	if id != "" {
+		intId, err := strconv.Atoi(id)
+		if err != nil {
+			log.Printf("Invalid ID format: %s", id)
+			http.Error(w, "Invalid ID format", http.StatusBadRequest)
+			return
+		}
+		if intId != caller.User.ID {
+			log.Printf("User %d requested notes of user %d", caller.User.ID, intId)
+			http.Error(w, "Notes not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	// ?tags=1,2&tags=3 lists the notes tagged (1 or 2) and 3
+	filter, err := parseNoteFilter(r.URL.Query())
 	if err != nil {
-		log.Printf("Invalid ID format: %s", id)
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	userNotes, err := jaegerdb.GetAllUserNotes(s.dbConn, intId)
-	// DEBUG: notes sent as null
-	log.Printf("Notes sent to frontend %s", userNotes)
+	userNotes, err := s.notes.GetAllUserNotes(r.Context(), caller.User.ID, filter)
 	if err != nil {
 		log.Printf("Failed to retrieve user notes: %s", err)
 		http.Error(w, "Failed to retrieve user notes!", http.StatusInternalServerError)
+		return
 	}
 
 	if userNotes == nil {
@@ -440,6 +729,7 @@ func (s *Server) handleGetUserNotes(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("Failed to marshal user notes: %s", err)
 		http.Error(w, "Failed to marshal user notes!", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -459,29 +749,36 @@ type updatedNote struct {
 	NoteId            int    `json:"noteId"`
 }
 
+func (req *updatedNote) validate() error {
+	if req.NoteId == 0 {
+		return errors.New("Note ID is required")
+	}
+	return nil
+}
+
 func (s *Server) handleUpdateNote(w http.ResponseWriter, r *http.Request) {
-	enableCors(&w)
+	caller := callerFromContext(r)
 
-	var updatedNoteData updatedNote
-	if err := json.NewDecoder(r.Body).Decode(&updatedNoteData); err != nil {
-		log.Printf("Error decoding updated note data")
-		http.Error(w, "Failed decoding updated note data", http.StatusInternalServerError)
+	updatedNoteData, ok := decodeJSON[updatedNote](w, r, "updated note")
+	if !ok {
+		return
 	}
+	updatedNoteData.UserId = caller.User.ID
 
 	log.Printf("\n*****INCOMMING UPDATED NOTE*****\nfunc handleUpdateNote -> updated note data that came in from the frontend:\nCompanyName: %s\nPosition: %s\nSalary: %s\nApplicationStatus: %s\nAppliedOn: %s\nDescription: %s\nUser ID: %d\nNote ID: %d\n*****END Incomming NOTE*****", updatedNoteData.CompanyName, updatedNoteData.Position, updatedNoteData.Salary, updatedNoteData.ApplicationStatus, updatedNoteData.AppliedOn, updatedNoteData.Description, updatedNoteData.UserId, updatedNoteData.NoteId)
 
 	// NOTE: testing
-	if err := jaegerdb.UpdateNote(s.dbConn, updatedNoteData.NoteId, updatedNoteData.CompanyName, updatedNoteData.Position, updatedNoteData.Salary, updatedNoteData.ApplicationStatus, updatedNoteData.AppliedOn, updatedNoteData.Description); err != nil {
-		log.Printf("Error updating the Note in DB: %s", err)
-		http.Error(w, "Error updating the Note in DB", http.StatusInternalServerError)
-	} else {
-		log.Printf("Note updated successfully")
-		w.WriteHeader(http.StatusOK)
+	err := s.notes.UpdateNote(r.Context(), caller.User.ID, updatedNoteData.NoteId, updatedNoteData.CompanyName, updatedNoteData.Position, updatedNoteData.Salary, updatedNoteData.ApplicationStatus, updatedNoteData.AppliedOn, updatedNoteData.Description)
+	if writeStoreError(w, err, "Error updating the Note in DB", "Error updating the Note in DB") {
+		return
 	}
+	s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditNoteUpdate, "note:"+strconv.Itoa(updatedNoteData.NoteId), "")
+	log.Printf("Note updated successfully")
+	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) handleGetCurrentNote(w http.ResponseWriter, r *http.Request) {
-	enableCors(&w)
+	caller := callerFromContext(r)
 
 	path := r.URL.Path
 	basePath := "/api/notes/current/"
@@ -505,20 +802,24 @@ func (s *Server) handleGetCurrentNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	note := jaegerdb.GetUpdatedNote(s.dbConn, intId)
+	note, err := s.notes.GetUpdatedNote(r.Context(), caller.User.ID, intId)
+	if writeStoreError(w, err, "Failed retrieving note", "Failed retrieving note %d", intId) {
+		return
+	}
 	jsonUpdatedNote, err := json.Marshal(note)
 	if err != nil {
 		log.Printf("Failed marshaling updated note data to json: %s", err)
 		http.Error(w, "Failed marshaling updated note data to json", http.StatusInternalServerError)
 		return
 	}
-	w.Write(jsonUpdatedNote)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	w.Write(jsonUpdatedNote)
 	log.Print("Note data successfully updated!")
 }
 
 func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
-	enableCors(&w)
+	caller := callerFromContext(r)
 
 	path := r.URL.Path
 	basePath := "/api/notes/delete/"
@@ -542,9 +843,10 @@ func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := jaegerdb.DeleteNote(s.dbConn, intId); err != nil {
-		log.Printf("Issues deleting note from DB: %s", err)
-		http.Error(w, "Unable to delete note from DB", http.StatusInternalServerError)
+	err = s.notes.DeleteNote(r.Context(), caller.User.ID, intId)
+	if writeStoreError(w, err, "Unable to delete note from DB", "Issues deleting note from DB") {
+		return
 	}
+	s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditNoteDelete, "note:"+id, "")
 	w.WriteHeader(http.StatusOK)
 }
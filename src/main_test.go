@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+func TestSignup(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t)
+
+	c.expect(http.MethodPost, "/api/users/signup", UserFromFrontend{FullName: "Ada", Email: "ada@example.com", Password: testPassword}, http.StatusCreated)
+	c.expect(http.MethodPost, "/api/users/signup", UserFromFrontend{FullName: "Ada", Email: "ada@example.com", Password: testPassword}, http.StatusConflict)
+	c.expect(http.MethodPost, "/api/users/signup", UserFromFrontend{FullName: "Bob", Email: "not an email", Password: testPassword}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/signup", UserFromFrontend{FullName: "Bob", Email: "bob@example.com", Password: "short"}, http.StatusBadRequest)
+
+	if user := ts.user(t, "ada@example.com"); user.Verified || user.Role != jaegerdb.RoleUser {
+		t.Errorf("new user verified=%t role=%q, want unverified %q", user.Verified, user.Role, jaegerdb.RoleUser)
+	}
+	if len(ts.mails(t, "ada@example.com")) != 1 {
+		t.Errorf("no verification mail sent on signup")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup(t, "ada@example.com")
+
+	c := ts.client(t)
+	c.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+	c.expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "ada@example.com", Password: "wrong password"}, http.StatusUnauthorized)
+	c.expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "nobody@example.com", Password: testPassword}, http.StatusUnauthorized)
+	if len(c.cookies) != 0 {
+		t.Fatalf("failed logins set cookies %v", c.cookies)
+	}
+
+	c.login("ada@example.com", testPassword)
+	for _, name := range []string{"authToken", "refreshToken", csrfCookieName} {
+		if c.cookies[name] == "" {
+			t.Errorf("login didn't set the %s cookie", name)
+		}
+	}
+
+	rec := c.expect(http.MethodGet, "/api/users/current", nil, http.StatusOK)
+	if user := decodeResponse[jaegerdb.RetrievedUser](t, rec); user.Email != "ada@example.com" {
+		t.Errorf("current user = %q, want ada@example.com", user.Email)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	accessToken := c.cookies["authToken"]
+
+	c.expect(http.MethodPost, "/api/users/logout", nil, http.StatusOK)
+
+	// the access token hasn't expired yet but its session is gone
+	stolen := ts.client(t)
+	stolen.cookies["authToken"] = accessToken
+	stolen.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+}
+
+func TestLogoutNeedsPost(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	// an <img src> on another site sends a GET with the cookies, it mustn't end the session
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut} {
+		c.do(method, "/api/users/logout", nil)
+	}
+	c.expect(http.MethodGet, "/api/users/logout", nil, http.StatusMethodNotAllowed)
+	c.expect(http.MethodGet, "/api/users/current", nil, http.StatusOK)
+	c.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusOK)
+}
+
+func TestLookupLoggedinUser(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	ts.signup(t, "bob@example.com")
+
+	// the user is answered as json and isn't dumped into the logs
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(io.Discard) })
+	rec := c.expect(http.MethodGet, "/api/users/current/Ada@example.com", nil, http.StatusOK)
+	if user := decodeResponse[jaegerdb.RetrievedUser](t, rec); user.Email != "ada@example.com" || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("lookup = %+v as %q", user, rec.Header().Get("Content-Type"))
+	}
+	if strings.Contains(logs.String(), "ada@example.com") {
+		t.Errorf("the user is in the logs: %s", logs.String())
+	}
+
+	c.expect(http.MethodGet, "/api/users/current/bob@example.com", nil, http.StatusNotFound)
+	ts.client(t).expect(http.MethodGet, "/api/users/current/ada@example.com", nil, http.StatusUnauthorized)
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	first := c.cookies["refreshToken"]
+
+	c.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusOK)
+	second := c.cookies["refreshToken"]
+	if second == "" || second == first {
+		t.Fatalf("refresh didn't rotate the refresh token")
+	}
+	c.expect(http.MethodGet, "/api/users/current", nil, http.StatusOK)
+
+	// replaying the old token revokes the whole family, the legitimate client is logged out too
+	replay := ts.client(t)
+	replay.cookies["refreshToken"] = first
+	replay.csrf, replay.cookies[csrfCookieName] = c.csrf, c.csrf
+	replay.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+
+	c.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+	c.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	user := ts.user(t, "ada@example.com")
+
+	// a token of the same family that expired a minute ago
+	expired, expiredHash, err := jaegerjwt.GenerateRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jti, err := jaegerjwt.NewTokenID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.store.CreateRefreshToken(t.Context(), user.ID, jti, expiredHash, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.cookies["refreshToken"] = expired
+	c.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+	if c.cookies["refreshToken"] != "" || c.cookies["authToken"] != "" {
+		t.Errorf("rejected refresh left cookies %v", c.cookies)
+	}
+}
+
+func TestRefreshAfterFamilyRevoked(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(ts *testServer, c *testClient)
+	}{
+		{"logout", func(ts *testServer, c *testClient) {
+			c.expect(http.MethodPost, "/api/users/logout", nil, http.StatusOK)
+		}},
+		{"all sessions revoked", func(ts *testServer, c *testClient) {
+			if err := ts.store.RevokeAllSessions(c.t.Context(), ts.user(c.t, "ada@example.com").ID); err != nil {
+				c.t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			c := ts.signup(t, "ada@example.com")
+			c.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusOK)
+
+			// the latest token of the family hasn't been used, it's still refused once the family is revoked
+			stolen := ts.client(t)
+			stolen.cookies["refreshToken"] = c.cookies["refreshToken"]
+			stolen.cookies["authToken"] = c.cookies["authToken"]
+			stolen.csrf, stolen.cookies[csrfCookieName] = c.csrf, c.csrf
+
+			tt.revoke(ts, c)
+			stolen.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+			stolen.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestCSRFRequired(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	csrf := c.csrf
+	c.csrf = ""
+	c.expect(http.MethodPost, "/api/notes/create", Note{CompanyName: "Acme", AppliedOn: "2026-09-01"}, http.StatusForbidden)
+	c.csrf = csrf
+	c.expect(http.MethodPost, "/api/notes/create", Note{CompanyName: "Acme", AppliedOn: "2026-09-01"}, http.StatusOK)
+}
+
+// listNotes returns the caller's notes
+func (c *testClient) listNotes() []jaegerdb.NoteDB {
+	c.t.Helper()
+	return decodeResponse[[]jaegerdb.NoteDB](c.t, c.expect(http.MethodGet, "/api/notes/", nil, http.StatusOK))
+}
+
+// createNote creates a note at the company and returns it
+func (c *testClient) createNote(company string) jaegerdb.NoteDB {
+	c.t.Helper()
+	c.expect(http.MethodPost, "/api/notes/create", Note{CompanyName: company, Position: "Engineer", AppliedOn: "2026-09-01"}, http.StatusOK)
+	for _, note := range c.listNotes() {
+		if note.CompanyName == company {
+			return note
+		}
+	}
+	c.t.Fatalf("note for %s not in the listing", company)
+	return jaegerdb.NoteDB{}
+}
+
+func TestNotesCRUD(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	if notes := c.listNotes(); len(notes) != 0 {
+		t.Fatalf("new user has %d notes", len(notes))
+	}
+
+	note := c.createNote("Acme")
+	if note.ApplicationStatus != jaegerdb.StatusApplied {
+		t.Errorf("new note status = %q, want %q", note.ApplicationStatus, jaegerdb.StatusApplied)
+	}
+	id := strconv.Itoa(note.Id)
+
+	// the frontend sends the whole note on update
+	update := updatedNote{NoteId: note.Id, CompanyName: "Acme", Position: "Staff Engineer", ApplicationStatus: jaegerdb.StatusInterview, AppliedOn: "2026-09-01"}
+	c.expect(http.MethodPut, "/api/notes/update", update, http.StatusOK)
+	got := decodeResponse[jaegerdb.NoteDB](t, c.expect(http.MethodGet, "/api/notes/current/"+id, nil, http.StatusOK))
+	if got.Position != "Staff Engineer" || got.ApplicationStatus != jaegerdb.StatusInterview || got.CompanyName != "Acme" || got.AppliedOn[:10] != "2026-09-01" {
+		t.Errorf("updated note = %+v", got)
+	}
+
+	update.ApplicationStatus = jaegerdb.StatusApplied
+	c.expect(http.MethodPut, "/api/notes/update", update, http.StatusConflict)
+	update.ApplicationStatus = "ghosted"
+	c.expect(http.MethodPut, "/api/notes/update", update, http.StatusBadRequest)
+
+	// bodies that can't be a note are the client's fault
+	c.expect(http.MethodPost, "/api/notes/create", "Acme", http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/notes/create", Note{CompanyName: "  ", AppliedOn: "2026-09-01"}, http.StatusBadRequest)
+	c.expect(http.MethodPut, "/api/notes/update", updatedNote{Position: "Staff Engineer"}, http.StatusBadRequest)
+
+	c.expect(http.MethodDelete, "/api/notes/delete/"+id, nil, http.StatusOK)
+	c.expect(http.MethodGet, "/api/notes/current/"+id, nil, http.StatusNotFound)
+	if notes := c.listNotes(); len(notes) != 0 {
+		t.Errorf("%d notes left after deleting", len(notes))
+	}
+}
+
+func TestNotesOwnership(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+
+	note := ada.createNote("Acme")
+	id := strconv.Itoa(note.Id)
+	adaId := strconv.Itoa(ts.user(t, "ada@example.com").ID)
+
+	bob.expect(http.MethodGet, "/api/notes/"+adaId, nil, http.StatusNotFound)
+	bob.expect(http.MethodGet, "/api/notes/current/"+id, nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, "/api/notes/update", updatedNote{NoteId: note.Id, Position: "Hijacked"}, http.StatusNotFound)
+	bob.expect(http.MethodDelete, "/api/notes/delete/"+id, nil, http.StatusNotFound)
+	bob.expect(http.MethodGet, "/api/notes/"+id+"/timeline", nil, http.StatusNotFound)
+	if notes := bob.listNotes(); len(notes) != 0 {
+		t.Errorf("bob sees %d notes of ada", len(notes))
+	}
+
+	// a note created with someone else's user id still belongs to the caller
+	bob.expect(http.MethodPost, "/api/notes/create", Note{CompanyName: "Globex", AppliedOn: "2026-09-01", UserId: ts.user(t, "ada@example.com").ID}, http.StatusOK)
+
+	notes := ada.listNotes()
+	if len(notes) != 1 || notes[0].Position != "Engineer" {
+		t.Errorf("ada's notes after bob's attempts = %+v", notes)
+	}
+	ada.expect(http.MethodGet, "/api/notes/"+adaId, nil, http.StatusOK)
+	ts.client(t).expect(http.MethodGet, "/api/notes/", nil, http.StatusUnauthorized)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+const migrateUsage = "usage: jaeger migrate up | down [steps] | status"
+
+// runMigrateCommand handles `migrate up`, `migrate down [steps]` and `migrate status`, output goes to the terminal instead of the log file
+func runMigrateCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, migrateUsage)
+		return 2
+	}
+
+	store, err := jaegerdb.OpenStore()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer store.Close()
+	migrator, ok := store.(jaegerdb.Migrator)
+	if !ok {
+		fmt.Fprintln(stderr, "the configured store has no migrations")
+		return 1
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.MigrateUp(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(stdout, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(stdout, "no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		reverted, err := migrator.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			fmt.Fprintf(stdout, "reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	case "status":
+		status, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, m := range status {
+			appliedAt := "pending"
+			if m.AppliedAt != nil {
+				appliedAt = m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(stdout, "%04d_%-25s %s\n", m.Version, m.Name, appliedAt)
+		}
+	default:
+		fmt.Fprintln(stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateCommand(t *testing.T) {
+	t.Setenv("DB_STORE", "sqlite")
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "jaeger.db"))
+
+	migrate := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runMigrateCommand(args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, out, _ := migrate("status")
+	if code != 0 || !strings.Contains(out, "0001_") || strings.Count(out, "pending") != strings.Count(out, "\n") {
+		t.Fatalf("status of a new database = %d\n%s", code, out)
+	}
+	migrations := strings.Count(out, "\n")
+
+	code, out, _ = migrate("up")
+	if code != 0 || strings.Count(out, "applied ") != migrations || !strings.HasPrefix(out, "applied 0001_") {
+		t.Fatalf("up = %d\n%s", code, out)
+	}
+	if code, out, _ = migrate("up"); code != 0 || out != "no pending migrations\n" {
+		t.Errorf("second up = %d\n%s", code, out)
+	}
+
+	code, out, _ = migrate("down", "2")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if code != 0 || len(lines) != 2 || !strings.HasPrefix(lines[0], "reverted ") || lines[0] < lines[1] {
+		t.Fatalf("down 2 = %d\n%s", code, out)
+	}
+	if _, out, _ = migrate("status"); strings.Count(out, "pending") != 2 {
+		t.Errorf("status after down 2:\n%s", out)
+	}
+	if code, out, _ = migrate("down"); code != 0 || strings.Count(out, "reverted ") != 1 {
+		t.Errorf("down = %d\n%s", code, out)
+	}
+
+	for _, args := range [][]string{{}, {"sideways"}, {"down", "0"}, {"down", "two"}} {
+		code, out, errOut := migrate(args...)
+		if code != 2 || out != "" || !strings.Contains(errOut, "usage:") {
+			t.Errorf("migrate %v = %d, %q, %q, want the usage and 2", args, code, out, errOut)
+		}
+	}
+
+	t.Setenv("DB_STORE", "memory")
+	if code, _, errOut := migrate("up"); code != 1 || !strings.Contains(errOut, "no migrations") {
+		t.Errorf("up on the memory store = %d, %q", code, errOut)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// handleNoteStatuses lists the application statuses and where each of them can go next, so the frontend can offer only valid changes
+func (s *Server) handleNoteStatuses(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	statuses := jaegerdb.NoteStatuses()
+	transitions := map[string][]string{}
+	for _, status := range statuses {
+		transitions[status] = jaegerdb.NextStatuses(status)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"statuses": statuses, "transitions": transitions})
+}
+
+// handleNoteTimeline serves GET /api/notes/{id}/timeline, the status changes of the note oldest first
+func (s *Server) handleNoteTimeline(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	noteId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	note, err := s.notes.GetUpdatedNote(r.Context(), caller.User.ID, noteId)
+	if writeStoreError(w, err, "Failed retrieving note", "Failed retrieving note %d", noteId) {
+		return
+	}
+
+	timeline, err := s.notes.GetNoteStatusHistory(r.Context(), caller.User.ID, noteId) // the note can be deleted in the meantime
+	if writeStoreError(w, err, "Failed retrieving the timeline", "Failed retrieving the timeline of note %d", noteId) {
+		return
+	}
+	if timeline == nil {
+		timeline = []jaegerdb.NoteStatusChangeDB{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"noteId":       noteId,
+		"status":       note.ApplicationStatus,
+		"nextStatuses": jaegerdb.NextStatuses(note.ApplicationStatus),
+		"timeline":     timeline,
+	})
+}
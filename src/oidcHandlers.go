@@ -0,0 +1,298 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/MGavranovic/jaeger-backend/src/jaegeroidc"
+)
+
+func (s *Server) handleOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	names := []string{}
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+// handleOIDC serves /api/users/oidc/{provider}/login and /api/users/oidc/{provider}/callback
+func (s *Server) handleOIDC(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	rest := strings.TrimPrefix(r.URL.Path, "/api/users/oidc/")
+	name, action, found := strings.Cut(rest, "/")
+	if !found {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	provider, ok := s.oidcProviders[name]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "login":
+		s.handleOIDCLogin(w, r, provider)
+	case "callback":
+		s.handleOIDCCallback(w, r, provider)
+	default:
+		http.Error(w, "Invalid URL", http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request, provider *jaegeroidc.Provider) {
+	state, err := jaegeroidc.RandomString()
+	if err != nil {
+		log.Printf("Failed generating OIDC state: %s", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := jaegeroidc.RandomString()
+	if err != nil {
+		log.Printf("Failed generating OIDC nonce: %s", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := jaegeroidc.NewPKCE()
+	if err != nil {
+		log.Printf("Failed generating PKCE verifier: %s", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		log.Printf("Failed building authorization URL for %s: %s", provider.Name, err)
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	stateToken, err := jaegerjwt.GenerateOIDCStateToken(jaegerjwt.OIDCState{
+		Provider:     provider.Name,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		log.Printf("Failed generating OIDC state token: %s", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	jaegerjwt.SetOIDCStateCookie(w, stateToken)
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request, provider *jaegeroidc.Provider) {
+	jaegerjwt.DeleteOIDCStateCookie(w) // the state can only be used once
+
+	cookie, err := r.Cookie("oidcState")
+	if err != nil {
+		log.Printf("OIDC callback without state cookie: %s", err)
+		s.redirectOIDCError(w, r, "login_expired")
+		return
+	}
+	oidcState, err := jaegerjwt.ValidateOIDCStateToken(cookie.Value)
+	if err != nil {
+		log.Printf("Invalid OIDC state cookie: %s", err)
+		s.redirectOIDCError(w, r, "login_expired")
+		return
+	}
+
+	query := r.URL.Query()
+	if oidcState.Provider != provider.Name || subtle.ConstantTimeCompare([]byte(oidcState.State), []byte(query.Get("state"))) != 1 {
+		log.Printf("OIDC state mismatch for provider %s", provider.Name)
+		s.redirectOIDCError(w, r, "invalid_state")
+		return
+	}
+	if idpError := query.Get("error"); idpError != "" {
+		log.Printf("Identity provider %s returned an error: %s %s", provider.Name, idpError, query.Get("error_description"))
+		s.redirectOIDCError(w, r, "provider_error")
+		return
+	}
+
+	tokens, err := provider.Exchange(r.Context(), query.Get("code"), oidcState.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange with %s failed: %s", provider.Name, err)
+		s.redirectOIDCError(w, r, "provider_error")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), tokens.IDToken, oidcState.Nonce)
+	if err != nil {
+		log.Printf("Invalid ID token from %s: %s", provider.Name, err)
+		s.redirectOIDCError(w, r, "invalid_token")
+		return
+	}
+
+	email, err := s.resolveOIDCUser(r, provider.Name, claims)
+	if err != nil {
+		log.Printf("Failed resolving user for %s subject %s: %s", provider.Name, claims.Subject, err)
+		s.redirectOIDCError(w, r, "account_not_linked")
+		return
+	}
+
+	// users with 2FA still have to pass the second step
+	mfaRequired, err := s.secondFactorRequired(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed checking 2FA for %s: %s", email, err)
+		s.redirectOIDCError(w, r, "server_error")
+		return
+	}
+	if mfaRequired {
+		mfaToken, err := jaegerjwt.GenerateMFAToken(email)
+		if err != nil {
+			log.Printf("Failed to generate MFA token: %s", err)
+			s.redirectOIDCError(w, r, "server_error")
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("%s/login/2fa?mfaToken=%s", frontendURL(), url.QueryEscape(mfaToken)), http.StatusSeeOther)
+		return
+	}
+
+	if err := s.startSession(w, r, email); err != nil {
+		log.Printf("Failed to generate token: %s", err)
+		if errors.Is(err, errAccountDisabled) {
+			s.redirectOIDCError(w, r, "account_disabled")
+			return
+		}
+		if errors.Is(err, errPasswordResetRequired) {
+			s.redirectOIDCError(w, r, "password_reset_required")
+			return
+		}
+		s.redirectOIDCError(w, r, "server_error")
+		return
+	}
+
+	log.Printf("User %s logged in with %s", email, provider.Name)
+	http.Redirect(w, r, frontendURL(), http.StatusSeeOther)
+}
+
+// resolveOIDCUser finds the user linked to the identity, linking or creating one if needed, and returns its email
+func (s *Server) resolveOIDCUser(r *http.Request, providerName string, claims *jaegeroidc.IDTokenClaims) (string, error) {
+	email, err := s.users.GetUserByIdentity(r.Context(), providerName, claims.Subject)
+	if err == nil {
+		return email, nil
+	}
+	if !errors.Is(err, jaegerdb.ErrIdentityNotFound) {
+		return "", err
+	}
+
+	// a logged in user is linking another way to log in
+	if caller, err := s.authenticateSession(r); err == nil {
+		if err := s.users.LinkIdentity(r.Context(), caller.User.ID, providerName, claims.Subject, claims.Email); err != nil {
+			return "", err
+		}
+		return caller.User.Email, nil
+	}
+
+	if claims.Email == "" {
+		return "", fmt.Errorf("identity provider didn't return an email")
+	}
+
+	existing, err := s.users.GetUserByEmail(r.Context(), claims.Email)
+	if err == nil {
+		// only verified emails are trusted to take over an existing account
+		if !claims.EmailVerified {
+			return "", fmt.Errorf("email %s is not verified by the identity provider", claims.Email)
+		}
+		if err := s.users.LinkIdentity(r.Context(), existing.ID, providerName, claims.Subject, claims.Email); err != nil {
+			return "", err
+		}
+		return existing.Email, nil
+	}
+	if !errors.Is(err, jaegerdb.ErrUserNotFound) {
+		return "", err
+	}
+
+	fullName := claims.Name
+	if fullName == "" {
+		fullName = claims.Email
+	}
+	if err := s.users.CreateExternalUser(r.Context(), fullName, claims.Email, claims.EmailVerified, providerName, claims.Subject); err != nil {
+		return "", err
+	}
+	return claims.Email, nil
+}
+
+func (s *Server) redirectOIDCError(w http.ResponseWriter, r *http.Request, reason string) {
+	http.Redirect(w, r, fmt.Sprintf("%s/login?error=%s", frontendURL(), url.QueryEscape(reason)), http.StatusSeeOther)
+}
+
+func (s *Server) handleGetIdentities(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized identities request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := caller.User
+
+	identities, err := s.users.GetUserIdentities(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed retrieving identities of user %d: %s", user.ID, err)
+		http.Error(w, "Failed to retrieve identities", http.StatusInternalServerError)
+		return
+	}
+	if identities == nil {
+		identities = []jaegerdb.IdentityDB{}
+	}
+	writeJSON(w, http.StatusOK, identities)
+}
+
+func (s *Server) handleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized unlink request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := caller.User
+
+	identityId, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/users/current/identities/"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	err = s.users.UnlinkIdentity(r.Context(), user.ID, identityId)
+	if writeStoreError(w, err, "Failed to unlink identity", "Failed unlinking identity %d of user %d", identityId, user.ID) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Identity unlinked"))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegeroidc"
+	"github.com/MGavranovic/jaeger-backend/src/jaegeroidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// withMockIdP registers a mock identity provider as "mock"
+func (ts *testServer) withMockIdP(t *testing.T) *oidctest.IdP {
+	t.Helper()
+	idp := oidctest.New(t)
+	ts.oidcProviders = map[string]*jaegeroidc.Provider{
+		"mock": idp.Provider("mock", "http://jaeger.test/api/users/oidc/mock/callback"),
+	}
+	return idp
+}
+
+// startOIDCLogin starts the login and lets the user log in at the IdP, it returns the code and state of the callback
+func (c *testClient) startOIDCLogin(idp *oidctest.IdP) (string, string) {
+	c.t.Helper()
+	rec := c.expect(http.MethodGet, "/api/users/oidc/mock/login", nil, http.StatusFound)
+	if c.cookies["oidcState"] == "" {
+		c.t.Fatalf("login didn't set the state cookie")
+	}
+	code, state, err := idp.Authorize(rec.Header().Get("Location"))
+	if err != nil {
+		c.t.Fatalf("logging in at the IdP: %s", err)
+	}
+	return code, state
+}
+
+func callbackPath(code, state string) string {
+	return "/api/users/oidc/mock/callback?" + url.Values{"code": {code}, "state": {state}}.Encode()
+}
+
+// oidcError returns the error the callback redirected to the frontend with
+func oidcError(t *testing.T, location string) string {
+	t.Helper()
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("parsing the redirect %q: %s", location, err)
+	}
+	return u.Query().Get("error")
+}
+
+func TestOIDCLogin(t *testing.T) {
+	ts := newTestServer(t)
+	idp := ts.withMockIdP(t)
+	c := ts.client(t)
+
+	code, state := c.startOIDCLogin(idp)
+	rec := c.expect(http.MethodGet, callbackPath(code, state), nil, http.StatusSeeOther)
+	if reason := oidcError(t, rec.Header().Get("Location")); reason != "" {
+		t.Fatalf("callback failed with %q", reason)
+	}
+	if c.cookies["oidcState"] != "" {
+		t.Errorf("the state cookie outlived the callback")
+	}
+
+	rec = c.expect(http.MethodGet, "/api/users/current", nil, http.StatusOK)
+	if user := decodeResponse[jaegerdb.RetrievedUser](t, rec); user.Email != idp.Email || !user.Verified {
+		t.Errorf("logged in as %s verified=%t, want verified %s", user.Email, user.Verified, idp.Email)
+	}
+
+	// the code and the state can't be replayed
+	c.cookies = map[string]string{}
+	rec = c.expect(http.MethodGet, callbackPath(code, state), nil, http.StatusSeeOther)
+	if reason := oidcError(t, rec.Header().Get("Location")); reason != "login_expired" {
+		t.Errorf("replayed callback error = %q, want login_expired", reason)
+	}
+}
+
+func TestOIDCCallbackStateMismatch(t *testing.T) {
+	ts := newTestServer(t)
+	idp := ts.withMockIdP(t)
+
+	tests := []struct {
+		name   string
+		state  func(state string) string
+		cookie func(c *testClient)
+		reason string
+	}{
+		{name: "other state", state: func(string) string { return "forged" }, reason: "invalid_state"},
+		{name: "no state", state: func(string) string { return "" }, reason: "invalid_state"},
+		{name: "no cookie", cookie: func(c *testClient) { delete(c.cookies, "oidcState") }, reason: "login_expired"},
+		{name: "tampered cookie", cookie: func(c *testClient) { c.cookies["oidcState"] += "x" }, reason: "login_expired"},
+		{name: "cookie of another login", cookie: func(c *testClient) {
+			other := ts.client(t)
+			other.startOIDCLogin(idp)
+			c.cookies["oidcState"] = other.cookies["oidcState"]
+		}, reason: "invalid_state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ts.client(t)
+			code, state := c.startOIDCLogin(idp)
+			if tt.state != nil {
+				state = tt.state(state)
+			}
+			if tt.cookie != nil {
+				tt.cookie(c)
+			}
+
+			rec := c.expect(http.MethodGet, callbackPath(code, state), nil, http.StatusSeeOther)
+			if reason := oidcError(t, rec.Header().Get("Location")); reason != tt.reason {
+				t.Errorf("error = %q, want %q", reason, tt.reason)
+			}
+			if c.cookies["authToken"] != "" {
+				t.Errorf("the callback started a session")
+			}
+		})
+	}
+
+	if _, err := ts.store.GetUserByEmail(t.Context(), idp.Email); err == nil {
+		t.Errorf("a rejected callback created the user")
+	}
+}
+
+func TestOIDCCallbackRejectsBadIDToken(t *testing.T) {
+	ts := newTestServer(t)
+	idp := ts.withMockIdP(t)
+	c := ts.client(t)
+
+	idp.Tamper = func(claims jwt.MapClaims) { claims["nonce"] = "replayed nonce" }
+	code, state := c.startOIDCLogin(idp)
+	rec := c.expect(http.MethodGet, callbackPath(code, state), nil, http.StatusSeeOther)
+	if reason := oidcError(t, rec.Header().Get("Location")); reason != "invalid_token" {
+		t.Errorf("error = %q, want invalid_token", reason)
+	}
+	if c.cookies["authToken"] != "" {
+		t.Errorf("the callback started a session")
+	}
+}
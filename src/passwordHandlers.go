@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/MGavranovic/jaeger-backend/src/jaegermail"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerpassword"
+)
+
+const passwordResetDuration = time.Hour
+
+type ForgotPasswordData struct {
+	Email string `json:"email"`
+}
+
+func (req *ForgotPasswordData) validate() error {
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		return errors.New("Email is required")
+	}
+	return nil
+}
+
+type ResetPasswordData struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// validate only checks the fields are there, the password rules need the user the token belongs to
+func (req *ResetPasswordData) validate() error {
+	if req.Token == "" || req.Password == "" {
+		return errors.New("Token and password are required")
+	}
+	return nil
+}
+
+func (s *Server) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	forgotData, ok := decodeJSON[ForgotPasswordData](w, r, "forgot password")
+	if !ok {
+		return
+	}
+
+	ip := clientIP(r)
+	if ok, wait := s.resetIPLimits.allow(ip, passwordResetsPerIP, passwordResetIPWindow); !ok {
+		seconds := int(math.Ceil(wait.Seconds()))
+		log.Printf("Password reset requests from %s blocked for %ds", ip, seconds)
+		w.Header().Set("Retry-After", fmt.Sprint(seconds))
+		http.Error(w, "Too many password reset requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	// the response is the same whether the account exists or not so emails can't be enumerated,
+	// that goes for an address that was just sent a link as well
+	if ok, _ := s.resetEmailLimits.allow(strings.ToLower(forgotData.Email), 1, passwordResetCooldown); !ok {
+		log.Printf("Password reset for %s not sent, one was requested recently", forgotData.Email)
+	} else if err := s.sendPasswordReset(r.Context(), forgotData.Email); err != nil {
+		log.Printf("Password reset for %s not sent: %s", forgotData.Email, err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("If an account with that email exists, a password reset link has been sent"))
+}
+
+func (s *Server) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	token, tokenHash, err := jaegerjwt.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := s.users.CreatePasswordResetToken(ctx, user.ID, tokenHash, time.Now().Add(passwordResetDuration)); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", frontendURL(), url.QueryEscape(token))
+	return s.mailer.Send(jaegermail.Message{
+		To:      user.Email,
+		Subject: "Reset your Jaeger password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone requested a password reset for your Jaeger account.\n"+
+			"Use the link below to choose a new password, it expires in %d minutes and can only be used once:\n\n%s\n\n"+
+			"If you didn't request this you can ignore this email.\n", user.FullName, int(passwordResetDuration.Minutes()), link),
+	})
+}
+
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resetData, ok := decodeJSON[ResetPasswordData](w, r, "reset password")
+	if !ok {
+		return
+	}
+
+	// the password is checked against the details of the user the token was sent to, the token is used only once it passes
+	tokenHash := jaegerjwt.HashToken(resetData.Token)
+	userId, err := s.users.GetPasswordResetUser(r.Context(), tokenHash)
+	if writeStoreError(w, err, "Failed to reset password", "Failed looking up the password reset token") {
+		return
+	}
+	profile, err := s.users.GetProfile(r.Context(), userId)
+	if err != nil {
+		log.Printf("Failed retrieving user %d for the password reset: %s", userId, err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	if err := jaegerpassword.Validate(resetData.Password, profile.Email, profile.FullName); err != nil {
+		log.Printf("Password rejected on reset: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userId, err = s.users.ResetPasswordWithToken(r.Context(), tokenHash, resetData.Password)
+	if writeStoreError(w, err, "Failed to reset password", "Failed resetting the password") {
+		return
+	}
+
+	// whoever had access to the account before the reset gets logged out
+	if err := s.users.RevokeAllSessions(r.Context(), userId); err != nil {
+		log.Printf("Failed revoking sessions after password reset of user %d: %s", userId, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Password has been reset"))
+	log.Printf("Password of user %d reset", userId)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+const resetSubject = "Reset your Jaeger password"
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t)
+
+	// same answer as for existing accounts, but nothing is sent
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "nobody@example.com"}, http.StatusAccepted)
+	if mails := ts.mails(t, "nobody@example.com"); len(mails) != 0 {
+		t.Errorf("%d mails sent to an unknown email", len(mails))
+	}
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{}, http.StatusBadRequest)
+}
+
+func TestResetPassword(t *testing.T) {
+	ts := newTestServer(t)
+	session := ts.signup(t, "ada@example.com")
+	other := ts.client(t)
+	other.login("ada@example.com", testPassword)
+
+	c := ts.client(t)
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "ada@example.com"}, http.StatusAccepted)
+	token := ts.mailedToken(t, "ada@example.com", resetSubject)
+
+	// a password the policy rejects leaves the token usable
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: token, Password: "short"}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: token, Password: "a brand new passphrase"}, http.StatusOK)
+
+	// single use
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: token, Password: "another new passphrase"}, http.StatusBadRequest)
+
+	// every session from before the reset is gone
+	session.expect(http.MethodGet, "/api/users/current", nil, http.StatusUnauthorized)
+	other.expect(http.MethodPost, "/api/users/token/refresh", nil, http.StatusUnauthorized)
+
+	ts.client(t).expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: "ada@example.com", Password: testPassword}, http.StatusUnauthorized)
+	ts.client(t).login("ada@example.com", "a brand new passphrase")
+}
+
+func TestResetPasswordTokenInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup(t, "ada@example.com")
+	user := ts.user(t, "ada@example.com")
+	c := ts.client(t)
+
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: "made-up", Password: "a brand new passphrase"}, http.StatusBadRequest)
+
+	if err := ts.store.CreatePasswordResetToken(t.Context(), user.ID, jaegerjwt.HashToken("expired"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: "expired", Password: "a brand new passphrase"}, http.StatusBadRequest)
+
+	// requesting a new link invalidates the older ones
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "ada@example.com"}, http.StatusAccepted)
+	first := ts.mailedToken(t, "ada@example.com", resetSubject)
+	ts.resetEmailLimits.now = func() time.Time { return time.Now().Add(passwordResetCooldown) }
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "ada@example.com"}, http.StatusAccepted)
+	second := ts.mailedToken(t, "ada@example.com", resetSubject)
+	if first == second {
+		t.Fatalf("the second request mailed the same token")
+	}
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: first, Password: "a brand new passphrase"}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: second, Password: "a brand new passphrase"}, http.StatusOK)
+}
+
+func TestResetPasswordPersonalValues(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t)
+	c.expect(http.MethodPost, "/api/users/signup", UserFromFrontend{FullName: "Grace Hopper", Email: "grace.hopper@example.com", Password: testPassword}, http.StatusCreated)
+
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "grace.hopper@example.com"}, http.StatusAccepted)
+	token := ts.mailedToken(t, "grace.hopper@example.com", resetSubject)
+
+	// the request only carries the token, the name and email come from the account
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: token, Password: "grace hopper"}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: token, Password: "my grace.hopper pass"}, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/password/reset", ResetPasswordData{Token: token, Password: "a brand new passphrase"}, http.StatusOK)
+}
+
+func TestForgotPasswordLimits(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup(t, "ada@example.com")
+	c := ts.client(t)
+
+	// asking again within the cooldown gets the same answer but no second mail
+	for range 3 {
+		c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "ada@example.com"}, http.StatusAccepted)
+	}
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "ADA@example.com"}, http.StatusAccepted)
+	if mails := ts.mails(t, "ada@example.com"); len(mails) != 2 { // the verification mail and one reset
+		t.Errorf("%d mails sent, want the verification mail and one reset", len(mails))
+	}
+
+	now := time.Now()
+	ts.resetEmailLimits.now = func() time.Time { return now.Add(passwordResetCooldown) }
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "ada@example.com"}, http.StatusAccepted)
+	if mails := ts.mails(t, "ada@example.com"); len(mails) != 3 {
+		t.Errorf("no reset mail sent after the cooldown")
+	}
+
+	// the requests above count against the ip as well, the rest of its budget goes to other addresses
+	for i := 5; i < passwordResetsPerIP; i++ {
+		c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: fmt.Sprintf("user%d@example.com", i)}, http.StatusAccepted)
+	}
+	rec := c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "bob@example.com"}, http.StatusTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("throttled request has no Retry-After")
+	}
+
+	ts.resetIPLimits.now = func() time.Time { return now.Add(passwordResetIPWindow) }
+	c.expect(http.MethodPost, "/api/users/password/forgot", ForgotPasswordData{Email: "bob@example.com"}, http.StatusAccepted)
+}
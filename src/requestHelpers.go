@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// decodeJSON reads the request body into T and validates it, on failure the response is already written
+// validate normalizes the request in place, its error message is meant for the client
+func decodeJSON[T any, PT interface {
+	*T
+	validate() error
+}](w http.ResponseWriter, r *http.Request, what string) (T, bool) {
+	var req T
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Failed to decode %s data: %s", what, err)
+		http.Error(w, "Failed to decode "+what+" data", http.StatusBadRequest)
+		return req, false
+	}
+	if err := PT(&req).validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// clientErrors are the store errors the client can act on and how they're answered
+var clientErrors = []struct {
+	err     error
+	status  int
+	message string
+}{
+	{jaegerdb.ErrNoteNotFound, http.StatusNotFound, "Note not found"},
+	{jaegerdb.ErrInvalidStatus, http.StatusBadRequest, "Unknown application status"},
+	{jaegerdb.ErrInvalidStatusTransition, http.StatusConflict, "The application can't move to that status from its current one"},
+	{jaegerdb.ErrInterviewNotFound, http.StatusNotFound, "Interview not found"},
+	{jaegerdb.ErrContactNotFound, http.StatusNotFound, "Contact not found"},
+	{jaegerdb.ErrCompanyNotFound, http.StatusNotFound, "Company not found"},
+	{jaegerdb.ErrCompanyAliasNotFound, http.StatusNotFound, "Alias not found"},
+	{jaegerdb.ErrInvalidCompanyName, http.StatusBadRequest, "Name has to contain letters or digits"},
+	{jaegerdb.ErrCompanyExists, http.StatusConflict, "Another company already goes by that name"},
+	{jaegerdb.ErrCompanyHasNotes, http.StatusConflict, "Company still has applications, add its name as an alias of another company to merge them"},
+	{jaegerdb.ErrTagNotFound, http.StatusNotFound, "Tag not found"},
+	{jaegerdb.ErrTagExists, http.StatusConflict, "A tag with this name already exists"},
+	{jaegerdb.ErrUserNotFound, http.StatusNotFound, "User not found"},
+	{jaegerdb.ErrUserExists, http.StatusConflict, "No rows inserted. A user with this email already exists."},
+	{jaegerdb.ErrSessionNotFound, http.StatusNotFound, "Session not found"},
+	{jaegerdb.ErrAPITokenNotFound, http.StatusNotFound, "API token not found"},
+	{jaegerdb.ErrIdentityNotFound, http.StatusNotFound, "Identity not found"},
+	{jaegerdb.ErrResetTokenInvalid, http.StatusBadRequest, "Reset link is invalid or has expired"},
+	{jaegerdb.ErrVerificationTokenInvalid, http.StatusBadRequest, "Verification link is invalid or has expired"},
+	{jaegerdb.ErrTOTPAlreadyEnabled, http.StatusConflict, "Two factor authentication is already enabled"},
+}
+
+// writeStoreError answers err, errors the client can act on get their status and anything else is logged and a 500 with message
+// it reports whether there was an error to answer
+func writeStoreError(w http.ResponseWriter, err error, message string, logFormat string, args ...any) bool {
+	if err == nil {
+		return false
+	}
+	for _, known := range clientErrors {
+		if errors.Is(err, known.err) {
+			http.Error(w, known.message, known.status)
+			return true
+		}
+	}
+	log.Printf(logFormat+": %s", append(args, err)...)
+	http.Error(w, message, http.StatusInternalServerError)
+	return true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// password reset mails are sent to anyone who asks, these limits keep the endpoint from being used to flood inboxes
+const (
+	passwordResetCooldown = 5 * time.Minute // one reset mail per address
+	passwordResetsPerIP   = 10              // requests per client ip in passwordResetIPWindow
+	passwordResetIPWindow = time.Hour
+	limiterSweepSize      = 10000 // keys kept before the expired ones are swept
+)
+
+// requestLimiter counts requests per key in a sliding window. Like the login locks it only covers this process
+type requestLimiter struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+	now      func() time.Time // time.Now when nil, tests move it forward
+}
+
+// allow records a request for key if fewer than limit were made in the window before it,
+// otherwise it returns how long until the oldest of them leaves the window
+func (l *requestLimiter) allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	if l.requests == nil {
+		l.requests = map[string][]time.Time{}
+	}
+	if len(l.requests) >= limiterSweepSize {
+		for k, times := range l.requests {
+			if len(times) == 0 || now.Sub(times[len(times)-1]) >= window {
+				delete(l.requests, k)
+			}
+		}
+	}
+
+	times := l.requests[key]
+	for len(times) > 0 && now.Sub(times[0]) >= window {
+		times = times[1:]
+	}
+	if len(times) >= limit {
+		l.requests[key] = times
+		return false, times[0].Add(window).Sub(now)
+	}
+	l.requests[key] = append(times, now)
+	return true, 0
+}
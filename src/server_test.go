@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/MGavranovic/jaeger-backend/src/jaegermail"
+)
+
+// the handler tests run the whole API on the in-memory store, mail goes to a temporary outbox
+
+const testPassword = "correct-horse-battery-9"
+
+func TestMain(m *testing.M) {
+	// tokens are signed with a fixed HS256 key and the logs stay out of the test output
+	os.Setenv("JWT_ALG", "HS256")
+	os.Setenv("JWT_KEY", "jaeger test signing key")
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	*Server
+	store   *jaegerdb.MemoryStore
+	handler http.Handler
+	outbox  string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	store := jaegerdb.NewMemoryStore()
+	outbox := t.TempDir()
+	s := &Server{
+		users:  store,
+		notes:  store,
+		mailer: &jaegermail.OutboxMailer{Dir: outbox, From: "jaeger@test.local"},
+	}
+	jaegerjwt.SetSessionCheck(store.IsSessionActive)
+	t.Cleanup(func() { jaegerjwt.SetSessionCheck(nil) })
+	return &testServer{Server: s, store: store, handler: s.routes(), outbox: outbox}
+}
+
+// testClient acts like a browser, it keeps the cookies it gets and sends the CSRF token back
+type testClient struct {
+	t       *testing.T
+	srv     *testServer
+	cookies map[string]string
+	csrf    string
+	bearer  string // sent instead of the cookies when set
+}
+
+func (ts *testServer) client(t *testing.T) *testClient {
+	return &testClient{t: t, srv: ts, cookies: map[string]string{}}
+}
+
+func (c *testClient) do(method, path string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("marshaling the request body: %s", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if c.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearer)
+	} else {
+		for name, value := range c.cookies {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		if c.csrf != "" {
+			req.Header.Set(csrfHeaderName, c.csrf)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	c.srv.handler.ServeHTTP(rec, req)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie.Value
+		}
+	}
+	if token := rec.Header().Get(csrfHeaderName); token != "" {
+		c.csrf = token
+	}
+	return rec
+}
+
+// expect makes the request and fails the test unless it's answered with status
+func (c *testClient) expect(method, path string, body any, status int) *httptest.ResponseRecorder {
+	c.t.Helper()
+	rec := c.do(method, path, body)
+	if rec.Code != status {
+		c.t.Fatalf("%s %s = %d %q, want %d", method, path, rec.Code, strings.TrimSpace(rec.Body.String()), status)
+	}
+	return rec
+}
+
+func decodeResponse[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %q: %s", rec.Body.String(), err)
+	}
+	return v
+}
+
+// signup creates an account and returns a client logged in to it
+func (ts *testServer) signup(t *testing.T, email string) *testClient {
+	t.Helper()
+	c := ts.client(t)
+	c.expect(http.MethodPost, "/api/users/signup", UserFromFrontend{FullName: "Test User", Email: email, Password: testPassword}, http.StatusCreated)
+	c.login(email, testPassword)
+	return c
+}
+
+func (c *testClient) login(email, password string) {
+	c.t.Helper()
+	c.expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: email, Password: password}, http.StatusOK)
+}
+
+func (ts *testServer) user(t *testing.T, email string) *jaegerdb.RetrievedUser {
+	t.Helper()
+	user, err := ts.store.GetUserByEmail(t.Context(), email)
+	if err != nil {
+		t.Fatalf("looking up %s: %s", email, err)
+	}
+	return user
+}
+
+// mails returns the bodies of the messages in the outbox sent to the address, oldest first
+func (ts *testServer) mails(t *testing.T, to string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(ts.outbox)
+	if err != nil {
+		t.Fatalf("reading the outbox: %s", err)
+	}
+
+	var bodies []string
+	for _, entry := range entries { // the names start with the time they were written
+		data, err := os.ReadFile(filepath.Join(ts.outbox, entry.Name()))
+		if err != nil {
+			t.Fatalf("reading %s: %s", entry.Name(), err)
+		}
+		header, body, _ := strings.Cut(string(data), "\r\n\r\n")
+		if strings.Contains(header, "\r\nTo: "+to+"\r\n") {
+			bodies = append(bodies, body)
+		}
+	}
+	return bodies
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// mailedToken returns the token of the link in the last mail to the address whose subject contains subject
+func (ts *testServer) mailedToken(t *testing.T, to, subject string) string {
+	t.Helper()
+	entries, err := os.ReadDir(ts.outbox)
+	if err != nil {
+		t.Fatalf("reading the outbox: %s", err)
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(filepath.Join(ts.outbox, entries[i].Name()))
+		if err != nil {
+			t.Fatalf("reading %s: %s", entries[i].Name(), err)
+		}
+		header, body, _ := strings.Cut(string(data), "\r\n\r\n")
+		if !strings.Contains(header, "\r\nTo: "+to+"\r\n") || !strings.Contains(header, "Subject: "+subject) {
+			continue
+		}
+		match := linkToken.FindStringSubmatch(body)
+		if match == nil {
+			t.Fatalf("no link with a token in %q", body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatalf("unescaping the token: %s", err)
+		}
+		return token
+	}
+	t.Fatalf("no mail to %s about %q", to, subject)
+	return ""
+}
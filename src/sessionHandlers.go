@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+func (s *Server) handleGetSessions(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized sessions request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, jti := caller.User, caller.SessionID
+
+	sessions, err := s.users.GetActiveSessions(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed to retrieve sessions: %s", err)
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if sessions == nil {
+		sessions = []jaegerdb.SessionDB{}
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == jti // marking the session the request came from
+	}
+
+	jsonSessions, err := json.Marshal(sessions)
+	if err != nil {
+		log.Printf("Failed to marshal sessions: %s", err)
+		http.Error(w, "Failed to marshal sessions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonSessions)
+}
+
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized session revoke request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, jti := caller.User, caller.SessionID
+
+	sessionId := strings.TrimPrefix(r.URL.Path, "/api/users/current/sessions/")
+	if sessionId == "" {
+		http.Error(w, "Session ID is required", http.StatusBadRequest)
+		return
+	}
+
+	err = s.users.RevokeSession(r.Context(), user.ID, sessionId)
+	if writeStoreError(w, err, "Failed to revoke session", "Failed to revoke session %s", sessionId) {
+		return
+	}
+
+	// revoking the current session is the same as logging out
+	if sessionId == jti {
+		jaegerjwt.DeleteCookie(w)
+		jaegerjwt.DeleteRefreshCookie(w)
+		deleteCSRFCookie(w)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Session revoked"))
+	log.Printf("Session %s of user %d revoked", sessionId, user.ID)
+}
+
+func (s *Server) handleLogoutAllDevices(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized logout all request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := caller.User
+
+	if err := s.users.RevokeAllSessions(r.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke all sessions of user %d: %s", user.ID, err)
+		http.Error(w, "Failed to log out of all devices", http.StatusInternalServerError)
+		return
+	}
+
+	jaegerjwt.DeleteCookie(w)
+	jaegerjwt.DeleteRefreshCookie(w)
+	deleteCSRFCookie(w)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Logged out of all devices"))
+	log.Printf("User %d logged out of all devices", user.ID)
+}
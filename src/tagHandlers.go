@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+const (
+	maxTagNameLength = 40
+	maxFilterTags    = 50 // across all groups of a notes listing
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+type TagRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"` // #rrggbb, gray when left out
+}
+
+// validate normalizes the request, the error message is meant for the client
+func (req *TagRequest) validate() error {
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	req.Color = strings.ToLower(strings.TrimSpace(req.Color))
+
+	if req.Name == "" {
+		return errors.New("Name is required")
+	}
+	if len([]rune(req.Name)) > maxTagNameLength {
+		return fmt.Errorf("Name can be at most %d characters long", maxTagNameLength)
+	}
+	if req.Color == "" {
+		req.Color = jaegerdb.DefaultTagColor
+	}
+	if !tagColorPattern.MatchString(req.Color) {
+		return errors.New("Color has to be a hex color like #1e88e5")
+	}
+	return nil
+}
+
+func (req TagRequest) toTag() jaegerdb.TagDB {
+	return jaegerdb.TagDB{Name: req.Name, Color: req.Color}
+}
+
+// parseNoteFilter reads the tag filters of the notes listing, every tags parameter is a comma separated list of tag ids
+// a note has to match all tags parameters and matches one when it has any of its tags, ?tags=1,2&tags=3 is (1 OR 2) AND 3
+func parseNoteFilter(query url.Values) (jaegerdb.NoteFilter, error) {
+	var filter jaegerdb.NoteFilter
+	count := 0
+	for _, value := range query["tags"] {
+		var group []int
+		for _, id := range strings.Split(value, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			tagId, err := strconv.Atoi(id)
+			if err != nil {
+				return filter, fmt.Errorf("Invalid tag ID %q", id)
+			}
+			group = append(group, tagId)
+		}
+		count += len(group)
+		if len(group) > 0 {
+			filter.TagGroups = append(filter.TagGroups, group)
+		}
+	}
+	if count > maxFilterTags {
+		return filter, fmt.Errorf("At most %d tags can be filtered by", maxFilterTags)
+	}
+	return filter, nil
+}
+
+// handleTags serves GET and POST /api/tags
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		tags, err := s.notes.GetTags(r.Context(), caller.User.ID)
+		if writeStoreError(w, err, "Failed retrieving tags", "Failed retrieving the tags of user %d", caller.User.ID) {
+			return
+		}
+		if tags == nil {
+			tags = []jaegerdb.TagDB{}
+		}
+		writeJSON(w, http.StatusOK, tags)
+
+	case http.MethodPost:
+		req, ok := decodeJSON[TagRequest](w, r, "tag")
+		if !ok {
+			return
+		}
+		created, err := s.notes.CreateTag(r.Context(), caller.User.ID, req.toTag())
+		if writeStoreError(w, err, "Failed creating tag", "Failed creating a tag for user %d", caller.User.ID) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditTagCreate, "tag:"+strconv.Itoa(created.ID), created.Name)
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTag serves PUT and DELETE /api/tags/{id}, deleting a tag takes it off all notes
+func (s *Server) handleTag(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	tagId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	target := "tag:" + strconv.Itoa(tagId)
+
+	switch r.Method {
+	case http.MethodPut:
+		req, ok := decodeJSON[TagRequest](w, r, "tag")
+		if !ok {
+			return
+		}
+		tag := req.toTag()
+		tag.ID = tagId
+		updated, err := s.notes.UpdateTag(r.Context(), caller.User.ID, tag)
+		if writeStoreError(w, err, "Failed updating tag", "Failed updating tag %d", tagId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditTagUpdate, target, "")
+		writeJSON(w, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		err := s.notes.DeleteTag(r.Context(), caller.User.ID, tagId)
+		if writeStoreError(w, err, "Failed deleting tag", "Failed deleting tag %d", tagId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditTagDelete, target, "")
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleNoteTags serves GET /api/notes/{id}/tags
+func (s *Server) handleNoteTags(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	noteId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := s.notes.GetNoteTags(r.Context(), caller.User.ID, noteId)
+	if writeStoreError(w, err, "Failed retrieving tags", "Failed retrieving the tags of note %d", noteId) {
+		return
+	}
+	if tags == nil {
+		tags = []jaegerdb.NoteTagDB{}
+	}
+	writeJSON(w, http.StatusOK, tags)
+}
+
+// handleNoteTag serves PUT and DELETE /api/notes/{id}/tags/{tagId}, attaching and detaching the tag
+func (s *Server) handleNoteTag(w http.ResponseWriter, r *http.Request) {
+	caller := callerFromContext(r)
+
+	noteId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	tagId, err := strconv.Atoi(r.PathValue("tagId"))
+	if err != nil {
+		http.Error(w, "Invalid tag ID format", http.StatusBadRequest)
+		return
+	}
+	target := "note:" + strconv.Itoa(noteId)
+	details := "tag:" + strconv.Itoa(tagId)
+
+	switch r.Method {
+	case http.MethodPut:
+		err := s.notes.TagNote(r.Context(), caller.User.ID, noteId, tagId)
+		if writeStoreError(w, err, "Failed tagging note", "Failed tagging note %d with tag %d", noteId, tagId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditNoteTag, target, details)
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodDelete:
+		err := s.notes.UntagNote(r.Context(), caller.User.ID, noteId, tagId)
+		if errors.Is(err, jaegerdb.ErrTagNotFound) {
+			http.Error(w, "Tag is not on the note", http.StatusNotFound)
+			return
+		}
+		if writeStoreError(w, err, "Failed removing tag", "Failed removing tag %d from note %d", tagId, noteId) {
+			return
+		}
+		s.audit(r, caller.User.ID, caller.User.ID, jaegerdb.AuditNoteUntag, target, details)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
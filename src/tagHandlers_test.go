@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+)
+
+// createTag creates a tag and returns its id
+func (c *testClient) createTag(name string) string {
+	c.t.Helper()
+	tag := decodeResponse[jaegerdb.TagDB](c.t, c.expect(http.MethodPost, "/api/tags", TagRequest{Name: name}, http.StatusCreated))
+	return strconv.Itoa(tag.ID)
+}
+
+func TestCreateTag(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	// names are compared without case, whitespace inside them is collapsed and colors are lowercased
+	tests := []struct {
+		name      string
+		req       TagRequest
+		status    int
+		wantName  string
+		wantColor string
+	}{
+		{"default color", TagRequest{Name: "remote"}, http.StatusCreated, "remote", jaegerdb.DefaultTagColor},
+		{"normalized", TagRequest{Name: "  dream   job ", Color: " #1E88E5 "}, http.StatusCreated, "dream job", "#1e88e5"},
+		{"longest name in runes", TagRequest{Name: strings.Repeat("ž", maxTagNameLength)}, http.StatusCreated, strings.Repeat("ž", maxTagNameLength), jaegerdb.DefaultTagColor},
+		{"taken in another case", TagRequest{Name: "Remote"}, http.StatusConflict, "", ""},
+		{"no name", TagRequest{Name: "   "}, http.StatusBadRequest, "", ""},
+		{"too long", TagRequest{Name: strings.Repeat("x", maxTagNameLength+1)}, http.StatusBadRequest, "", ""},
+		{"named color", TagRequest{Name: "onsite", Color: "red"}, http.StatusBadRequest, "", ""},
+		{"short hex", TagRequest{Name: "onsite", Color: "#fff"}, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.t = t
+			rec := c.expect(http.MethodPost, "/api/tags", tt.req, tt.status)
+			if tt.status != http.StatusCreated {
+				return
+			}
+			if tag := decodeResponse[jaegerdb.TagDB](t, rec); tag.Name != tt.wantName || tag.Color != tt.wantColor {
+				t.Errorf("tag = %q %q, want %q %q", tag.Name, tag.Color, tt.wantName, tt.wantColor)
+			}
+		})
+	}
+	c.t = t
+
+	if tags := decodeResponse[[]jaegerdb.TagDB](t, c.expect(http.MethodGet, "/api/tags", nil, http.StatusOK)); len(tags) != 3 {
+		t.Errorf("tags = %+v, want the 3 created ones", tags)
+	}
+}
+
+func TestNotesTagFilter(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+
+	remote, senior, startup := c.createTag("remote"), c.createTag("senior"), c.createTag("startup")
+	notes := map[string]jaegerdb.NoteDB{}
+	for _, company := range []string{"Acme", "Globex", "Initech", "Umbrella"} {
+		notes[company] = c.createNote(company)
+	}
+	tag := func(company, tagId string) {
+		c.expect(http.MethodPut, "/api/notes/"+strconv.Itoa(notes[company].Id)+"/tags/"+tagId, nil, http.StatusOK)
+	}
+	tag("Acme", remote)
+	tag("Acme", senior)
+	tag("Globex", remote)
+	tag("Initech", startup)
+	tag("Initech", senior)
+
+	listed := func(query string) []string {
+		t.Helper()
+		var companies []string
+		for _, note := range decodeResponse[[]jaegerdb.NoteDB](t, c.expect(http.MethodGet, "/api/notes/?"+query, nil, http.StatusOK)) {
+			companies = append(companies, note.CompanyName)
+		}
+		slices.Sort(companies)
+		return companies
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Acme", "Globex", "Initech", "Umbrella"}},
+		{"tags=" + remote, []string{"Acme", "Globex"}},
+		{"tags=" + remote + "," + startup, []string{"Acme", "Globex", "Initech"}},
+		{"tags=" + remote + "&tags=" + senior, []string{"Acme"}},
+		{"tags=" + remote + "," + startup + "&tags=" + senior, []string{"Acme", "Initech"}},
+		{"tags=" + remote + "&tags=" + startup, nil},
+		{"tags=," + remote + ",,&tags=&tags=%20" + senior, []string{"Acme"}},
+		{"tags=999", nil},
+	}
+	for _, tt := range tests {
+		if got := listed(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("notes for ?%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	for _, query := range []string{"tags=remote", "tags=" + remote + ",x", "tags=" + strings.Repeat(remote+",", maxFilterTags+1)} {
+		c.expect(http.MethodGet, "/api/notes/?"+query, nil, http.StatusBadRequest)
+	}
+
+	// deleting a tag takes it off the notes
+	c.expect(http.MethodDelete, "/api/tags/"+senior, nil, http.StatusOK)
+	if got := listed("tags=" + senior); got != nil {
+		t.Errorf("notes with a deleted tag = %v", got)
+	}
+}
+
+func TestTagLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	ada := ts.signup(t, "ada@example.com")
+	bob := ts.signup(t, "bob@example.com")
+
+	remote := ada.createTag("remote")
+	ada.createTag("senior")
+	note := strconv.Itoa(ada.createNote("Acme").Id)
+
+	ada.expect(http.MethodPut, "/api/tags/"+remote, TagRequest{Name: "SENIOR"}, http.StatusConflict)
+	ada.expect(http.MethodPut, "/api/tags/"+remote, TagRequest{Name: "Remote", Color: "#43a047"}, http.StatusOK)
+
+	// another user's tags and notes don't exist for them
+	bob.expect(http.MethodPut, "/api/tags/"+remote, TagRequest{Name: "mine"}, http.StatusNotFound)
+	bob.expect(http.MethodDelete, "/api/tags/"+remote, nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, "/api/notes/"+note+"/tags/"+remote, nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, "/api/notes/"+strconv.Itoa(bob.createNote("Globex").Id)+"/tags/"+remote, nil, http.StatusNotFound)
+	bob.createTag("remote")
+
+	ada.expect(http.MethodPut, "/api/notes/"+note+"/tags/"+remote, nil, http.StatusOK)
+	tags := decodeResponse[[]jaegerdb.NoteTagDB](t, ada.expect(http.MethodGet, "/api/notes/"+note+"/tags", nil, http.StatusOK))
+	if len(tags) != 1 || tags[0].Name != "Remote" || tags[0].Color != "#43a047" {
+		t.Errorf("tags of the note = %+v", tags)
+	}
+	ada.expect(http.MethodDelete, "/api/notes/"+note+"/tags/"+remote, nil, http.StatusOK)
+	ada.expect(http.MethodDelete, "/api/notes/"+note+"/tags/"+remote, nil, http.StatusNotFound)
+}
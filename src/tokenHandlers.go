@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+// startSession creates a new session for the user, issues a short lived access token and starts the session's refresh token family
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, email string) error {
+	user, err := s.users.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		return err
+	}
+	if user.Disabled {
+		return errAccountDisabled
+	}
+	// set by an admin, every way of logging in waits for the reset, not only the password
+	if user.PasswordResetRequired {
+		return errPasswordResetRequired
+	}
+	// logging in during the grace period cancels the account deletion
+	if user.DeletionScheduled {
+		if err := s.users.CancelUserDeletion(r.Context(), user.ID); err != nil {
+			return err
+		}
+		log.Printf("Deletion of user %d cancelled by logging in", user.ID)
+	}
+
+	// the session id is used both as the jti of the access tokens and as the refresh token family
+	jti, err := jaegerjwt.NewTokenID()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(jaegerjwt.RefreshTokenDuration)
+	if err := s.users.CreateSession(r.Context(), jti, user.ID, r.UserAgent(), clientIP(r), expiresAt); err != nil {
+		return err
+	}
+
+	token, err := jaegerjwt.GenerateJWT(email, jti, user.Role) // generating the access token
+	if err != nil {
+		return err
+	}
+
+	refreshToken, refreshHash, err := jaegerjwt.GenerateRefreshToken()
+	if err != nil {
+		return err
+	}
+	if err := s.users.CreateRefreshToken(r.Context(), user.ID, jti, refreshHash, expiresAt); err != nil {
+		return err
+	}
+
+	jaegerjwt.SetTokenInCookies(w, token) // setting the cookies
+	jaegerjwt.SetRefreshTokenInCookies(w, refreshToken)
+	if err := issueCSRFToken(w); err != nil {
+		return err
+	}
+	s.audit(r, user.ID, user.ID, jaegerdb.AuditLogin, "session:"+jti, "")
+	return nil
+}
+
+func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie("refreshToken")
+	if err != nil {
+		log.Printf("Refresh unsuccessful, no refresh cookie: %s", err)
+		http.Error(w, "No refresh token - Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	newToken, newHash, err := jaegerjwt.GenerateRefreshToken()
+	if err != nil {
+		log.Printf("Failed to generate refresh token: %s", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	email, jti, err := s.users.RotateRefreshToken(r.Context(), jaegerjwt.HashToken(cookie.Value), newHash, time.Now().Add(jaegerjwt.RefreshTokenDuration))
+	if err != nil {
+		if errors.Is(err, jaegerdb.ErrRefreshTokenReused) || errors.Is(err, jaegerdb.ErrRefreshTokenInvalid) {
+			log.Printf("Refresh token rejected: %s", err)
+			jaegerjwt.DeleteCookie(w)
+			jaegerjwt.DeleteRefreshCookie(w)
+			deleteCSRFCookie(w)
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Failed to rotate refresh token: %s", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	// the role is read again so a changed role ends up in the new token,
+	// an account scheduled for deletion only comes back through a new login
+	user, err := s.users.GetUserByEmail(r.Context(), email)
+	if err != nil || user.Disabled || user.PasswordResetRequired || user.DeletionScheduled {
+		log.Printf("Refresh for unavailable user %s: %v", email, err)
+		jaegerjwt.DeleteCookie(w)
+		jaegerjwt.DeleteRefreshCookie(w)
+		deleteCSRFCookie(w)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jaegerjwt.GenerateJWT(email, jti, user.Role)
+	if err != nil {
+		log.Printf("Failed to generate token: %s", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	jaegerjwt.SetTokenInCookies(w, token)
+	jaegerjwt.SetRefreshTokenInCookies(w, newToken)
+	if err := ensureCSRFToken(w, r); err != nil {
+		log.Printf("Failed to issue CSRF token: %s", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Token refreshed"))
+	log.Printf("Token refreshed for user %s", email)
+}
+
+// handleJWKS publishes the public signing keys so other services can verify Jaeger tokens
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jwks, err := jaegerjwt.JWKS()
+	if err != nil {
+		log.Printf("Failed building JWKS: %s", err)
+		http.Error(w, "Failed to retrieve signing keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*") // public keys can be fetched from anywhere
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, jwks)
+}
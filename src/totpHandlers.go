@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/MGavranovic/jaeger-backend/src/jaegertotp"
+)
+
+const recoveryCodeCount = 10
+
+var errSecondFactorInvalid = errors.New("two factor code is invalid")
+
+type SecondFactorData struct {
+	MFAToken     string `json:"mfaToken,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
+type TOTPStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Jaeger"
+}
+
+// verifySecondFactor accepts either a current TOTP code or one of the unused recovery codes
+func (s *Server) verifySecondFactor(ctx context.Context, userId int, state jaegerdb.TOTPStateDB, code, recoveryCode string) error {
+	if code != "" {
+		counter, ok := jaegertotp.Validate(state.Secret, code, time.Now())
+		if !ok {
+			return errSecondFactorInvalid
+		}
+		return s.users.UseTOTPCounter(ctx, userId, counter)
+	}
+	if recoveryCode != "" {
+		return s.users.UseRecoveryCode(ctx, userId, jaegerjwt.HashToken(jaegertotp.NormalizeRecoveryCode(recoveryCode)))
+	}
+	return errSecondFactorInvalid
+}
+
+// newRecoveryCodes returns the codes to show to the user once and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := jaegertotp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = jaegerjwt.HashToken(jaegertotp.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed marshaling response to json: %s", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// handleLoginSecondFactor is the second login step for users with 2FA enabled
+func (s *Server) handleLoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var secondFactor SecondFactorData
+	if err := json.NewDecoder(r.Body).Decode(&secondFactor); err != nil {
+		log.Printf("Error decoding second factor data: %s", err)
+		http.Error(w, "Failed to decode second factor data", http.StatusBadRequest)
+		return
+	}
+
+	email, err := jaegerjwt.ValidateMFAToken(secondFactor.MFAToken)
+	if err != nil {
+		log.Printf("Invalid MFA token: %s", err)
+		http.Error(w, "Login expired, please log in again", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := s.users.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		log.Printf("Failed retrieving user for second factor: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	state, err := s.users.GetTOTPState(r.Context(), user.ID)
+	if err != nil || !state.Enabled {
+		log.Printf("Second factor requested for user %d without 2FA enabled: %v", user.ID, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// wrong codes count towards the same lockout as wrong passwords
+	unlock := s.lockLogin(r, email)
+	defer unlock()
+	if s.rejectThrottledLogin(w, r, email, http.Error) {
+		return
+	}
+
+	if err := s.verifySecondFactor(r.Context(), user.ID, state, secondFactor.Code, secondFactor.RecoveryCode); err != nil {
+		log.Printf("Second factor rejected for user %d: %s", user.ID, err)
+		s.recordLoginAttempt(r, email, false)
+		http.Error(w, "Invalid two factor code", http.StatusUnauthorized)
+		return
+	}
+
+	// only now the user gets the tokens
+	if err := s.startSession(w, r, email); err != nil {
+		log.Printf("Failed to generate token: %s", err)
+		if errors.Is(err, errAccountDisabled) {
+			http.Error(w, "Account has been disabled", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, errPasswordResetRequired) {
+			http.Error(w, "Password reset required, check your email for the reset link", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	s.recordLoginAttempt(r, email, true)
+
+	w.WriteHeader(http.StatusOK)
+	log.Print("Users successfully logged in with second factor!")
+}
+
+func (s *Server) handleTOTPStatus(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized 2FA status request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := caller.User
+
+	state, err := s.users.GetTOTPState(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed retrieving 2FA state of user %d: %s", user.ID, err)
+		http.Error(w, "Failed to retrieve 2FA status", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TOTPStatusResponse{Enabled: state.Enabled, RecoveryCodesLeft: state.RecoveryCodesLeft})
+}
+
+// handleTOTPSetup generates a new secret, 2FA is only turned on after it's confirmed with a code
+func (s *Server) handleTOTPSetup(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized 2FA setup request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := caller.User
+
+	secret, err := jaegertotp.GenerateSecret()
+	if err != nil {
+		log.Printf("Failed generating TOTP secret: %s", err)
+		http.Error(w, "Failed to set up 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.users.SetPendingTOTPSecret(r.Context(), user.ID, secret)
+	if writeStoreError(w, err, "Failed to set up 2FA", "Failed storing TOTP secret of user %d", user.ID) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TOTPSetupResponse{Secret: secret, OtpauthURI: jaegertotp.URI(totpIssuer(), user.Email, secret)})
+}
+
+func (s *Server) handleTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized 2FA confirm request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := caller.User
+
+	var confirmData SecondFactorData
+	if err := json.NewDecoder(r.Body).Decode(&confirmData); err != nil || confirmData.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	state, err := s.users.GetTOTPState(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed retrieving 2FA state of user %d: %s", user.ID, err)
+		http.Error(w, "Failed to confirm 2FA", http.StatusInternalServerError)
+		return
+	}
+	if state.Enabled {
+		http.Error(w, "Two factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if state.Secret == "" {
+		http.Error(w, jaegerdb.ErrTOTPNotSetUp.Error(), http.StatusBadRequest)
+		return
+	}
+
+	counter, ok := jaegertotp.Validate(state.Secret, confirmData.Code, time.Now())
+	if !ok {
+		http.Error(w, "Invalid two factor code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Printf("Failed generating recovery codes: %s", err)
+		http.Error(w, "Failed to confirm 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.users.EnableTOTP(r.Context(), user.ID, counter, hashes); err != nil {
+		log.Printf("Failed enabling 2FA for user %d: %s", user.ID, err)
+		http.Error(w, "Failed to confirm 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	// the recovery codes are only ever shown here
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	log.Printf("2FA enabled for user %d", user.ID)
+}
+
+func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := s.checkSecondFactorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.users.DisableTOTP(r.Context(), user.ID); err != nil {
+		log.Printf("Failed disabling 2FA for user %d: %s", user.ID, err)
+		http.Error(w, "Failed to disable 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Two factor authentication disabled"))
+	log.Printf("2FA disabled for user %d", user.ID)
+}
+
+func (s *Server) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := s.checkSecondFactorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Printf("Failed generating recovery codes: %s", err)
+		http.Error(w, "Failed to regenerate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.users.RegenerateRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+		log.Printf("Failed storing recovery codes for user %d: %s", user.ID, err)
+		http.Error(w, "Failed to regenerate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// checkSecondFactorRequest authenticates the user and checks the code sent along with changes to 2FA settings
+// it writes the error response itself and returns false if the request shouldn't continue
+func (s *Server) checkSecondFactorRequest(w http.ResponseWriter, r *http.Request) (*jaegerdb.RetrievedUser, bool) {
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized 2FA request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	user := caller.User
+
+	var secondFactor SecondFactorData
+	if err := json.NewDecoder(r.Body).Decode(&secondFactor); err != nil {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	state, err := s.users.GetTOTPState(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed retrieving 2FA state of user %d: %s", user.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !state.Enabled {
+		http.Error(w, "Two factor authentication is not enabled", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if err := s.verifySecondFactor(r.Context(), user.ID, state, secondFactor.Code, secondFactor.RecoveryCode); err != nil {
+		log.Printf("Second factor rejected for user %d: %s", user.ID, err)
+		http.Error(w, "Invalid two factor code", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// secondFactorRequired is checked on login after the password matched
+func (s *Server) secondFactorRequired(ctx context.Context, email string) (bool, error) {
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return false, fmt.Errorf("failed retrieving user: %w", err)
+	}
+	state, err := s.users.GetTOTPState(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return state.Enabled, nil
+}
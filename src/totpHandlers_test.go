@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegertotp"
+)
+
+// totpCode returns the code for the time step
+func totpCode(t *testing.T, secret string, counter int64) string {
+	t.Helper()
+	code, err := jaegertotp.Code(secret, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enableTOTP sets up 2FA for the client's user and returns the secret, the time step of the code it was confirmed with
+// and the recovery codes. Codes are made from that step so the tests don't depend on crossing into the next one
+func (c *testClient) enableTOTP() (string, int64, []string) {
+	c.t.Helper()
+	setup := decodeResponse[TOTPSetupResponse](c.t, c.expect(http.MethodPost, "/api/users/current/2fa/setup", nil, http.StatusOK))
+	if !strings.Contains(setup.OtpauthURI, "secret="+setup.Secret) {
+		c.t.Errorf("otpauth URI %q doesn't carry the secret", setup.OtpauthURI)
+	}
+
+	c.expect(http.MethodPost, "/api/users/current/2fa/confirm", SecondFactorData{Code: "000000"}, http.StatusBadRequest)
+	counter := jaegertotp.Counter(time.Now())
+	confirmed := decodeResponse[RecoveryCodesResponse](c.t, c.expect(http.MethodPost, "/api/users/current/2fa/confirm", SecondFactorData{Code: totpCode(c.t, setup.Secret, counter)}, http.StatusOK))
+	if len(confirmed.RecoveryCodes) != recoveryCodeCount {
+		c.t.Fatalf("got %d recovery codes, want %d", len(confirmed.RecoveryCodes), recoveryCodeCount)
+	}
+	return setup.Secret, counter, confirmed.RecoveryCodes
+}
+
+// passwordLogin logs in with the password and returns the MFA token the second step needs
+func (c *testClient) passwordLogin(email string) string {
+	c.t.Helper()
+	rec := c.expect(http.MethodPost, "/api/v2/users/login", LoginRequest{Email: email, Password: testPassword}, http.StatusAccepted)
+	if c.cookies["authToken"] != "" || c.cookies["refreshToken"] != "" {
+		c.t.Fatalf("the password alone started a session")
+	}
+	return decodeResponse[struct {
+		MFAToken string `json:"mfaToken"`
+	}](c.t, rec).MFAToken
+}
+
+func TestTOTPLogin(t *testing.T) {
+	ts := newTestServer(t)
+	session := ts.signup(t, "ada@example.com")
+	secret, counter, _ := session.enableTOTP()
+	session.expect(http.MethodPost, "/api/users/current/2fa/setup", nil, http.StatusConflict)
+
+	c := ts.client(t)
+	mfaToken := c.passwordLogin("ada@example.com")
+	c.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: "forged", Code: totpCode(t, secret, counter+1)}, http.StatusUnauthorized)
+
+	// the code that confirmed the setup has been used, the next one hasn't
+	c.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: mfaToken, Code: totpCode(t, secret, counter)}, http.StatusUnauthorized)
+	next := totpCode(t, secret, counter+1)
+	c.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: mfaToken, Code: next}, http.StatusOK)
+	c.expect(http.MethodGet, "/api/users/current", nil, http.StatusOK)
+
+	// a code seen by someone else can't be replayed in its 30 seconds
+	replay := ts.client(t)
+	replay.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: replay.passwordLogin("ada@example.com"), Code: next}, http.StatusUnauthorized)
+	if replay.cookies["authToken"] != "" {
+		t.Errorf("replayed code started a session")
+	}
+}
+
+func TestTOTPRecoveryCodes(t *testing.T) {
+	ts := newTestServer(t)
+	session := ts.signup(t, "ada@example.com")
+	_, _, codes := session.enableTOTP()
+
+	// typed without the dash and in upper case, and only once
+	recovery := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	c := ts.client(t)
+	c.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: c.passwordLogin("ada@example.com"), RecoveryCode: recovery}, http.StatusOK)
+	again := ts.client(t)
+	again.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: again.passwordLogin("ada@example.com"), RecoveryCode: codes[0]}, http.StatusUnauthorized)
+
+	status := decodeResponse[TOTPStatusResponse](t, session.expect(http.MethodGet, "/api/users/current/2fa", nil, http.StatusOK))
+	if !status.Enabled || status.RecoveryCodesLeft != recoveryCodeCount-1 {
+		t.Errorf("status = %+v, want enabled with %d codes left", status, recoveryCodeCount-1)
+	}
+
+	// new codes replace the old ones
+	regenerated := decodeResponse[RecoveryCodesResponse](t, session.expect(http.MethodPost, "/api/users/current/2fa/recovery-codes", SecondFactorData{RecoveryCode: codes[1]}, http.StatusOK))
+	again.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: again.passwordLogin("ada@example.com"), RecoveryCode: codes[2]}, http.StatusUnauthorized)
+	again.expect(http.MethodPost, "/api/users/login/2fa", SecondFactorData{MFAToken: again.passwordLogin("ada@example.com"), RecoveryCode: regenerated.RecoveryCodes[0]}, http.StatusOK)
+}
+
+func TestTOTPDisable(t *testing.T) {
+	ts := newTestServer(t)
+	session := ts.signup(t, "ada@example.com")
+	_, _, codes := session.enableTOTP()
+
+	// turning 2FA off needs a second factor as well, a stolen session isn't enough
+	session.expect(http.MethodPost, "/api/users/current/2fa/disable", SecondFactorData{}, http.StatusUnauthorized)
+	session.expect(http.MethodPost, "/api/users/current/2fa/disable", SecondFactorData{Code: "000000"}, http.StatusUnauthorized)
+	session.expect(http.MethodPost, "/api/users/current/2fa/disable", SecondFactorData{RecoveryCode: codes[0]}, http.StatusOK)
+	session.expect(http.MethodPost, "/api/users/current/2fa/disable", SecondFactorData{RecoveryCode: codes[1]}, http.StatusBadRequest)
+
+	if status := decodeResponse[TOTPStatusResponse](t, session.expect(http.MethodGet, "/api/users/current/2fa", nil, http.StatusOK)); status.Enabled {
+		t.Errorf("2FA still enabled after disabling it")
+	}
+	ts.client(t).login("ada@example.com", testPassword)
+}
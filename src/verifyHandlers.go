@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerdb"
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+	"github.com/MGavranovic/jaeger-backend/src/jaegermail"
+)
+
+const emailVerificationDuration = 48 * time.Hour
+
+// publicURL is where this API is reachable from the outside, used for links in emails
+func publicURL() string {
+	if url := os.Getenv("PUBLIC_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:8080"
+}
+
+// validEmail makes sure the email is a bare address like name@example.com
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+func (s *Server) sendEmailVerification(ctx context.Context, user *jaegerdb.RetrievedUser) error {
+	token, tokenHash, err := jaegerjwt.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := s.users.CreateEmailVerificationToken(ctx, user.ID, user.Email, tokenHash, time.Now().Add(emailVerificationDuration)); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/users/verify?token=%s", publicURL(), url.QueryEscape(token))
+	return s.mailer.Send(jaegermail.Message{
+		To:      user.Email,
+		Subject: "Verify your Jaeger email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this is your email address by opening the link below, it expires in %d hours:\n\n%s\n\n"+
+			"If you didn't create a Jaeger account you can ignore this email.\n", user.FullName, int(emailVerificationDuration.Hours()), link),
+	})
+}
+
+func (s *Server) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	userId, err := s.users.VerifyEmailWithToken(r.Context(), jaegerjwt.HashToken(token))
+	if writeStoreError(w, err, "Failed to verify email", "Failed verifying email") {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Email verified"))
+	log.Printf("Email of user %d verified", userId)
+}
+
+func (s *Server) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, err := s.authenticateSession(r)
+	if err != nil {
+		log.Printf("Unauthorized verification resend request: %s", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := caller.User
+
+	if user.Verified {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	if err := s.sendEmailVerification(r.Context(), user); err != nil {
+		log.Printf("Failed sending verification email to user %d: %s", user.ID, err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Verification email sent"))
+}
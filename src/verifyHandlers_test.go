@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/MGavranovic/jaeger-backend/src/jaegerjwt"
+)
+
+const verifySubject = "Verify your Jaeger email address"
+
+func verifyPath(token string) string {
+	return "/api/users/verify?token=" + url.QueryEscape(token)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	token := ts.mailedToken(t, "ada@example.com", verifySubject)
+
+	// the link is opened from the mail, without the session
+	link := ts.client(t)
+	link.expect(http.MethodGet, "/api/users/verify", nil, http.StatusBadRequest)
+	link.expect(http.MethodGet, verifyPath("made-up"), nil, http.StatusBadRequest)
+	link.expect(http.MethodGet, verifyPath(token), nil, http.StatusOK)
+	if !ts.user(t, "ada@example.com").Verified {
+		t.Fatalf("user not verified by the mailed link")
+	}
+
+	// single use, and there is nothing left to resend
+	link.expect(http.MethodGet, verifyPath(token), nil, http.StatusBadRequest)
+	c.expect(http.MethodPost, "/api/users/verify/resend", nil, http.StatusConflict)
+}
+
+func TestVerifyEmailExpired(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	user := ts.user(t, "ada@example.com")
+
+	if err := ts.store.CreateEmailVerificationToken(t.Context(), user.ID, user.Email, jaegerjwt.HashToken("expired"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	c.expect(http.MethodGet, verifyPath("expired"), nil, http.StatusBadRequest)
+	if ts.user(t, "ada@example.com").Verified {
+		t.Fatalf("user verified by an expired token")
+	}
+
+	// a new link can be requested, it replaces the older ones
+	ts.client(t).expect(http.MethodPost, "/api/users/verify/resend", nil, http.StatusUnauthorized)
+	first := ts.mailedToken(t, "ada@example.com", verifySubject)
+	c.expect(http.MethodPost, "/api/users/verify/resend", nil, http.StatusAccepted)
+	resent := ts.mailedToken(t, "ada@example.com", verifySubject)
+	if resent == first {
+		t.Fatalf("resend mailed the same token")
+	}
+	c.expect(http.MethodGet, verifyPath(first), nil, http.StatusBadRequest)
+	c.expect(http.MethodGet, verifyPath(resent), nil, http.StatusOK)
+}
+
+func TestVerifyEmailAfterEmailChange(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.signup(t, "ada@example.com")
+	old := ts.mailedToken(t, "ada@example.com", verifySubject)
+
+	// the link sent to the old address doesn't verify the new one
+	c.expect(http.MethodPut, "/api/users/current/update", UpdatedUserData{Email: "ada@example.org"}, http.StatusOK)
+	c.expect(http.MethodGet, verifyPath(old), nil, http.StatusBadRequest)
+
+	c.expect(http.MethodGet, verifyPath(ts.mailedToken(t, "ada@example.org", verifySubject)), nil, http.StatusOK)
+	if !ts.user(t, "ada@example.org").Verified {
+		t.Errorf("new address not verified by its link")
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	ts := newTestServer(t)
+	ts.requireVerifiedEmail = true
+	c := ts.signup(t, "ada@example.com")
+
+	// logging in works, creating notes waits for the verification
+	note := Note{CompanyName: "Acme", AppliedOn: "2026-09-01"}
+	c.expect(http.MethodPost, "/api/notes/create", note, http.StatusForbidden)
+	if notes := c.listNotes(); len(notes) != 0 {
+		t.Fatalf("unverified user created notes %v", notes)
+	}
+
+	c.expect(http.MethodGet, verifyPath(ts.mailedToken(t, "ada@example.com", verifySubject)), nil, http.StatusOK)
+	c.expect(http.MethodPost, "/api/notes/create", note, http.StatusOK)
+}